@@ -0,0 +1,394 @@
+package check_expiry
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yakuter/gossl/pkg/probe"
+	"github.com/yakuter/gossl/pkg/utils"
+
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	CmdCheckExpiry = "check-expiry"
+
+	flagWarn   = "warn"
+	flagCrit   = "crit"
+	flagFormat = "format"
+
+	formatText = "text"
+	formatJSON = "json"
+)
+
+// Nagios plugin exit codes
+const (
+	ExitOK       = 0
+	ExitWarning  = 1
+	ExitCritical = 2
+	ExitUnknown  = 3
+)
+
+const (
+	statusOK       = "OK"
+	statusWarning  = "WARNING"
+	statusCritical = "CRITICAL"
+	statusUnknown  = "UNKNOWN"
+)
+
+// statusCodes are exit codes of statuses
+var statusCodes = map[string]int{
+	statusOK:       ExitOK,
+	statusWarning:  ExitWarning,
+	statusCritical: ExitCritical,
+	statusUnknown:  ExitUnknown,
+}
+
+// statusSeverity orders statuses, the most severe one is the overall status
+var statusSeverity = map[string]int{
+	statusOK:       0,
+	statusWarning:  1,
+	statusUnknown:  2,
+	statusCritical: 3,
+}
+
+func Command() *cli.Command {
+	return &cli.Command{
+		Name:      CmdCheckExpiry,
+		HelpName:  CmdCheckExpiry,
+		Action:    Action,
+		ArgsUsage: `[file, directory or host:port...]`,
+		Usage:     `checks expiry of certificates.`,
+		Description: `Checks days remaining for every certificate in files, directories and
+chains sent by TLS servers. Exit code is 0 for OK, 1 for warning, 2 for critical
+and 3 for unknown like Nagios plugins.`,
+		Flags: Flags(),
+	}
+}
+
+func Flags() []cli.Flag {
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Name:  flagWarn,
+			Usage: "Warning threshold of remaining validity, in days (eg, 30d) or Go duration (eg, 72h)",
+			Value: "30d",
+		},
+		&cli.StringFlag{
+			Name:  flagCrit,
+			Usage: "Critical threshold of remaining validity, in days (eg, 7d) or Go duration (eg, 72h)",
+			Value: "7d",
+		},
+		&cli.StringFlag{
+			Name:        flagFormat,
+			Usage:       "Output format (text or json)",
+			DefaultText: formatText,
+			Value:       formatText,
+		},
+	}
+
+	return append(flags, probe.Flags()...)
+}
+
+// report is the JSON schema of check-expiry output
+type report struct {
+	Status  string         `json:"status"`
+	Targets []targetReport `json:"targets"`
+}
+
+type targetReport struct {
+	Target       string       `json:"target"`
+	Status       string       `json:"status"`
+	Error        string       `json:"error,omitempty"`
+	Certificates []certExpiry `json:"certificates"`
+}
+
+type certExpiry struct {
+	Source        string    `json:"source"`
+	Depth         int       `json:"depth"`
+	Subject       string    `json:"subject"`
+	Issuer        string    `json:"issuer"`
+	NotAfter      time.Time `json:"notAfter"`
+	DaysRemaining int       `json:"daysRemaining"`
+	Status        string    `json:"status"`
+}
+
+func Action(c *cli.Context) error {
+	if c.NArg() == 0 {
+		err := errors.New("no file, directory or host provided")
+		log.Printf("Failed to check expiry error: %v", err)
+		return err
+	}
+
+	warn, err := parseThreshold(c.String(flagWarn))
+	if err != nil {
+		log.Printf("Failed to parse warning threshold error: %v", err)
+		return err
+	}
+
+	crit, err := parseThreshold(c.String(flagCrit))
+	if err != nil {
+		log.Printf("Failed to parse critical threshold error: %v", err)
+		return err
+	}
+
+	if crit > warn {
+		err = fmt.Errorf("critical threshold %s is greater than warning threshold %s", c.String(flagCrit), c.String(flagWarn))
+		log.Printf("%v", err)
+		return err
+	}
+
+	format := c.String(flagFormat)
+	if format != formatText && format != formatJSON {
+		err = fmt.Errorf("unsupported format %q", format)
+		log.Printf("%v", err)
+		return err
+	}
+
+	opts, err := probe.OptionsFromContext(c, utils.StdinPasswordReader{Prompt: "Enter pass phrase: "})
+	if err != nil {
+		log.Printf("Failed to get connection options error: %v", err)
+		return err
+	}
+
+	now := time.Now()
+	r := report{Status: statusOK, Targets: []targetReport{}}
+	for _, target := range c.Args().Slice() {
+		t := checkTarget(target, opts, now, warn, crit)
+		r.Targets = append(r.Targets, t)
+		r.Status = worse(r.Status, t.Status)
+	}
+
+	if format == formatJSON {
+		err = writeJSON(c.App.Writer, r)
+	} else {
+		err = writeText(c.App.Writer, r)
+	}
+	if err != nil {
+		log.Printf("Failed to write report error: %v", err)
+		return err
+	}
+
+	if r.Status == statusOK {
+		return nil
+	}
+
+	return cli.Exit("", statusCodes[r.Status])
+}
+
+// parseThreshold parses days (eg, 30d) or a Go duration (eg, 72h)
+func parseThreshold(s string) (time.Duration, error) {
+	if days := strings.TrimSuffix(s, "d"); days != s {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid threshold %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid threshold %q", s)
+	}
+
+	return d, nil
+}
+
+// checkTarget checks certificates of a file, a directory or a TLS server
+func checkTarget(target string, opts *probe.Options, now time.Time, warn, crit time.Duration) targetReport {
+	t := targetReport{Target: target, Status: statusOK, Certificates: []certExpiry{}}
+
+	sources, err := readTarget(target, opts)
+	if err != nil {
+		t.Status = statusUnknown
+		t.Error = err.Error()
+		return t
+	}
+
+	for _, src := range sources {
+		for depth, cert := range src.certs {
+			e := newCertExpiry(src.name, depth, cert, now, warn, crit)
+			t.Certificates = append(t.Certificates, e)
+			t.Status = worse(t.Status, e.Status)
+		}
+	}
+
+	return t
+}
+
+// source is a file or a TLS server with certificates in chain order
+type source struct {
+	name  string
+	certs []*x509.Certificate
+}
+
+// readTarget reads certificates of a file, all files in a directory or a TLS server.
+// Files in directory which do not contain certificates are skipped.
+func readTarget(target string, opts *probe.Options) ([]source, error) {
+	info, err := os.Stat(target)
+	if err != nil {
+		certs, err := peerCertificates(target, opts)
+		if err != nil {
+			return nil, err
+		}
+		return []source{{name: target, certs: certs}}, nil
+	}
+
+	if !info.IsDir() {
+		certs, err := utils.CertsFromFile(target)
+		if err != nil {
+			return nil, err
+		}
+		return []source{{name: target, certs: certs}}, nil
+	}
+
+	var sources []source
+	err = filepath.WalkDir(target, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		certs, err := utils.CertsFromFile(path)
+		if err != nil {
+			return nil
+		}
+		sources = append(sources, source{name: path, certs: certs})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no certificate found in directory %q", target)
+	}
+
+	return sources, nil
+}
+
+// peerCertificates returns certificates sent by TLS server. Chain is not
+// verified, so expiry of untrusted and expired certificates can be checked.
+func peerCertificates(uri string, opts *probe.Options) ([]*x509.Certificate, error) {
+	addr, err := opts.HostPort(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := opts.Dial(addr, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate returned from %q", addr)
+	}
+
+	return certs, nil
+}
+
+func newCertExpiry(name string, depth int, cert *x509.Certificate, now time.Time, warn, crit time.Duration) certExpiry {
+	remaining := cert.NotAfter.Sub(now)
+
+	status := statusOK
+	switch {
+	case remaining <= crit:
+		status = statusCritical
+	case remaining <= warn:
+		status = statusWarning
+	}
+
+	return certExpiry{
+		Source:        name,
+		Depth:         depth,
+		Subject:       cert.Subject.String(),
+		Issuer:        cert.Issuer.String(),
+		NotAfter:      cert.NotAfter.UTC(),
+		DaysRemaining: int(math.Floor(remaining.Hours() / 24)),
+		Status:        status,
+	}
+}
+
+// worse returns the more severe status
+func worse(a, b string) string {
+	if statusSeverity[b] > statusSeverity[a] {
+		return b
+	}
+
+	return a
+}
+
+func writeJSON(w io.Writer, r report) error {
+	out, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "%s\n", out)
+	return err
+}
+
+// writeText writes a Nagios plugin output, a status line followed by a line for
+// each certificate or target error
+func writeText(w io.Writer, r report) error {
+	counts := map[string]int{}
+	var soonest *certExpiry
+	for _, t := range r.Targets {
+		if t.Error != "" {
+			counts[statusUnknown]++
+		}
+		for i, e := range t.Certificates {
+			counts[e.Status]++
+			if soonest == nil || e.NotAfter.Before(soonest.NotAfter) {
+				soonest = &t.Certificates[i]
+			}
+		}
+	}
+
+	summary := fmt.Sprintf("%d critical, %d warning, %d unknown, %d ok",
+		counts[statusCritical], counts[statusWarning], counts[statusUnknown], counts[statusOK])
+	if soonest != nil {
+		summary += fmt.Sprintf(", soonest %s %s", soonest.Subject, expiresIn(soonest.DaysRemaining))
+	}
+	if _, err := fmt.Fprintf(w, "EXPIRY %s - %s\n", r.Status, summary); err != nil {
+		return err
+	}
+
+	for _, t := range r.Targets {
+		if t.Error != "" {
+			if _, err := fmt.Fprintf(w, "%s: %s: %s\n", statusUnknown, t.Target, t.Error); err != nil {
+				return err
+			}
+		}
+
+		for _, e := range t.Certificates {
+			_, err := fmt.Fprintf(w, "%s: %s [%d] %s %s (%s)\n",
+				e.Status, e.Source, e.Depth, e.Subject, expiresIn(e.DaysRemaining), e.NotAfter.Format(time.RFC3339))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func expiresIn(days int) string {
+	if days < 0 {
+		return fmt.Sprintf("expired %d days ago", -days)
+	}
+
+	return fmt.Sprintf("expires in %d days", days)
+}
@@ -0,0 +1,240 @@
+package check_expiry_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yakuter/gossl/commands/check_expiry"
+	"github.com/yakuter/gossl/pkg/utils"
+
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+// newCert returns a self-signed certificate and key valid until notAfter
+func newCert(t *testing.T, cn string, notAfter time.Time) (*x509.Certificate, tls.Certificate) {
+	t.Helper()
+
+	key, err := utils.GenerateKey(utils.KeyTypeECDSA, 0, "P-256")
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return cert, tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func writeCert(t *testing.T, path string, certs ...*x509.Certificate) string {
+	t.Helper()
+
+	var data []byte
+	for _, cert := range certs {
+		data = append(data, utils.CertToPEM(cert.Raw)...)
+	}
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+
+	return path
+}
+
+func TestCheckExpiry(t *testing.T) {
+	execName, err := os.Executable()
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	app := &cli.App{
+		Writer:         &out,
+		ExitErrHandler: func(*cli.Context, error) {},
+		Commands: []*cli.Command{
+			check_expiry.Command(),
+		},
+	}
+
+	day := 24 * time.Hour
+	now := time.Now()
+	ok, _ := newCert(t, "ok", now.Add(365*day+time.Hour))
+	warning, _ := newCert(t, "warning", now.Add(20*day+time.Hour))
+	critical, _ := newCert(t, "critical", now.Add(3*day+time.Hour))
+	expired, _ := newCert(t, "expired", now.Add(-2*day+time.Hour))
+
+	dir := t.TempDir()
+	okFile := writeCert(t, filepath.Join(dir, "ok.pem"), ok)
+	warningFile := writeCert(t, filepath.Join(dir, "warning.pem"), warning)
+	criticalFile := writeCert(t, filepath.Join(t.TempDir(), "critical.pem"), critical)
+	expiredFile := writeCert(t, filepath.Join(t.TempDir(), "expired.pem"), expired)
+	chainFile := writeCert(t, filepath.Join(t.TempDir(), "chain.pem"), ok, warning)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README"), []byte("not a cert"), 0o600))
+
+	// Server sending certificate expiring in 3 days
+	_, serverCert := newCert(t, "server", now.Add(3*day+time.Hour))
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{serverCert}})
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	testCases := []struct {
+		name     string
+		args     []string
+		exitCode int
+		output   []string
+	}{
+		{
+			name:     "ok",
+			args:     []string{okFile},
+			exitCode: check_expiry.ExitOK,
+			output:   []string{"EXPIRY OK - 0 critical, 0 warning, 0 unknown, 1 ok, soonest CN=ok expires in 365 days\n"},
+		},
+		{
+			name:     "warning in directory",
+			args:     []string{dir},
+			exitCode: check_expiry.ExitWarning,
+			output: []string{
+				"EXPIRY WARNING - 0 critical, 1 warning, 0 unknown, 1 ok, soonest CN=warning expires in 20 days\n",
+				"OK: " + okFile + " [0] CN=ok expires in 365 days",
+				"WARNING: " + warningFile + " [0] CN=warning expires in 20 days",
+			},
+		},
+		{
+			name:     "critical",
+			args:     []string{okFile, criticalFile},
+			exitCode: check_expiry.ExitCritical,
+			output:   []string{"EXPIRY CRITICAL - 1 critical, 0 warning, 0 unknown, 1 ok"},
+		},
+		{
+			name:     "expired",
+			args:     []string{expiredFile},
+			exitCode: check_expiry.ExitCritical,
+			output:   []string{"CRITICAL: " + expiredFile + " [0] CN=expired expired 2 days ago"},
+		},
+		{
+			name:     "custom thresholds",
+			args:     []string{"--warn", "2d", "--crit", "24h", criticalFile},
+			exitCode: check_expiry.ExitOK,
+		},
+		{
+			name:     "every certificate in chain",
+			args:     []string{chainFile},
+			exitCode: check_expiry.ExitWarning,
+			output:   []string{"[0] CN=ok", "[1] CN=warning"},
+		},
+		{
+			name:     "TLS server",
+			args:     []string{ln.Addr().String()},
+			exitCode: check_expiry.ExitCritical,
+			output:   []string{"CRITICAL: " + ln.Addr().String() + " [0] CN=server expires in 3 days"},
+		},
+		{
+			name:     "unknown when server is not reachable",
+			args:     []string{"--timeout", "1s", okFile, "127.0.0.1:1"},
+			exitCode: check_expiry.ExitUnknown,
+			output:   []string{"EXPIRY UNKNOWN - 0 critical, 0 warning, 1 unknown, 1 ok", "UNKNOWN: 127.0.0.1:1: "},
+		},
+		{
+			name:     "critical is worse than unknown",
+			args:     []string{criticalFile, "127.0.0.1:1"},
+			exitCode: check_expiry.ExitCritical,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.name, func(t *testing.T) {
+			out.Reset()
+			err := app.Run(append([]string{execName, check_expiry.CmdCheckExpiry}, tC.args...))
+			if tC.exitCode == check_expiry.ExitOK {
+				require.NoError(t, err)
+			} else {
+				var exitErr cli.ExitCoder
+				require.True(t, errors.As(err, &exitErr))
+				require.Equal(t, tC.exitCode, exitErr.ExitCode())
+			}
+
+			for _, output := range tC.output {
+				require.Contains(t, out.String(), output)
+			}
+		})
+	}
+
+	t.Run("json", func(t *testing.T) {
+		out.Reset()
+		err := app.Run([]string{execName, check_expiry.CmdCheckExpiry, "--format", "json", chainFile, "127.0.0.1:1"})
+		require.Error(t, err)
+
+		var got struct {
+			Status  string `json:"status"`
+			Targets []struct {
+				Target       string `json:"target"`
+				Status       string `json:"status"`
+				Error        string `json:"error"`
+				Certificates []struct {
+					Depth         int       `json:"depth"`
+					Subject       string    `json:"subject"`
+					NotAfter      time.Time `json:"notAfter"`
+					DaysRemaining int       `json:"daysRemaining"`
+					Status        string    `json:"status"`
+				} `json:"certificates"`
+			} `json:"targets"`
+		}
+		require.NoError(t, json.Unmarshal(out.Bytes(), &got))
+
+		require.Equal(t, "UNKNOWN", got.Status)
+		require.Len(t, got.Targets, 2)
+		require.Equal(t, chainFile, got.Targets[0].Target)
+		require.Equal(t, "WARNING", got.Targets[0].Status)
+		require.Len(t, got.Targets[0].Certificates, 2)
+		require.Equal(t, 1, got.Targets[0].Certificates[1].Depth)
+		require.Equal(t, "CN=warning", got.Targets[0].Certificates[1].Subject)
+		require.Equal(t, 20, got.Targets[0].Certificates[1].DaysRemaining)
+		require.True(t, warning.NotAfter.Equal(got.Targets[0].Certificates[1].NotAfter))
+		require.Equal(t, "UNKNOWN", got.Targets[1].Status)
+		require.NotEmpty(t, got.Targets[1].Error)
+		require.Empty(t, got.Targets[1].Certificates)
+	})
+
+	errorCases := map[string][]string{
+		"no target error":             {},
+		"invalid threshold error":     {"--warn", "30days", okFile},
+		"critical over warning error": {"--warn", "7d", "--crit", "30d", okFile},
+		"unsupported format error":    {"--format", "xml", okFile},
+	}
+	for name, args := range errorCases {
+		t.Run(name, func(t *testing.T) {
+			out.Reset()
+			err := app.Run(append([]string{execName, check_expiry.CmdCheckExpiry}, args...))
+			require.Error(t, err)
+
+			// Usage errors are not check results
+			var exitErr cli.ExitCoder
+			require.False(t, errors.As(err, &exitErr))
+			require.Empty(t, out.String())
+		})
+	}
+}
@@ -0,0 +1,450 @@
+package convert
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yakuter/gossl/pkg/utils"
+
+	"github.com/urfave/cli/v2"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+const (
+	CmdConvert = "convert"
+
+	flagIn       = "in"
+	flagOut      = "out"
+	flagInform   = "inform"
+	flagOutform  = "outform"
+	flagKey      = "key"
+	flagPassword = "password"
+	flagLegacy   = "legacy"
+)
+
+// Supported file formats
+const (
+	formatPEM = "pem"
+	formatDER = "der"
+	formatP7B = "p7b"
+	formatP12 = "p12"
+)
+
+func Command(reader utils.PasswordReader) *cli.Command {
+	return &cli.Command{
+		Name:        CmdConvert,
+		HelpName:    CmdConvert,
+		Action:      Action(reader),
+		ArgsUsage:   ` `,
+		Usage:       `converts certificates, CSRs, keys and CRLs between PEM, DER, PKCS#7 and PKCS#12.`,
+		Description: `Converts certificates, CSRs, private and public keys and CRLs between PEM and DER formats, builds and unpacks PKCS#7 (.p7b) certificate bundles, creates and extracts PKCS#12 (.p12, .pfx) files.`,
+		Flags:       Flags(),
+	}
+}
+
+func Flags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        flagIn,
+			Usage:       "Input file path",
+			DefaultText: "eg, ./cert.pem",
+			Required:    true,
+		},
+		&cli.StringFlag{
+			Name:        flagOut,
+			Usage:       "Output file path",
+			DefaultText: "eg, ./cert.der",
+			Required:    true,
+		},
+		&cli.StringFlag{
+			Name:        flagInform,
+			Usage:       "Input format (pem, der, p7b or p12)",
+			DefaultText: "detected from input file",
+			Required:    false,
+		},
+		&cli.StringFlag{
+			Name:        flagOutform,
+			Usage:       "Output format (pem, der, p7b or p12)",
+			DefaultText: "detected from output file extension",
+			Required:    false,
+		},
+		&cli.StringFlag{
+			Name:        flagKey,
+			Usage:       "Private key file path to add to PKCS#12 output (optional)",
+			DefaultText: "eg, ./key.pem",
+			Required:    false,
+		},
+		&cli.StringFlag{
+			Name:     flagPassword,
+			Usage:    "Pass phrase of PKCS#12 file or encrypted private key (optional)",
+			Required: false,
+		},
+		&cli.BoolFlag{
+			Name:     flagLegacy,
+			Usage:    "Encrypt PKCS#12 output with legacy 3DES for older Windows and Java versions",
+			Required: false,
+		},
+	}
+}
+
+func Action(reader utils.PasswordReader) func(*cli.Context) error {
+	return func(c *cli.Context) error {
+		// Flag takes precedence over password prompt
+		if c.IsSet(flagPassword) {
+			reader = utils.StaticPasswordReader{Password: c.String(flagPassword)}
+		}
+
+		in := c.String(flagIn)
+		data, err := os.ReadFile(in)
+		if err != nil {
+			log.Printf("Failed to read input file %q error: %v", in, err)
+			return err
+		}
+
+		inform := strings.ToLower(c.String(flagInform))
+		if inform == "" {
+			inform = detectFormat(in, data)
+		}
+
+		blocks, err := decode(inform, data, reader)
+		if err != nil {
+			log.Printf("Failed to read %s file %q error: %v", inform, in, err)
+			return err
+		}
+
+		if c.IsSet(flagKey) {
+			keyBlocks, err := utils.PEMBlocksFromFile(c.String(flagKey))
+			if err != nil {
+				return err
+			}
+			blocks = append(blocks, keyBlocks...)
+		}
+
+		out := c.String(flagOut)
+		outform := strings.ToLower(c.String(flagOutform))
+		if outform == "" {
+			outform = formatFromExt(out)
+			if outform == "" {
+				err = fmt.Errorf("failed to detect output format of %q, please provide outform flag", out)
+				log.Printf("%v", err)
+				return err
+			}
+		}
+
+		outData, err := encode(outform, blocks, reader, c.Bool(flagLegacy))
+		if err != nil {
+			log.Printf("Failed to convert to %s error: %v", outform, err)
+			return err
+		}
+
+		// Output may contain private key
+		if err = os.WriteFile(out, outData, 0o600); err != nil {
+			log.Printf("Failed to write output file %q error: %v", out, err)
+			return err
+		}
+
+		log.Printf("Converted %s (%s) to %s (%s)", in, inform, out, outform)
+		return nil
+	}
+}
+
+// formatFromExt returns format of file from its extension or empty string if it is unknown
+func formatFromExt(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".pem":
+		return formatPEM
+	case ".der", ".cer":
+		return formatDER
+	case ".p7b", ".p7c":
+		return formatP7B
+	case ".p12", ".pfx":
+		return formatP12
+	default:
+		return ""
+	}
+}
+
+// detectFormat returns format of input file from its extension or content
+func detectFormat(path string, data []byte) string {
+	if format := formatFromExt(path); format == formatP7B || format == formatP12 {
+		return format
+	}
+
+	switch {
+	case bytes.Contains(data, []byte("-----BEGIN PKCS7-----")):
+		return formatP7B
+	case bytes.Contains(data, []byte("-----BEGIN")):
+		return formatPEM
+	}
+
+	if _, _, err := utils.DecodePKCS7(data); err == nil {
+		return formatP7B
+	}
+
+	return formatDER
+}
+
+// decode reads input data in provided format and returns its objects as PEM blocks
+func decode(format string, data []byte, reader utils.PasswordReader) ([]*pem.Block, error) {
+	switch format {
+	case formatPEM:
+		var blocks []*pem.Block
+		for {
+			var block *pem.Block
+			block, data = pem.Decode(data)
+			if block == nil {
+				break
+			}
+
+			// PKCS#7 bundles are unpacked in PEM files too
+			if block.Type == "PKCS7" {
+				p7Blocks, err := decodePKCS7(block.Bytes)
+				if err != nil {
+					return nil, err
+				}
+				blocks = append(blocks, p7Blocks...)
+				continue
+			}
+			blocks = append(blocks, block)
+		}
+		if len(blocks) == 0 {
+			return nil, errors.New("no PEM block found")
+		}
+		return blocks, nil
+
+	case formatDER:
+		block, err := derToPEM(data)
+		if err != nil {
+			return nil, err
+		}
+		return []*pem.Block{block}, nil
+
+	case formatP7B:
+		if block, _ := pem.Decode(data); block != nil {
+			data = block.Bytes
+		}
+		return decodePKCS7(data)
+
+	case formatP12:
+		return decodePKCS12(data, reader)
+
+	default:
+		return nil, fmt.Errorf("unsupported input format %q", format)
+	}
+}
+
+// encode writes PEM blocks in provided format
+func encode(format string, blocks []*pem.Block, reader utils.PasswordReader, legacy bool) ([]byte, error) {
+	switch format {
+	case formatPEM:
+		var out []byte
+		for _, block := range blocks {
+			out = append(out, pem.EncodeToMemory(block)...)
+		}
+		return out, nil
+
+	case formatDER:
+		if len(blocks) != 1 {
+			return nil, fmt.Errorf("DER format holds a single object but input has %d, use p7b or p12 for bundles", len(blocks))
+		}
+		return blocks[0].Bytes, nil
+
+	case formatP7B:
+		var (
+			certs []*x509.Certificate
+			crls  [][]byte
+		)
+		for _, block := range blocks {
+			switch block.Type {
+			case "CERTIFICATE":
+				cert, err := x509.ParseCertificate(block.Bytes)
+				if err != nil {
+					return nil, err
+				}
+				certs = append(certs, cert)
+			case "X509 CRL":
+				crls = append(crls, block.Bytes)
+			case "PRIVATE KEY", "ENCRYPTED PRIVATE KEY", "RSA PRIVATE KEY", "EC PRIVATE KEY":
+				log.Printf("Private key is skipped, PKCS#7 holds only certificates and CRLs")
+			default:
+				return nil, fmt.Errorf("%s can not be added to PKCS#7", strings.ToLower(block.Type))
+			}
+		}
+		return utils.EncodePKCS7(certs, crls)
+
+	case formatP12:
+		return encodePKCS12(blocks, reader, legacy)
+
+	default:
+		return nil, fmt.Errorf("unsupported output format %q", format)
+	}
+}
+
+// derToPEM detects type of DER encoded object and returns it as PEM block
+func derToPEM(der []byte) (*pem.Block, error) {
+	blockType := ""
+	switch {
+	case isParsed(x509.ParseCertificate(der)):
+		blockType = "CERTIFICATE"
+	case isParsed(x509.ParseCertificateRequest(der)):
+		blockType = "CERTIFICATE REQUEST"
+	case isParsed(x509.ParseDERCRL(der)):
+		blockType = "X509 CRL"
+	case isParsed(x509.ParsePKCS8PrivateKey(der)):
+		blockType = "PRIVATE KEY"
+	case isParsed(x509.ParsePKCS1PrivateKey(der)):
+		blockType = "RSA PRIVATE KEY"
+	case isParsed(x509.ParseECPrivateKey(der)):
+		blockType = "EC PRIVATE KEY"
+	case isParsed(x509.ParsePKIXPublicKey(der)):
+		blockType = "PUBLIC KEY"
+	case isParsed(x509.ParsePKCS1PublicKey(der)):
+		blockType = "RSA PUBLIC KEY"
+	default:
+		return nil, errors.New("unsupported DER encoded data")
+	}
+
+	return &pem.Block{Type: blockType, Bytes: der}, nil
+}
+
+func isParsed(_ interface{}, err error) bool {
+	return err == nil
+}
+
+// decodePKCS7 returns certificates and CRLs of DER encoded PKCS#7 bundle as PEM blocks
+func decodePKCS7(der []byte) ([]*pem.Block, error) {
+	certs, crls, err := utils.DecodePKCS7(der)
+	if err != nil {
+		return nil, err
+	}
+
+	var blocks []*pem.Block
+	for _, cert := range certs {
+		blocks = append(blocks, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	}
+	for _, crl := range crls {
+		blocks = append(blocks, &pem.Block{Type: "X509 CRL", Bytes: crl})
+	}
+
+	if len(blocks) == 0 {
+		return nil, errors.New("PKCS#7 bundle is empty")
+	}
+
+	return blocks, nil
+}
+
+// decodePKCS12 returns certificates and private key of PKCS#12 file as PEM blocks.
+// PKCS#12 files without private key (trust stores) are supported too.
+func decodePKCS12(data []byte, reader utils.PasswordReader) ([]*pem.Block, error) {
+	pwd, err := reader.ReadPassword()
+	if err != nil {
+		log.Printf("Failed to read pass phrase error: %v", err)
+		return nil, err
+	}
+
+	key, cert, caCerts, err := pkcs12.DecodeChain(data, pwd)
+	if err != nil {
+		if errors.Is(err, pkcs12.ErrIncorrectPassword) {
+			return nil, err
+		}
+
+		// Trust stores contain only certificates
+		certs, trustErr := pkcs12.DecodeTrustStore(data, pwd)
+		if trustErr != nil {
+			return nil, err
+		}
+
+		var blocks []*pem.Block
+		for _, cert := range certs {
+			blocks = append(blocks, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+		}
+		return blocks, nil
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := []*pem.Block{
+		{Type: "PRIVATE KEY", Bytes: keyDER},
+		{Type: "CERTIFICATE", Bytes: cert.Raw},
+	}
+	for _, caCert := range caCerts {
+		blocks = append(blocks, &pem.Block{Type: "CERTIFICATE", Bytes: caCert.Raw})
+	}
+
+	return blocks, nil
+}
+
+// encodePKCS12 creates PKCS#12 file from a private key and its certificate chain.
+// Without private key, a trust store of certificates is created.
+func encodePKCS12(blocks []*pem.Block, reader utils.PasswordReader, legacy bool) ([]byte, error) {
+	var (
+		key   crypto.Signer
+		certs []*x509.Certificate
+	)
+
+	for _, block := range blocks {
+		switch {
+		case block.Type == "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			certs = append(certs, cert)
+		case strings.HasSuffix(block.Type, "PRIVATE KEY"):
+			if key != nil {
+				return nil, errors.New("PKCS#12 can hold only one private key")
+			}
+			var err error
+			if key, err = utils.PrivateKeyFromPEM(block, reader); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("%s can not be added to PKCS#12", strings.ToLower(block.Type))
+		}
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New("PKCS#12 requires at least one certificate")
+	}
+
+	pwd, err := reader.ReadPassword()
+	if err != nil {
+		log.Printf("Failed to read pass phrase error: %v", err)
+		return nil, err
+	}
+
+	encoder := pkcs12.Modern
+	if legacy {
+		encoder = pkcs12.Legacy
+	}
+
+	if key == nil {
+		return encoder.EncodeTrustStore(certs, pwd)
+	}
+
+	// Certificate of private key is the leaf, others are CA certificates
+	leaf := -1
+	for i := range certs {
+		if pub, ok := key.Public().(interface{ Equal(crypto.PublicKey) bool }); ok && pub.Equal(certs[i].PublicKey) {
+			leaf = i
+			break
+		}
+	}
+	if leaf < 0 {
+		return nil, errors.New("no certificate matches the private key")
+	}
+
+	caCerts := append(append([]*x509.Certificate{}, certs[:leaf]...), certs[leaf+1:]...)
+	return encoder.Encode(key, certs[leaf], caCerts, pwd)
+}
@@ -0,0 +1,195 @@
+package convert_test
+
+import (
+	"crypto"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/yakuter/gossl/commands/convert"
+	"github.com/yakuter/gossl/pkg/utils"
+
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func TestConvert(t *testing.T) {
+	const (
+		certFile   = "../../testdata/server-cert.pem"
+		keyFile    = "../../testdata/server-key.pem"
+		caCertFile = "../../testdata/ca-cert.pem"
+		caKeyFile  = "../../testdata/ca-key.pem"
+		caKeyEnc   = "../../testdata/ca-key-encrypted.pem"
+		csrFile    = "../../testdata/server-req.pem"
+	)
+
+	app := &cli.App{
+		Commands: []*cli.Command{
+			convert.Command(utils.StaticPasswordReader{Password: "s3cret"}),
+		},
+	}
+
+	execName, err := os.Executable()
+	require.NoError(t, err)
+
+	tempDir := t.TempDir()
+
+	// Certificate chain of server and CA
+	chainFile := filepath.Join(tempDir, "chain.pem")
+	serverPEM, err := os.ReadFile(certFile)
+	require.NoError(t, err)
+	caPEM, err := os.ReadFile(caCertFile)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(chainFile, append(append([]byte{}, serverPEM...), caPEM...), 0o600))
+
+	run := func(args ...string) error {
+		return app.Run(append([]string{execName, convert.CmdConvert}, args...))
+	}
+
+	testCases := []struct {
+		name      string
+		in        string
+		key       string
+		outform   string
+		back      string // converted back to PEM and compared with this file
+		password  string
+		legacy    bool
+		shouldErr bool
+	}{
+		{
+			name:    "certificate to DER",
+			in:      certFile,
+			outform: "der",
+			back:    certFile,
+		},
+		{
+			name:    "CSR to DER",
+			in:      csrFile,
+			outform: "der",
+			back:    csrFile,
+		},
+		{
+			name:    "private key to DER",
+			in:      caKeyFile,
+			outform: "der",
+			back:    caKeyFile,
+		},
+		{
+			name:    "certificate chain to PKCS#7",
+			in:      chainFile,
+			outform: "p7b",
+			back:    chainFile,
+		},
+		{
+			name:    "certificate chain and key to PKCS#12",
+			in:      chainFile,
+			key:     keyFile,
+			outform: "p12",
+		},
+		{
+			name:    "certificate chain and key to legacy PKCS#12",
+			in:      chainFile,
+			key:     keyFile,
+			outform: "p12",
+			legacy:  true,
+		},
+		{
+			name:     "encrypted CA key to PKCS#12",
+			in:       caCertFile,
+			key:      caKeyEnc,
+			outform:  "p12",
+			password: "gossl",
+		},
+		{
+			name:    "certificate chain to PKCS#12 trust store",
+			in:      chainFile,
+			outform: "p12",
+			back:    chainFile,
+		},
+		{
+			name:      "certificate chain to DER error",
+			in:        chainFile,
+			outform:   "der",
+			shouldErr: true,
+		},
+		{
+			name:      "CSR to PKCS#7 error",
+			in:        csrFile,
+			outform:   "p7b",
+			shouldErr: true,
+		},
+		{
+			name:      "key without matching certificate to PKCS#12 error",
+			in:        caCertFile,
+			key:       keyFile,
+			outform:   "p12",
+			shouldErr: true,
+		},
+		{
+			name:      "unknown output format error",
+			in:        certFile,
+			outform:   "txt",
+			shouldErr: true,
+		},
+		{
+			name:      "missing input file error",
+			in:        "wrong-file",
+			outform:   "der",
+			shouldErr: true,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.name, func(t *testing.T) {
+			outFile := filepath.Join(tempDir, "out."+tC.outform)
+			testArgs := []string{"--in", tC.in, "--out", outFile}
+			if tC.key != "" {
+				testArgs = append(testArgs, "--key", tC.key)
+			}
+			if tC.password != "" {
+				testArgs = append(testArgs, "--password", tC.password)
+			}
+			if tC.legacy {
+				testArgs = append(testArgs, "--legacy")
+			}
+
+			if tC.shouldErr {
+				require.Error(t, run(testArgs...))
+				return
+			}
+			require.NoError(t, run(testArgs...))
+
+			// Convert back to PEM with detected input format
+			backFile := filepath.Join(tempDir, "back.pem")
+			backArgs := []string{"--in", outFile, "--out", backFile}
+			if tC.password != "" {
+				backArgs = append(backArgs, "--password", tC.password)
+			}
+			require.NoError(t, run(backArgs...))
+
+			if tC.back != "" {
+				want, err := os.ReadFile(tC.back)
+				require.NoError(t, err)
+				got, err := os.ReadFile(backFile)
+				require.NoError(t, err)
+				require.Equal(t, string(want), string(got))
+			}
+
+			// Private key added to PKCS#12 must be extracted
+			if tC.key != "" {
+				key, err := utils.PrivateKeyFromFile(backFile)
+				require.NoError(t, err)
+
+				wantKey, err := utils.PrivateKeyFromFileWithPassword(tC.key, utils.StaticPasswordReader{Password: tC.password})
+				require.NoError(t, err)
+				require.True(t, wantKey.Public().(interface{ Equal(crypto.PublicKey) bool }).Equal(key.Public()))
+			}
+		})
+	}
+
+	t.Run("wrong PKCS#12 password error", func(t *testing.T) {
+		p12File := filepath.Join(tempDir, "wrong.p12")
+		require.NoError(t, run("--in", chainFile, "--key", keyFile, "--out", p12File))
+		require.Error(t, run("--in", p12File, "--out", filepath.Join(tempDir, "wrong.pem"), "--password", "wrong"))
+	})
+}
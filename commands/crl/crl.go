@@ -0,0 +1,400 @@
+package crl
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/yakuter/gossl/pkg/utils"
+
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	CmdCRL = "crl"
+
+	flagCACert     = "cacert"
+	flagCAKey      = "cakey"
+	flagPassword   = "password"
+	flagIn         = "in"
+	flagRevoke     = "revoke"
+	flagRevokeFile = "revokeFile"
+	flagRemove     = "remove"
+	flagDays       = "days"
+	flagHours      = "hours"
+	flagCRLNumber  = "crlNumber"
+	flagOut        = "out"
+)
+
+func Command() *cli.Command {
+	return &cli.Command{
+		Name:      CmdCRL,
+		HelpName:  CmdCRL,
+		Action:    Action,
+		ArgsUsage: ` `,
+		Usage:     `generates and updates certificate revocation lists (CRL).`,
+		Description: `Generates a CRL signed by CA cert and key from revoked serial numbers with
+revocation reasons and dates. An existing CRL can be updated by adding or
+removing entries, its CRL number is incremented.`,
+		Flags: Flags(),
+	}
+}
+
+func Flags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        flagCACert,
+			Usage:       "CA cert file to sign CRL with (required)",
+			DefaultText: "eg, ./ca.pem",
+			Required:    true,
+		},
+		&cli.StringFlag{
+			Name:        flagCAKey,
+			Usage:       "CA private key file to sign CRL with (required)",
+			DefaultText: "eg, ./ca.key",
+			Required:    true,
+		},
+		&cli.StringFlag{
+			Name:     flagPassword,
+			Usage:    "Pass phrase of encrypted CA key, asked if not set (optional)",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:        flagIn,
+			Usage:       "Existing CRL to update, its entries are kept (optional)",
+			DefaultText: "eg, ./ca.crl",
+			Required:    false,
+		},
+		&cli.StringSliceFlag{
+			Name:        flagRevoke,
+			Usage:       "Revoked serial as serial[:reason[:date]], serial is decimal or 0x prefixed hex, date is YYYY-MM-DD or RFC 3339, can be repeated (optional)",
+			DefaultText: "eg, 0x1f:keyCompromise:2024-01-31",
+			Required:    false,
+		},
+		&cli.StringFlag{
+			Name:        flagRevokeFile,
+			Usage:       "File with a revoked serial per line in revoke flag format, empty lines and # comments are skipped (optional)",
+			DefaultText: "eg, ./revoked.txt",
+			Required:    false,
+		},
+		&cli.StringSliceFlag{
+			Name:        flagRemove,
+			Usage:       "Serial to remove from CRL, eg, a certificate on hold, can be repeated (optional)",
+			DefaultText: "eg, 0x1f",
+			Required:    false,
+		},
+		&cli.UintFlag{
+			Name:        flagDays,
+			Usage:       "Number of days until next CRL update",
+			DefaultText: "30",
+			Value:       30,
+			Required:    false,
+		},
+		&cli.UintFlag{
+			Name:     flagHours,
+			Usage:    "Number of hours added to days until next CRL update (optional)",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:        flagCRLNumber,
+			Usage:       "File holding the next CRL number in hex, created if missing and incremented after CRL is generated (optional)",
+			DefaultText: "eg, ./crlnumber",
+			Required:    false,
+		},
+		&cli.StringFlag{
+			Name:        flagOut,
+			Usage:       "Output file name (optional)",
+			DefaultText: "eg, ./ca.crl",
+			Required:    false,
+		},
+	}
+}
+
+// revocation is a revoked certificate entry provided with flags
+type revocation struct {
+	serial *big.Int
+	reason int
+	date   time.Time
+}
+
+func Action(c *cli.Context) error {
+	// Set output
+	output := os.Stdout
+	outputFilePath := output.Name()
+	if c.IsSet(flagOut) {
+		outputFilePath = c.String(flagOut)
+	}
+
+	nextUpdate := time.Duration(c.Uint(flagDays))*24*time.Hour + time.Duration(c.Uint(flagHours))*time.Hour
+	if nextUpdate <= 0 {
+		err := errors.New("next update must be after this update, set days or hours")
+		log.Printf("%v", err)
+		return err
+	}
+
+	caCert, err := utils.CertFromFile(c.String(flagCACert))
+	if err != nil {
+		log.Printf("Failed to get CA cert from file %s error: %v", c.String(flagCACert), err)
+		return err
+	}
+
+	// Signing CRLs requires the cRLSign key usage on CA cert
+	if caCert.KeyUsage&x509.KeyUsageCRLSign == 0 {
+		err = fmt.Errorf("CA cert %s does not have the cRLSign key usage", c.String(flagCACert))
+		log.Printf("%v", err)
+		return err
+	}
+
+	caKey, err := utils.PrivateKeyFromFileWithPassword(c.String(flagCAKey), utils.KeyPasswordReader(c, flagPassword, c.String(flagCAKey)))
+	if err != nil {
+		log.Printf("Failed to get CA key from file %s error: %v", c.String(flagCAKey), err)
+		return err
+	}
+
+	now := time.Now()
+	revocations, err := readRevocations(c, now)
+	if err != nil {
+		log.Printf("Failed to read revoked serials error: %v", err)
+		return err
+	}
+
+	removed, err := parseSerials(c.StringSlice(flagRemove))
+	if err != nil {
+		log.Printf("Failed to parse removed serials error: %v", err)
+		return err
+	}
+
+	// Entries and number of the existing CRL are kept
+	var (
+		revoked []pkix.RevokedCertificate
+		number  = big.NewInt(1)
+	)
+	if c.IsSet(flagIn) {
+		existing, err := utils.CRLFromFile(c.String(flagIn))
+		if err != nil {
+			log.Printf("Failed to get CRL from file %s error: %v", c.String(flagIn), err)
+			return err
+		}
+
+		if err = caCert.CheckCRLSignature(existing); err != nil {
+			log.Printf("CRL %s is not issued by CA error: %v", c.String(flagIn), err)
+			return err
+		}
+
+		revoked = existing.TBSCertList.RevokedCertificates
+		if n := utils.CRLNumber(existing); n != nil {
+			number.Add(n, big.NewInt(1))
+		}
+	}
+
+	if c.IsSet(flagCRLNumber) {
+		n, err := readCRLNumber(c.String(flagCRLNumber))
+		if err != nil {
+			log.Printf("Failed to read CRL number from file %s error: %v", c.String(flagCRLNumber), err)
+			return err
+		}
+		if n.Cmp(number) > 0 {
+			number = n
+		}
+	}
+
+	revoked, err = updateEntries(revoked, revocations, removed)
+	if err != nil {
+		log.Printf("Failed to update revoked certificates error: %v", err)
+		return err
+	}
+
+	template := &x509.RevocationList{
+		RevokedCertificates: revoked,
+		Number:              number,
+		ThisUpdate:          now,
+		NextUpdate:          now.Add(nextUpdate),
+	}
+
+	der, err := x509.CreateRevocationList(rand.Reader, template, caCert, caKey)
+	if err != nil {
+		log.Printf("Failed to create CRL error: %v", err)
+		return err
+	}
+
+	// Write CRL to file
+	if err = os.WriteFile(outputFilePath, utils.CRLToPEM(der), 0o600); err != nil {
+		log.Printf("Failed to write PEM to file %s error: %v", outputFilePath, err)
+		return err
+	}
+
+	// Next CRL number is saved only after CRL is written
+	if c.IsSet(flagCRLNumber) {
+		next := new(big.Int).Add(number, big.NewInt(1))
+		if err = os.WriteFile(c.String(flagCRLNumber), []byte(fmt.Sprintf("%X\n", next)), 0o600); err != nil {
+			log.Printf("Failed to write CRL number to file %s error: %v", c.String(flagCRLNumber), err)
+			return err
+		}
+	}
+
+	log.Printf("CRL %d generated with %d revoked certificates", number, len(revoked))
+	return nil
+}
+
+// readRevocations returns revoked certificate entries in revoke flags and revoke file.
+// Entries without a date are revoked at now.
+func readRevocations(c *cli.Context, now time.Time) ([]revocation, error) {
+	entries := c.StringSlice(flagRevoke)
+
+	if c.IsSet(flagRevokeFile) {
+		f, err := os.Open(c.String(flagRevokeFile))
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if i := strings.Index(line, "#"); i >= 0 {
+				line = strings.TrimSpace(line[:i])
+			}
+			if line != "" {
+				entries = append(entries, line)
+			}
+		}
+		if err = scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	revocations := make([]revocation, 0, len(entries))
+	for _, entry := range entries {
+		r, err := parseRevocation(entry, now)
+		if err != nil {
+			return nil, err
+		}
+		revocations = append(revocations, r)
+	}
+
+	return revocations, nil
+}
+
+// parseRevocation parses serial[:reason[:date]]
+func parseRevocation(s string, now time.Time) (revocation, error) {
+	parts := strings.SplitN(s, ":", 3)
+
+	serial, err := parseSerial(parts[0])
+	if err != nil {
+		return revocation{}, err
+	}
+	r := revocation{serial: serial, reason: -1, date: now}
+
+	if len(parts) > 1 && parts[1] != "" {
+		reason, ok := utils.CRLReasons[parts[1]]
+		if !ok {
+			return revocation{}, fmt.Errorf("unknown revocation reason %q", parts[1])
+		}
+		r.reason = reason
+	}
+
+	if len(parts) > 2 && parts[2] != "" {
+		if r.date, err = parseDate(parts[2]); err != nil {
+			return revocation{}, err
+		}
+	}
+
+	return r, nil
+}
+
+// parseSerial parses a decimal or 0x prefixed hex serial number
+func parseSerial(s string) (*big.Int, error) {
+	serial, ok := new(big.Int).SetString(s, 0)
+	if !ok || serial.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid serial number %q", s)
+	}
+
+	return serial, nil
+}
+
+func parseSerials(values []string) ([]*big.Int, error) {
+	serials := make([]*big.Int, 0, len(values))
+	for _, s := range values {
+		serial, err := parseSerial(s)
+		if err != nil {
+			return nil, err
+		}
+		serials = append(serials, serial)
+	}
+
+	return serials, nil
+}
+
+// parseDate parses a date (YYYY-MM-DD) in UTC or an RFC 3339 time
+func parseDate(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid revocation date %q", s)
+	}
+
+	return t, nil
+}
+
+// updateEntries removes serials in removed from revoked and adds revocations.
+// An entry of a serial which is already revoked is replaced.
+func updateEntries(revoked []pkix.RevokedCertificate, revocations []revocation, removed []*big.Int) ([]pkix.RevokedCertificate, error) {
+	drop := func(serial *big.Int) {
+		kept := revoked[:0:0]
+		for _, rc := range revoked {
+			if rc.SerialNumber.Cmp(serial) != 0 {
+				kept = append(kept, rc)
+			}
+		}
+		revoked = kept
+	}
+
+	for _, serial := range removed {
+		drop(serial)
+	}
+
+	for _, r := range revocations {
+		drop(r.serial)
+
+		rc := pkix.RevokedCertificate{SerialNumber: r.serial, RevocationTime: r.date.UTC()}
+		if r.reason >= 0 {
+			ext, err := utils.CRLReasonExtension(r.reason)
+			if err != nil {
+				return nil, err
+			}
+			rc.Extensions = []pkix.Extension{ext}
+		}
+		revoked = append(revoked, rc)
+	}
+
+	return revoked, nil
+}
+
+// readCRLNumber reads hex CRL number from file, 1 if file does not exist
+func readCRLNumber(path string) (*big.Int, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return big.NewInt(1), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	number, ok := new(big.Int).SetString(strings.TrimSpace(string(data)), 16)
+	if !ok || number.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid CRL number %q", strings.TrimSpace(string(data)))
+	}
+
+	return number, nil
+}
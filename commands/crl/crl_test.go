@@ -0,0 +1,245 @@
+package crl_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yakuter/gossl/commands/crl"
+	"github.com/yakuter/gossl/commands/generate"
+	"github.com/yakuter/gossl/commands/key"
+	"github.com/yakuter/gossl/commands/req"
+	"github.com/yakuter/gossl/pkg/utils"
+
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func TestCRL(t *testing.T) {
+	execName, err := os.Executable()
+	require.NoError(t, err)
+
+	app := &cli.App{
+		Commands: []*cli.Command{
+			generate.Command(),
+			crl.Command(),
+			key.Command(),
+			req.Command(&bytes.Buffer{}),
+		},
+	}
+
+	pkiDir := t.TempDir()
+	require.NoError(t, app.Run([]string{execName, generate.CmdGenerate, "--out", pkiDir, "--type", "ecdsa"}))
+	caCert := filepath.Join(pkiDir, "ca-cert.pem")
+	caKey := filepath.Join(pkiDir, "ca-key.pem")
+
+	ca, err := utils.CertFromFile(caCert)
+	require.NoError(t, err)
+
+	type entry struct {
+		serial int64
+		reason int
+		date   string
+	}
+
+	testCases := []struct {
+		name      string
+		args      []string
+		revoked   []entry
+		number    int64
+		shouldErr bool
+	}{
+		{
+			name:   "empty CRL",
+			number: 1,
+		},
+		{
+			name: "revoked serials with reasons and dates",
+			args: []string{"--revoke", "10:keyCompromise:2024-01-31", "--revoke", "0x1f:superseded:2024-02-01T10:00:00Z", "--revoke", "12"},
+			revoked: []entry{
+				{serial: 10, reason: 1, date: "2024-01-31T00:00:00Z"},
+				{serial: 31, reason: 4, date: "2024-02-01T10:00:00Z"},
+				{serial: 12, reason: -1},
+			},
+			number: 1,
+		},
+		{
+			name:    "CRL number from file",
+			args:    []string{"--revoke", "7:cessationOfOperation", "--crlNumber", filepath.Join(pkiDir, "crlnumber")},
+			revoked: []entry{{serial: 7, reason: 5}},
+			number:  1,
+		},
+		{
+			name:    "CRL number is incremented in file",
+			args:    []string{"--revoke", "8", "--crlNumber", filepath.Join(pkiDir, "crlnumber")},
+			revoked: []entry{{serial: 8, reason: -1}},
+			number:  2,
+		},
+		{
+			name:      "unknown reason error",
+			args:      []string{"--revoke", "10:stolen"},
+			shouldErr: true,
+		},
+		{
+			name:      "invalid serial error",
+			args:      []string{"--revoke", "abc"},
+			shouldErr: true,
+		},
+		{
+			name:      "invalid date error",
+			args:      []string{"--revoke", "10:keyCompromise:31/01/2024"},
+			shouldErr: true,
+		},
+		{
+			name:      "zero next update error",
+			args:      []string{"--days", "0"},
+			shouldErr: true,
+		},
+		{
+			name:      "wrong CA key error",
+			args:      []string{"--cakey", caCert},
+			shouldErr: true,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.name, func(t *testing.T) {
+			outFile := filepath.Join(t.TempDir(), "ca.crl")
+			testArgs := append([]string{execName, crl.CmdCRL,
+				"--cacert", caCert,
+				"--cakey", caKey,
+				"--out", outFile,
+			}, tC.args...)
+
+			if tC.shouldErr {
+				require.Error(t, app.Run(testArgs))
+				return
+			}
+			require.NoError(t, app.Run(testArgs))
+
+			got, err := utils.CRLFromFile(outFile)
+			require.NoError(t, err)
+			require.NoError(t, ca.CheckCRLSignature(got))
+			require.Equal(t, big.NewInt(tC.number), utils.CRLNumber(got))
+			require.WithinDuration(t, time.Now().Add(30*24*time.Hour), got.TBSCertList.NextUpdate, time.Minute)
+
+			revoked := got.TBSCertList.RevokedCertificates
+			require.Len(t, revoked, len(tC.revoked))
+			for i, want := range tC.revoked {
+				require.Equal(t, big.NewInt(want.serial), revoked[i].SerialNumber)
+
+				reason, ok := utils.CRLReason(revoked[i])
+				require.Equal(t, want.reason >= 0, ok)
+				if ok {
+					require.Equal(t, want.reason, reason)
+				}
+
+				if want.date != "" {
+					require.Equal(t, want.date, revoked[i].RevocationTime.UTC().Format(time.RFC3339))
+				}
+			}
+		})
+	}
+
+	t.Run("update existing CRL", func(t *testing.T) {
+		dir := t.TempDir()
+		first := filepath.Join(dir, "first.crl")
+		second := filepath.Join(dir, "second.crl")
+		revokeFile := filepath.Join(dir, "revoked.txt")
+		require.NoError(t, os.WriteFile(revokeFile, []byte("# decommissioned hosts\n20:cessationOfOperation\n\n21:certificateHold # on hold\n"), 0o600))
+
+		require.NoError(t, app.Run([]string{execName, crl.CmdCRL, "--cacert", caCert, "--cakey", caKey,
+			"--revokeFile", revokeFile, "--days", "1", "--hours", "12", "--out", first}))
+
+		got, err := utils.CRLFromFile(first)
+		require.NoError(t, err)
+		require.Len(t, got.TBSCertList.RevokedCertificates, 2)
+		require.WithinDuration(t, time.Now().Add(36*time.Hour), got.TBSCertList.NextUpdate, time.Minute)
+
+		// Hold is released and serial 20 is revoked again with another reason
+		require.NoError(t, app.Run([]string{execName, crl.CmdCRL, "--cacert", caCert, "--cakey", caKey,
+			"--in", first, "--remove", "21", "--revoke", "20:keyCompromise", "--revoke", "22", "--out", second}))
+
+		got, err = utils.CRLFromFile(second)
+		require.NoError(t, err)
+		require.Equal(t, big.NewInt(2), utils.CRLNumber(got))
+
+		revoked := got.TBSCertList.RevokedCertificates
+		require.Len(t, revoked, 2)
+		require.Equal(t, big.NewInt(20), revoked[0].SerialNumber)
+		reason, ok := utils.CRLReason(revoked[0])
+		require.True(t, ok)
+		require.Equal(t, utils.CRLReasons["keyCompromise"], reason)
+		require.Equal(t, big.NewInt(22), revoked[1].SerialNumber)
+	})
+
+	t.Run("CRL of another CA error", func(t *testing.T) {
+		otherDir := t.TempDir()
+		require.NoError(t, app.Run([]string{execName, generate.CmdGenerate, "--out", otherDir, "--type", "ecdsa"}))
+
+		otherCRL := filepath.Join(otherDir, "ca.crl")
+		require.NoError(t, app.Run([]string{execName, crl.CmdCRL, "--cacert", filepath.Join(otherDir, "ca-cert.pem"),
+			"--cakey", filepath.Join(otherDir, "ca-key.pem"), "--out", otherCRL}))
+
+		err := app.Run([]string{execName, crl.CmdCRL, "--cacert", caCert, "--cakey", caKey, "--in", otherCRL,
+			"--out", filepath.Join(otherDir, "updated.crl")})
+		require.Error(t, err)
+	})
+
+	t.Run("CRL number file content", func(t *testing.T) {
+		data, err := os.ReadFile(filepath.Join(pkiDir, "crlnumber"))
+		require.NoError(t, err)
+		require.Equal(t, "3", strings.TrimSpace(string(data)))
+	})
+
+	t.Run("root made with cert --isCA", func(t *testing.T) {
+		dir := t.TempDir()
+		rootKey := filepath.Join(dir, "root-key.pem")
+		rootCert := filepath.Join(dir, "root-cert.pem")
+		require.NoError(t, app.Run([]string{execName, key.CmdKey, "--out", rootKey, "--type", "ecdsa"}))
+		require.NoError(t, app.Run([]string{execName, req.CmdCert, "--key", rootKey, "--out", rootCert,
+			"--isCA", "--subj", "/CN=Root CA"}))
+
+		outFile := filepath.Join(dir, "root.crl")
+		require.NoError(t, app.Run([]string{execName, crl.CmdCRL, "--cacert", rootCert, "--cakey", rootKey,
+			"--revoke", "10", "--out", outFile}))
+
+		root, err := utils.CertFromFile(rootCert)
+		require.NoError(t, err)
+		got, err := utils.CRLFromFile(outFile)
+		require.NoError(t, err)
+		require.NoError(t, root.CheckCRLSignature(got))
+	})
+
+	t.Run("CA without cRLSign key usage error", func(t *testing.T) {
+		signer, err := utils.PrivateKeyFromFile(caKey)
+		require.NoError(t, err)
+
+		tmpl := &x509.Certificate{
+			SerialNumber:          big.NewInt(1),
+			Subject:               pkix.Name{CommonName: "No CRL Sign CA"},
+			NotBefore:             time.Now(),
+			NotAfter:              time.Now().Add(time.Hour),
+			KeyUsage:              x509.KeyUsageCertSign,
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, signer.Public(), signer)
+		require.NoError(t, err)
+
+		dir := t.TempDir()
+		noCRLSignCert := filepath.Join(dir, "ca-cert.pem")
+		require.NoError(t, os.WriteFile(noCRLSignCert, utils.CertToPEM(der), 0o600))
+
+		err = app.Run([]string{execName, crl.CmdCRL, "--cacert", noCRLSignCert, "--cakey", caKey,
+			"--out", filepath.Join(dir, "ca.crl")})
+		require.ErrorContains(t, err, "cRLSign")
+	})
+}
@@ -0,0 +1,305 @@
+package generate
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yakuter/gossl/commands/req"
+	"github.com/yakuter/gossl/pkg/utils"
+
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	CmdGenerate = "generate"
+
+	flagOut          = "out"
+	flagType         = "type"
+	flagBits         = "bits"
+	flagCurve        = "curve"
+	flagCN           = "cn"
+	flagIntermediate = "intermediate"
+	flagLeaf         = "leaf"
+	flagDays         = "days"
+	flagCADays       = "caDays"
+	flagPassword     = "password"
+)
+
+// File names written to output directory
+const (
+	fileCAKey            = "ca-key.pem"
+	fileCACert           = "ca-cert.pem"
+	fileIntermediateKey  = "intermediate-key.pem"
+	fileIntermediateCert = "intermediate-cert.pem"
+	fileCAChain          = "ca-chain.pem"
+)
+
+func Command() *cli.Command {
+	return &cli.Command{
+		Name:        CmdGenerate,
+		HelpName:    CmdGenerate,
+		Action:      Action,
+		ArgsUsage:   ` `,
+		Usage:       `generates root CA, intermediate CA and certificates in one command.`,
+		Description: `Generates private keys and certificates of a root CA, an optional intermediate CA and leaf certificates into output directory.`,
+		Flags:       Flags(),
+	}
+}
+
+func Flags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        flagOut,
+			Usage:       "Output directory",
+			DefaultText: "./pki",
+			Value:       "./pki",
+			Required:    false,
+		},
+		&cli.StringFlag{
+			Name:        flagType,
+			Usage:       "Key type (rsa, ecdsa or ed25519)",
+			DefaultText: utils.KeyTypeRSA,
+			Value:       utils.KeyTypeRSA,
+			Required:    false,
+		},
+		&cli.UintFlag{
+			Name:        flagBits,
+			Usage:       "Number of bits (rsa only)",
+			DefaultText: "2048",
+			Value:       2048,
+			Required:    false,
+		},
+		&cli.StringFlag{
+			Name:        flagCurve,
+			Usage:       "Elliptic curve (P-256, P-384 or P-521) (ecdsa only)",
+			DefaultText: "P-256",
+			Value:       "P-256",
+			Required:    false,
+		},
+		&cli.StringFlag{
+			Name:        flagCN,
+			Usage:       "Common Name of root CA",
+			DefaultText: "GoSSL Root CA",
+			Value:       "GoSSL Root CA",
+			Required:    false,
+		},
+		&cli.BoolFlag{
+			Name:     flagIntermediate,
+			Usage:    "Generate an intermediate CA to sign leaf certificates",
+			Required: false,
+		},
+		&cli.StringSliceFlag{
+			Name:        flagLeaf,
+			Usage:       "SANs of a leaf certificate joined with +, can be repeated (optional)",
+			DefaultText: "eg, api.example.com+10.0.0.1",
+			Required:    false,
+		},
+		&cli.UintFlag{
+			Name:        flagDays,
+			Usage:       "Number of days leaf certificates are valid for",
+			DefaultText: "365",
+			Value:       365,
+			Required:    false,
+		},
+		&cli.UintFlag{
+			Name:        flagCADays,
+			Usage:       "Number of days CA certificates are valid for",
+			DefaultText: "3650",
+			Value:       3650,
+			Required:    false,
+		},
+		&cli.StringFlag{
+			Name:     flagPassword,
+			Usage:    "Pass phrase to encrypt CA private keys with (optional)",
+			Required: false,
+		},
+	}
+}
+
+func Action(c *cli.Context) error {
+	outDir := c.String(flagOut)
+	if err := os.MkdirAll(outDir, 0o700); err != nil {
+		log.Printf("Failed to create output directory %s error: %v", outDir, err)
+		return err
+	}
+
+	// Generate root CA
+	caKey, err := generateKey(c)
+	if err != nil {
+		return err
+	}
+
+	caCert, err := issue(req.RootCAProfile(), pkix.Name{CommonName: c.String(flagCN)}, nil,
+		c.Uint(flagCADays), caKey, nil, caKey)
+	if err != nil {
+		log.Printf("Failed to create root CA error: %v", err)
+		return err
+	}
+
+	if err = writeKeyPair(c, outDir, fileCAKey, fileCACert, caKey, caCert, true); err != nil {
+		return err
+	}
+
+	// Leaf certificates are signed by intermediate CA if it is generated
+	issuerCert, issuerKey := caCert, caKey
+	chain := []*x509.Certificate{caCert}
+
+	if c.Bool(flagIntermediate) {
+		issuerKey, err = generateKey(c)
+		if err != nil {
+			return err
+		}
+
+		subj := pkix.Name{CommonName: c.String(flagCN) + " Intermediate"}
+		issuerCert, err = issue(req.IntermediateCAProfile(), subj, nil,
+			c.Uint(flagCADays), issuerKey, caCert, caKey)
+		if err != nil {
+			log.Printf("Failed to create intermediate CA error: %v", err)
+			return err
+		}
+
+		if err = writeKeyPair(c, outDir, fileIntermediateKey, fileIntermediateCert, issuerKey, issuerCert, true); err != nil {
+			return err
+		}
+
+		chain = append([]*x509.Certificate{issuerCert}, chain...)
+	}
+
+	if err = writeCerts(filepath.Join(outDir, fileCAChain), chain...); err != nil {
+		return err
+	}
+
+	// Generate leaf certificates
+	for _, leaf := range c.StringSlice(flagLeaf) {
+		sans := strings.Split(leaf, "+")
+		name := leafName(sans[0])
+		if name == "" {
+			err = fmt.Errorf("invalid leaf %q", leaf)
+			log.Printf("%v", err)
+			return err
+		}
+
+		leafKey, err := generateKey(c)
+		if err != nil {
+			return err
+		}
+
+		// Key encipherment is only meaningful for RSA keys
+		p := req.LeafProfile()
+		if _, ok := leafKey.Public().(*rsa.PublicKey); !ok {
+			p.KeyUsage = []string{"digitalSignature"}
+		}
+
+		subj := pkix.Name{CommonName: sanValue(sans[0])}
+		leafCert, err := issue(p, subj, sans, c.Uint(flagDays), leafKey, issuerCert, issuerKey)
+		if err != nil {
+			log.Printf("Failed to create leaf certificate %s error: %v", leaf, err)
+			return err
+		}
+
+		if err = writeKeyPair(c, outDir, name+"-key.pem", name+"-cert.pem", leafKey, leafCert, false); err != nil {
+			return err
+		}
+
+		// Chain file contains leaf and intermediate certificates without root CA
+		leafChain := []*x509.Certificate{leafCert}
+		if c.Bool(flagIntermediate) {
+			leafChain = append(leafChain, issuerCert)
+		}
+		if err = writeCerts(filepath.Join(outDir, name+"-chain.pem"), leafChain...); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("PKI generated in %s", outDir)
+	return nil
+}
+
+// issue creates a certificate with a random serial number
+func issue(p *req.Profile, subj pkix.Name, sans []string, days uint, key crypto.Signer,
+	parent *x509.Certificate, parentKey crypto.Signer) (*x509.Certificate, error) {
+	serial, err := utils.RandomSerial()
+	if err != nil {
+		log.Printf("Failed to generate serial number error: %v", err)
+		return nil, err
+	}
+
+	return req.Issue(p, subj, sans, days, serial, key.Public(), parent, parentKey)
+}
+
+func generateKey(c *cli.Context) (crypto.Signer, error) {
+	key, err := utils.GenerateKey(c.String(flagType), int(c.Uint(flagBits)), c.String(flagCurve))
+	if err != nil {
+		log.Printf("Failed to generate %s Private Key error: %v", c.String(flagType), err)
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// writeKeyPair writes private key and certificate into output directory.
+// CA private keys are encrypted if password flag is set.
+func writeKeyPair(c *cli.Context, outDir, keyFile, certFile string, key crypto.Signer, cert *x509.Certificate, isCA bool) error {
+	var (
+		keyPEM []byte
+		err    error
+	)
+
+	if isCA && c.IsSet(flagPassword) {
+		keyPEM, err = utils.EncryptedPrivateKeyToPEM(key, []byte(c.String(flagPassword)))
+	} else {
+		keyPEM, err = utils.AnyPrivateKeyToPEM(key)
+	}
+	if err != nil {
+		log.Printf("Failed to encode Private Key error: %v", err)
+		return err
+	}
+
+	keyPath := filepath.Join(outDir, keyFile)
+	if err = os.WriteFile(keyPath, keyPEM, 0o600); err != nil {
+		log.Printf("Failed to write Private Key to file %s error: %v", keyPath, err)
+		return err
+	}
+
+	return writeCerts(filepath.Join(outDir, certFile), cert)
+}
+
+// writeCerts writes certificates to path in PEM format
+func writeCerts(path string, certs ...*x509.Certificate) error {
+	var out []byte
+	for _, cert := range certs {
+		out = append(out, utils.CertToPEM(cert.Raw)...)
+	}
+
+	if err := os.WriteFile(path, out, 0o600); err != nil {
+		log.Printf("Failed to write certificate to file %s error: %v", path, err)
+		return err
+	}
+
+	log.Printf("Certificate written to %s", path)
+	return nil
+}
+
+// sanValue returns SAN without its type prefix (eg, "dns:")
+func sanValue(san string) string {
+	for _, prefix := range []string{"dns:", "ip:", "email:", "uri:"} {
+		if strings.HasPrefix(strings.ToLower(san), prefix) {
+			return san[len(prefix):]
+		}
+	}
+
+	return san
+}
+
+// leafName returns a file name for leaf certificate from its first SAN
+func leafName(san string) string {
+	replacer := strings.NewReplacer("*", "wildcard", ":", "_", "/", "_", "\\", "_", "@", "_at_")
+	return replacer.Replace(sanValue(san))
+}
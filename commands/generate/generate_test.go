@@ -0,0 +1,137 @@
+package generate_test
+
+import (
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yakuter/gossl/commands/generate"
+	"github.com/yakuter/gossl/pkg/utils"
+
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func TestGenerate(t *testing.T) {
+	app := &cli.App{
+		Commands: []*cli.Command{
+			generate.Command(),
+		},
+	}
+
+	execName, err := os.Executable()
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name         string
+		keyType      string
+		intermediate bool
+		leafs        []string
+		password     string
+		files        []string
+		shouldErr    bool
+	}{
+		{
+			name:    "root CA with leafs",
+			keyType: "ecdsa",
+			leafs:   []string{"api.example.com+10.0.0.1", "*.example.com"},
+			files: []string{
+				"ca-key.pem", "ca-cert.pem", "ca-chain.pem",
+				"api.example.com-key.pem", "api.example.com-cert.pem", "api.example.com-chain.pem",
+				"wildcard.example.com-key.pem", "wildcard.example.com-cert.pem",
+			},
+		},
+		{
+			name:         "root and intermediate CA with leaf",
+			keyType:      "ed25519",
+			intermediate: true,
+			leafs:        []string{"ip:127.0.0.1+dns:localhost"},
+			password:     "s3cret",
+			files: []string{
+				"ca-key.pem", "ca-cert.pem", "ca-chain.pem",
+				"intermediate-key.pem", "intermediate-cert.pem",
+				"127.0.0.1-key.pem", "127.0.0.1-cert.pem", "127.0.0.1-chain.pem",
+			},
+		},
+		{
+			name:         "rsa keys",
+			keyType:      "rsa",
+			intermediate: true,
+			leafs:        []string{"localhost"},
+			files:        []string{"localhost-cert.pem"},
+		},
+		{
+			name:      "unsupported key type error",
+			keyType:   "dsa",
+			shouldErr: true,
+		},
+		{
+			name:      "invalid leaf SAN error",
+			keyType:   "ecdsa",
+			leafs:     []string{"ip:not-an-ip"},
+			shouldErr: true,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.name, func(t *testing.T) {
+			outDir := filepath.Join(t.TempDir(), "pki")
+			testArgs := []string{execName, generate.CmdGenerate, "--out", outDir, "--type", tC.keyType, "--bits", "1024"}
+			if tC.intermediate {
+				testArgs = append(testArgs, "--intermediate")
+			}
+			for _, leaf := range tC.leafs {
+				testArgs = append(testArgs, "--leaf", leaf)
+			}
+			if tC.password != "" {
+				testArgs = append(testArgs, "--password", tC.password)
+			}
+
+			if tC.shouldErr {
+				require.Error(t, app.Run(testArgs))
+				return
+			}
+			require.NoError(t, app.Run(testArgs))
+
+			for _, file := range tC.files {
+				require.FileExists(t, filepath.Join(outDir, file))
+			}
+
+			// Every leaf must be verified with root CA through its chain file
+			ca, err := utils.CertFromFile(filepath.Join(outDir, "ca-cert.pem"))
+			require.NoError(t, err)
+			roots := x509.NewCertPool()
+			roots.AddCert(ca)
+
+			intermediates := x509.NewCertPool()
+			if tC.intermediate {
+				intermediate, err := utils.CertFromFile(filepath.Join(outDir, "intermediate-cert.pem"))
+				require.NoError(t, err)
+				require.True(t, intermediate.MaxPathLenZero)
+				intermediates.AddCert(intermediate)
+			}
+
+			for _, file := range tC.files {
+				if !strings.HasSuffix(file, "-cert.pem") || file == "ca-cert.pem" || file == "intermediate-cert.pem" {
+					continue
+				}
+				leaf, err := utils.CertFromFile(filepath.Join(outDir, file))
+				require.NoError(t, err)
+				_, err = leaf.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+				require.NoError(t, err)
+				require.Equal(t, 1, leaf.SerialNumber.Sign())
+				require.NotEqual(t, 0, leaf.SerialNumber.Cmp(ca.SerialNumber))
+			}
+
+			// CA keys are encrypted if password is provided
+			_, err = utils.PrivateKeyFromFile(filepath.Join(outDir, "ca-key.pem"))
+			if tC.password != "" {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
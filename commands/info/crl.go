@@ -0,0 +1,138 @@
+package info
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yakuter/gossl/pkg/utils"
+)
+
+// crlInfo is the JSON and YAML schema of a CRL
+type crlInfo struct {
+	Version             int           `json:"version" yaml:"version"`
+	SignatureAlgorithm  string        `json:"signatureAlgorithm" yaml:"signatureAlgorithm"`
+	Issuer              nameInfo      `json:"issuer" yaml:"issuer"`
+	ThisUpdate          time.Time     `json:"thisUpdate" yaml:"thisUpdate"`
+	NextUpdate          *time.Time    `json:"nextUpdate" yaml:"nextUpdate"`
+	Number              string        `json:"crlNumber" yaml:"crlNumber"`
+	Expired             bool          `json:"expired" yaml:"expired"`
+	RevokedCertificates []revokedInfo `json:"revokedCertificates" yaml:"revokedCertificates"`
+}
+
+// revokedInfo is a revoked certificate entry, serial number is lowercase hex
+type revokedInfo struct {
+	SerialNumber   string    `json:"serialNumber" yaml:"serialNumber"`
+	RevocationDate time.Time `json:"revocationDate" yaml:"revocationDate"`
+	Reason         string    `json:"reason,omitempty" yaml:"reason,omitempty"`
+}
+
+// signatureAlgorithmNames are names of signature algorithm OIDs as in x509.SignatureAlgorithm
+var signatureAlgorithmNames = map[string]string{
+	"1.2.840.113549.1.1.5":  "SHA1-RSA",
+	"1.2.840.113549.1.1.10": "RSASSA-PSS",
+	"1.2.840.113549.1.1.11": "SHA256-RSA",
+	"1.2.840.113549.1.1.12": "SHA384-RSA",
+	"1.2.840.113549.1.1.13": "SHA512-RSA",
+	"1.2.840.10045.4.1":     "ECDSA-SHA1",
+	"1.2.840.10045.4.3.2":   "ECDSA-SHA256",
+	"1.2.840.10045.4.3.3":   "ECDSA-SHA384",
+	"1.2.840.10045.4.3.4":   "ECDSA-SHA512",
+	"1.3.101.112":           "Ed25519",
+}
+
+func newCRLInfo(crl *pkix.CertificateList, now time.Time) crlInfo {
+	tbs := crl.TBSCertList
+
+	var issuer pkix.Name
+	issuer.FillFromRDNSequence(&tbs.Issuer)
+
+	info := crlInfo{
+		Version:             tbs.Version + 1,
+		SignatureAlgorithm:  signatureAlgorithmName(crl.SignatureAlgorithm.Algorithm),
+		Issuer:              newNameInfo(issuer),
+		ThisUpdate:          tbs.ThisUpdate.UTC(),
+		Expired:             crl.HasExpired(now),
+		RevokedCertificates: []revokedInfo{},
+	}
+
+	if !tbs.NextUpdate.IsZero() {
+		nextUpdate := tbs.NextUpdate.UTC()
+		info.NextUpdate = &nextUpdate
+	}
+
+	if number := utils.CRLNumber(crl); number != nil {
+		info.Number = number.String()
+	}
+
+	for _, rc := range tbs.RevokedCertificates {
+		r := revokedInfo{
+			SerialNumber:   hex.EncodeToString(rc.SerialNumber.Bytes()),
+			RevocationDate: rc.RevocationTime.UTC(),
+		}
+		if reason, ok := utils.CRLReason(rc); ok {
+			r.Reason = utils.CRLReasonName(reason)
+		}
+		info.RevokedCertificates = append(info.RevokedCertificates, r)
+	}
+
+	return info
+}
+
+func signatureAlgorithmName(oid asn1.ObjectIdentifier) string {
+	if name, ok := signatureAlgorithmNames[oid.String()]; ok {
+		return name
+	}
+
+	return oid.String()
+}
+
+// crlText returns human-readable text of CRL in OpenSSL style
+func crlText(info crlInfo) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Certificate Revocation List (CRL):\n")
+	fmt.Fprintf(&b, "    Version: %d\n", info.Version)
+	fmt.Fprintf(&b, "    Signature Algorithm: %s\n", info.SignatureAlgorithm)
+	fmt.Fprintf(&b, "    Issuer: %s\n", info.Issuer.String)
+	fmt.Fprintf(&b, "    Last Update: %s\n", info.ThisUpdate.Format(time.RFC3339))
+	if info.NextUpdate != nil {
+		expired := ""
+		if info.Expired {
+			expired = " (expired)"
+		}
+		fmt.Fprintf(&b, "    Next Update: %s%s\n", info.NextUpdate.Format(time.RFC3339), expired)
+	} else {
+		fmt.Fprintf(&b, "    Next Update: none\n")
+	}
+	fmt.Fprintf(&b, "    CRL Number: %s\n", valueOrNone(info.Number))
+
+	if len(info.RevokedCertificates) == 0 {
+		fmt.Fprintf(&b, "No Revoked Certificates.\n")
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "Revoked Certificates:\n")
+	for _, r := range info.RevokedCertificates {
+		fmt.Fprintf(&b, "    Serial Number: %s\n", strings.ToUpper(r.SerialNumber))
+		fmt.Fprintf(&b, "        Revocation Date: %s\n", r.RevocationDate.Format(time.RFC3339))
+		if r.Reason != "" {
+			fmt.Fprintf(&b, "        Reason: %s\n", r.Reason)
+		}
+	}
+
+	return b.String()
+}
+
+// formatCRL returns crl in text, JSON or YAML format
+func formatCRL(format string, crl *pkix.CertificateList) (string, error) {
+	info := newCRLInfo(crl, time.Now())
+	if format == formatText {
+		return crlText(info), nil
+	}
+
+	return marshal(format, info)
+}
@@ -0,0 +1,289 @@
+package info
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"strings"
+
+	"github.com/grantae/certinfo"
+)
+
+// certinfo does not know about Ed25519 public keys, so certificates and CSRs
+// carrying one are rendered here in the same layout.
+
+var (
+	oidExtensionAuthorityInfoAccess = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 1}
+
+	// textKeyUsageNames are certinfo style names of key usages in bit order
+	textKeyUsageNames = []struct {
+		usage x509.KeyUsage
+		name  string
+	}{
+		{x509.KeyUsageDigitalSignature, "Digital Signature"},
+		{x509.KeyUsageContentCommitment, "Content Commitment"},
+		{x509.KeyUsageKeyEncipherment, "Key Encipherment"},
+		{x509.KeyUsageDataEncipherment, "Data Encipherment"},
+		{x509.KeyUsageKeyAgreement, "Key Agreement"},
+		{x509.KeyUsageCertSign, "Certificate Sign"},
+		{x509.KeyUsageCRLSign, "CRL Sign"},
+		{x509.KeyUsageEncipherOnly, "Encipher Only"},
+		{x509.KeyUsageDecipherOnly, "Decipher Only"},
+	}
+
+	// textExtKeyUsageNames are certinfo style names of extended key usages
+	textExtKeyUsageNames = map[x509.ExtKeyUsage]string{
+		x509.ExtKeyUsageAny:             "Any Usage",
+		x509.ExtKeyUsageServerAuth:      "TLS Web Server Authentication",
+		x509.ExtKeyUsageClientAuth:      "TLS Web Client Authentication",
+		x509.ExtKeyUsageCodeSigning:     "Code Signing",
+		x509.ExtKeyUsageEmailProtection: "E-mail Protection",
+		x509.ExtKeyUsageIPSECEndSystem:  "IPSec End System",
+		x509.ExtKeyUsageIPSECTunnel:     "IPSec Tunnel",
+		x509.ExtKeyUsageIPSECUser:       "IPSec User",
+		x509.ExtKeyUsageTimeStamping:    "Time Stamping",
+		x509.ExtKeyUsageOCSPSigning:     "OCSP Signing",
+	}
+)
+
+// certificateText returns human-readable text of cert including Ed25519 certificates
+func certificateText(cert *x509.Certificate) (string, error) {
+	pub, ok := cert.PublicKey.(ed25519.PublicKey)
+	if !ok {
+		return certinfo.CertificateText(cert)
+	}
+
+	var b strings.Builder
+	b.WriteString("Certificate:\n")
+	fmt.Fprintf(&b, "%4sData:\n", "")
+	writeVersion(&b, cert.Version)
+	fmt.Fprintf(&b, "%8sSerial Number: %d (%#x)\n", "", cert.SerialNumber, cert.SerialNumber)
+	fmt.Fprintf(&b, "%4sSignature Algorithm: %s\n", "", cert.SignatureAlgorithm)
+	fmt.Fprintf(&b, "%8sIssuer: %s\n", "", nameText(cert.Issuer))
+	fmt.Fprintf(&b, "%8sValidity\n", "")
+	fmt.Fprintf(&b, "%12sNot Before: %s\n", "", cert.NotBefore.Format("Jan 2 15:04:05 2006 MST"))
+	fmt.Fprintf(&b, "%12sNot After : %s\n", "", cert.NotAfter.Format("Jan 2 15:04:05 2006 MST"))
+	writeSubject(&b, cert.Subject, pub)
+
+	if cert.Version == 3 && len(cert.Extensions) > 0 {
+		fmt.Fprintf(&b, "%8sX509v3 extensions:\n", "")
+		for _, ext := range cert.Extensions {
+			writeExtension(&b, ext, cert)
+		}
+		b.WriteString("\n")
+	}
+
+	writeSignature(&b, cert.SignatureAlgorithm, cert.Signature)
+
+	return b.String(), nil
+}
+
+// certificateRequestText returns human-readable text of csr including Ed25519 CSRs
+func certificateRequestText(csr *x509.CertificateRequest) (string, error) {
+	pub, ok := csr.PublicKey.(ed25519.PublicKey)
+	if !ok {
+		return certinfo.CertificateRequestText(csr)
+	}
+
+	var b strings.Builder
+	b.WriteString("Certificate Request:\n")
+	fmt.Fprintf(&b, "%4sData:\n", "")
+	writeVersion(&b, csr.Version)
+	writeSubject(&b, csr.Subject, pub)
+
+	if len(csr.Extensions) > 0 {
+		// Requested extensions are rendered through the certificate fields
+		// crypto/x509 fills for them
+		cert := &x509.Certificate{
+			DNSNames:       csr.DNSNames,
+			EmailAddresses: csr.EmailAddresses,
+			IPAddresses:    csr.IPAddresses,
+			URIs:           csr.URIs,
+		}
+		fmt.Fprintf(&b, "%8sRequested Extensions:\n", "")
+		for _, ext := range csr.Extensions {
+			writeExtension(&b, ext, cert)
+		}
+		b.WriteString("\n")
+	}
+
+	writeSignature(&b, csr.SignatureAlgorithm, csr.Signature)
+
+	return b.String(), nil
+}
+
+// ed25519Text formats Ed25519 public key in certinfo style
+func ed25519Text(pub ed25519.PublicKey) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%12sPublic Key Algorithm: Ed25519\n", "")
+	fmt.Fprintf(&b, "%16sPublic-Key: (256 bit)\n", "")
+	fmt.Fprintf(&b, "%16spub:", "")
+	for i, val := range pub {
+		if (i % 15) == 0 {
+			fmt.Fprintf(&b, "\n%20s", "")
+		}
+		fmt.Fprintf(&b, "%02x", val)
+		if i != len(pub)-1 {
+			b.WriteString(":")
+		}
+	}
+	b.WriteString("\n")
+
+	return b.String()
+}
+
+func writeVersion(b *strings.Builder, version int) {
+	hexVersion := version - 1
+	if hexVersion < 0 {
+		hexVersion = 0
+	}
+	fmt.Fprintf(b, "%8sVersion: %d (%#x)\n", "", version, hexVersion)
+}
+
+func writeSubject(b *strings.Builder, subject pkix.Name, pub ed25519.PublicKey) {
+	fmt.Fprintf(b, "%8sSubject: %s\n", "", nameText(subject))
+	fmt.Fprintf(b, "%8sSubject Public Key Info:\n", "")
+	b.WriteString(ed25519Text(pub))
+}
+
+func writeSignature(b *strings.Builder, algo x509.SignatureAlgorithm, sig []byte) {
+	fmt.Fprintf(b, "%4sSignature Algorithm: %s", "", algo)
+	for i, val := range sig {
+		if (i % 18) == 0 {
+			fmt.Fprintf(b, "\n%9s", "")
+		}
+		fmt.Fprintf(b, "%02x", val)
+		if i != len(sig)-1 {
+			b.WriteString(":")
+		}
+	}
+	b.WriteString("\n")
+}
+
+// writeExtension writes ext using the values crypto/x509 parsed into cert
+func writeExtension(b *strings.Builder, ext pkix.Extension, cert *x509.Certificate) {
+	header := func(name string) {
+		fmt.Fprintf(b, "%12s%s:", "", name)
+		if ext.Critical {
+			b.WriteString(" critical")
+		}
+		b.WriteString("\n")
+	}
+	line := func(values []string) {
+		if len(values) > 0 {
+			fmt.Fprintf(b, "%16s%s\n", "", strings.Join(values, ", "))
+		}
+	}
+
+	switch ext.Id.String() {
+	case "2.5.29.14":
+		header("X509v3 Subject Key Identifier")
+		var keyID []byte
+		if _, err := asn1.Unmarshal(ext.Value, &keyID); err == nil {
+			line([]string{hexColon(keyID)})
+		}
+	case "2.5.29.15":
+		header("X509v3 Key Usage")
+		var usages []string
+		for _, u := range textKeyUsageNames {
+			if cert.KeyUsage&u.usage != 0 {
+				usages = append(usages, u.name)
+			}
+		}
+		if len(usages) == 0 {
+			usages = []string{"None"}
+		}
+		line(usages)
+	case "2.5.29.17":
+		header("X509v3 Subject Alternative Name")
+		var names []string
+		for _, name := range cert.DNSNames {
+			names = append(names, "DNS:"+name)
+		}
+		for _, email := range cert.EmailAddresses {
+			names = append(names, "email:"+email)
+		}
+		for _, ip := range cert.IPAddresses {
+			names = append(names, "IP Address:"+ip.String())
+		}
+		for _, uri := range cert.URIs {
+			names = append(names, "URI:"+uri.String())
+		}
+		line(names)
+	case "2.5.29.19":
+		header("X509v3 Basic Constraints")
+		constraint := "CA:FALSE"
+		if cert.IsCA {
+			constraint = "CA:TRUE"
+		}
+		if cert.MaxPathLenZero {
+			constraint += ", pathlen:0"
+		} else if cert.MaxPathLen > 0 {
+			constraint += fmt.Sprintf(", pathlen:%d", cert.MaxPathLen)
+		}
+		line([]string{constraint})
+	case "2.5.29.31":
+		header("X509v3 CRL Distribution Points")
+		for _, uri := range cert.CRLDistributionPoints {
+			line([]string{"URI:" + uri})
+		}
+	case "2.5.29.32":
+		header("X509v3 Certificate Policies")
+		for _, policy := range cert.PolicyIdentifiers {
+			line([]string{"Policy: " + policy.String()})
+		}
+	case "2.5.29.35":
+		header("X509v3 Authority Key Identifier")
+		line([]string{"keyid:" + hexColon(cert.AuthorityKeyId)})
+	case "2.5.29.37":
+		header("X509v3 Extended Key Usage")
+		var usages []string
+		for _, usage := range cert.ExtKeyUsage {
+			name, ok := textExtKeyUsageNames[usage]
+			if !ok {
+				name = "UNKNOWN"
+			}
+			usages = append(usages, name)
+		}
+		for _, oid := range cert.UnknownExtKeyUsage {
+			usages = append(usages, oid.String())
+		}
+		line(usages)
+	case oidExtensionAuthorityInfoAccess.String():
+		header("Authority Information Access")
+		for _, uri := range cert.OCSPServer {
+			line([]string{"OCSP - URI:" + uri})
+		}
+		for _, uri := range cert.IssuingCertificateURL {
+			line([]string{"CA Issuers - URI:" + uri})
+		}
+	default:
+		header("Unknown extension " + ext.Id.String())
+	}
+}
+
+// nameText formats a distinguished name in certinfo style, e.g. CN=a,O=b
+func nameText(name pkix.Name) string {
+	values := make([]string, 0, len(name.Names))
+	for _, attr := range name.Names {
+		key, ok := attributeTypeNames[attr.Type.String()]
+		if !ok {
+			values = append(values, fmt.Sprintf("UnknownOID=%s", attr.Type))
+			continue
+		}
+		values = append(values, fmt.Sprintf("%s=%v", key, attr.Value))
+	}
+
+	return strings.Join(values, ",")
+}
+
+func hexColon(data []byte) string {
+	parts := make([]string, len(data))
+	for i, v := range data {
+		parts[i] = fmt.Sprintf("%02X", v)
+	}
+
+	return strings.Join(parts, ":")
+}
@@ -0,0 +1,282 @@
+package info
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+
+	"github.com/yakuter/gossl/pkg/probe"
+)
+
+// enumVersions are TLS versions tried from the oldest one
+var enumVersions = []uint16{tls.VersionTLS10, tls.VersionTLS11, tls.VersionTLS12, tls.VersionTLS13}
+
+// enumGroups are named groups (elliptic curves) supported by crypto/tls
+var enumGroups = []struct {
+	id   tls.CurveID
+	name string
+}{
+	{tls.X25519, "X25519"},
+	{tls.CurveP256, "P-256"},
+	{tls.CurveP384, "P-384"},
+	{tls.CurveP521, "P-521"},
+}
+
+// enumInfo is the JSON and YAML schema of TLS versions and cipher suites supported by a server
+type enumInfo struct {
+	Host                 string        `json:"host" yaml:"host"`
+	ServerName           string        `json:"serverName" yaml:"serverName"`
+	Versions             []versionInfo `json:"versions" yaml:"versions"`
+	NamedGroups          []string      `json:"namedGroups" yaml:"namedGroups"`
+	InsecureVersions     []string      `json:"insecureVersions" yaml:"insecureVersions"`
+	InsecureCipherSuites []string      `json:"insecureCipherSuites" yaml:"insecureCipherSuites"`
+}
+
+type versionInfo struct {
+	Version      string      `json:"version" yaml:"version"`
+	Supported    bool        `json:"supported" yaml:"supported"`
+	CipherSuites []suiteInfo `json:"cipherSuites" yaml:"cipherSuites"`
+	// PreferenceOrder is the order of cipher suites chosen by server
+	PreferenceOrder []string `json:"preferenceOrder" yaml:"preferenceOrder"`
+}
+
+type suiteInfo struct {
+	Name     string `json:"name" yaml:"name"`
+	ID       string `json:"id" yaml:"id"`
+	Insecure bool   `json:"insecure" yaml:"insecure"`
+}
+
+// enumerator connects to a server with a TLS config for each check
+type enumerator struct {
+	addr string
+	opts *probe.Options
+}
+
+// handshake returns the negotiated state if server accepts config
+func (e *enumerator) handshake(config *tls.Config) (tls.ConnectionState, bool) {
+	config.InsecureSkipVerify = true
+
+	conn, err := e.opts.Dial(e.addr, config)
+	if err != nil {
+		return tls.ConnectionState{}, false
+	}
+	defer conn.Close()
+
+	return conn.ConnectionState(), true
+}
+
+// enumerate tries each TLS version, each cipher suite of TLS 1.0-1.2 and each
+// named group individually. crypto/tls does not allow offering TLS 1.3 cipher
+// suites individually, so only the negotiated TLS 1.3 suite is reported.
+func enumerate(addr string, opts *probe.Options) (enumInfo, error) {
+	// Every version is tried regardless of TLS version options
+	versionOpts := *opts
+	versionOpts.MinVersion, versionOpts.MaxVersion = 0, 0
+	e := &enumerator{addr: addr, opts: &versionOpts}
+
+	// Check that server is reachable before trying each version
+	conn, err := e.opts.Dial(addr, &tls.Config{InsecureSkipVerify: true, MinVersion: tls.VersionTLS10})
+	if err != nil {
+		return enumInfo{}, err
+	}
+	conn.Close()
+
+	info := enumInfo{
+		Host:                 opts.Address(addr),
+		ServerName:           opts.TLSConfig(&tls.Config{}, addr).ServerName,
+		Versions:             []versionInfo{},
+		NamedGroups:          []string{},
+		InsecureVersions:     []string{},
+		InsecureCipherSuites: []string{},
+	}
+
+	insecureSuites := map[string]bool{}
+	for _, version := range enumVersions {
+		v := versionInfo{
+			Version:         probe.VersionName(version),
+			CipherSuites:    []suiteInfo{},
+			PreferenceOrder: []string{},
+		}
+
+		state, ok := e.handshake(&tls.Config{MinVersion: version, MaxVersion: version})
+		v.Supported = ok
+		if ok && version < tls.VersionTLS12 {
+			info.InsecureVersions = append(info.InsecureVersions, v.Version)
+		}
+
+		switch {
+		case !ok:
+		case version == tls.VersionTLS13:
+			v.CipherSuites = append(v.CipherSuites, newSuiteInfo(state.CipherSuite))
+			v.PreferenceOrder = append(v.PreferenceOrder, tls.CipherSuiteName(state.CipherSuite))
+		default:
+			v.CipherSuites, v.PreferenceOrder = e.cipherSuites(version)
+		}
+
+		for _, s := range v.CipherSuites {
+			if s.Insecure && !insecureSuites[s.Name] {
+				insecureSuites[s.Name] = true
+				info.InsecureCipherSuites = append(info.InsecureCipherSuites, s.Name)
+			}
+		}
+
+		info.Versions = append(info.Versions, v)
+	}
+
+	// Only ECDHE suites and TLS 1.3 use the offered group, a handshake with
+	// RSA key exchange succeeds whatever the group is
+	ecdheSuites := ecdheCipherSuites()
+	for _, group := range enumGroups {
+		state, ok := e.handshake(&tls.Config{CurvePreferences: []tls.CurveID{group.id}, CipherSuites: ecdheSuites})
+		if ok && (state.Version == tls.VersionTLS13 || containsSuite(ecdheSuites, state.CipherSuite)) {
+			info.NamedGroups = append(info.NamedGroups, group.name)
+		}
+	}
+
+	return info, nil
+}
+
+// cipherSuites returns cipher suites of TLS 1.0-1.2 version accepted by server
+// when offered alone, and the order server chooses them when all are offered
+func (e *enumerator) cipherSuites(version uint16) ([]suiteInfo, []string) {
+	var (
+		accepted []uint16
+		suites   = []suiteInfo{}
+		order    = []string{}
+	)
+
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if !supportsVersion(suite, version) {
+			continue
+		}
+
+		config := &tls.Config{MinVersion: version, MaxVersion: version, CipherSuites: []uint16{suite.ID}}
+		if _, ok := e.handshake(config); ok {
+			accepted = append(accepted, suite.ID)
+			suites = append(suites, newSuiteInfo(suite.ID))
+		}
+	}
+
+	// Remove the chosen suite from offered ones until none is left
+	remaining := accepted
+	for len(remaining) > 0 {
+		config := &tls.Config{MinVersion: version, MaxVersion: version, CipherSuites: remaining}
+		state, ok := e.handshake(config)
+		if !ok {
+			break
+		}
+
+		order = append(order, tls.CipherSuiteName(state.CipherSuite))
+		remaining = without(remaining, state.CipherSuite)
+	}
+
+	return suites, order
+}
+
+// ecdheCipherSuites returns TLS 1.0-1.2 cipher suites with ECDHE key exchange
+func ecdheCipherSuites() []uint16 {
+	var ids []uint16
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if strings.HasPrefix(suite.Name, "TLS_ECDHE_") {
+			ids = append(ids, suite.ID)
+		}
+	}
+
+	return ids
+}
+
+func containsSuite(ids []uint16, id uint16) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+func supportsVersion(suite *tls.CipherSuite, version uint16) bool {
+	for _, v := range suite.SupportedVersions {
+		if v == version {
+			return true
+		}
+	}
+
+	return false
+}
+
+func without(ids []uint16, id uint16) []uint16 {
+	result := make([]uint16, 0, len(ids))
+	for _, v := range ids {
+		if v != id {
+			result = append(result, v)
+		}
+	}
+
+	return result
+}
+
+func newSuiteInfo(id uint16) suiteInfo {
+	insecure := false
+	for _, suite := range tls.InsecureCipherSuites() {
+		if suite.ID == id {
+			insecure = true
+		}
+	}
+
+	return suiteInfo{
+		Name:     tls.CipherSuiteName(id),
+		ID:       fmt.Sprintf("0x%04x", id),
+		Insecure: insecure,
+	}
+}
+
+// enumText returns human-readable text of supported TLS versions and cipher suites
+func enumText(info enumInfo) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "TLS Enumeration:\n")
+	fmt.Fprintf(&b, "    Host: %s\n", info.Host)
+	fmt.Fprintf(&b, "    Server Name: %s\n", info.ServerName)
+
+	for _, v := range info.Versions {
+		if !v.Supported {
+			fmt.Fprintf(&b, "    %s: not supported\n", v.Version)
+			continue
+		}
+
+		// Accepted suites in server preference order followed by unordered ones
+		fmt.Fprintf(&b, "    %s: supported\n", v.Version)
+		names := append([]string{}, v.PreferenceOrder...)
+		for _, s := range v.CipherSuites {
+			if !contains(names, s.Name) {
+				names = append(names, s.Name)
+			}
+		}
+		for i, name := range names {
+			insecure := ""
+			for _, s := range v.CipherSuites {
+				if s.Name == name && s.Insecure {
+					insecure = " (insecure)"
+				}
+			}
+			fmt.Fprintf(&b, "        %d. %s%s\n", i+1, name, insecure)
+		}
+	}
+
+	fmt.Fprintf(&b, "    Named Groups: %s\n", valueOrNone(strings.Join(info.NamedGroups, ", ")))
+	fmt.Fprintf(&b, "    Insecure Versions: %s\n", valueOrNone(strings.Join(info.InsecureVersions, ", ")))
+	fmt.Fprintf(&b, "    Insecure Cipher Suites: %s\n", valueOrNone(strings.Join(info.InsecureCipherSuites, ", ")))
+
+	return b.String()
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
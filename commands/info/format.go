@@ -0,0 +1,320 @@
+package info
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Supported output formats
+const (
+	formatText = "text"
+	formatJSON = "json"
+	formatYAML = "yaml"
+)
+
+// certificateInfo is the JSON and YAML schema of a certificate.
+// Binary values (serial number, key identifiers, fingerprints) are lowercase hex.
+type certificateInfo struct {
+	Version            int               `json:"version" yaml:"version"`
+	SerialNumber       string            `json:"serialNumber" yaml:"serialNumber"`
+	SignatureAlgorithm string            `json:"signatureAlgorithm" yaml:"signatureAlgorithm"`
+	Issuer             nameInfo          `json:"issuer" yaml:"issuer"`
+	Subject            nameInfo          `json:"subject" yaml:"subject"`
+	Validity           validityInfo      `json:"validity" yaml:"validity"`
+	PublicKey          publicKeyInfo     `json:"publicKey" yaml:"publicKey"`
+	SANs               sansInfo          `json:"sans" yaml:"sans"`
+	KeyUsage           []string          `json:"keyUsage" yaml:"keyUsage"`
+	ExtKeyUsage        []string          `json:"extKeyUsage" yaml:"extKeyUsage"`
+	BasicConstraints   *basicConstraints `json:"basicConstraints" yaml:"basicConstraints"`
+	SubjectKeyID       string            `json:"subjectKeyId" yaml:"subjectKeyId"`
+	AuthorityKeyID     string            `json:"authorityKeyId" yaml:"authorityKeyId"`
+	CRLURLs            []string          `json:"crlDistributionPoints" yaml:"crlDistributionPoints"`
+	OCSPURLs           []string          `json:"ocspServers" yaml:"ocspServers"`
+	IssuerURLs         []string          `json:"issuingCertificateURLs" yaml:"issuingCertificateURLs"`
+	Fingerprints       fingerprints      `json:"fingerprints" yaml:"fingerprints"`
+	SPKIPin            string            `json:"spkiPin" yaml:"spkiPin"`
+}
+
+// certificateRequestInfo is the JSON and YAML schema of a CSR
+type certificateRequestInfo struct {
+	Version            int           `json:"version" yaml:"version"`
+	SignatureAlgorithm string        `json:"signatureAlgorithm" yaml:"signatureAlgorithm"`
+	Subject            nameInfo      `json:"subject" yaml:"subject"`
+	PublicKey          publicKeyInfo `json:"publicKey" yaml:"publicKey"`
+	SANs               sansInfo      `json:"sans" yaml:"sans"`
+	SPKIPin            string        `json:"spkiPin" yaml:"spkiPin"`
+}
+
+// nameInfo is a distinguished name as string and as list of RDNs in order
+type nameInfo struct {
+	String string    `json:"string" yaml:"string"`
+	RDNs   []rdnInfo `json:"rdns" yaml:"rdns"`
+}
+
+// rdnInfo is an attribute of distinguished name. Type is a short name
+// like "CN" for well known attributes, otherwise dotted OID.
+type rdnInfo struct {
+	Type  string `json:"type" yaml:"type"`
+	Value string `json:"value" yaml:"value"`
+}
+
+type validityInfo struct {
+	NotBefore time.Time `json:"notBefore" yaml:"notBefore"`
+	NotAfter  time.Time `json:"notAfter" yaml:"notAfter"`
+}
+
+type publicKeyInfo struct {
+	Algorithm string `json:"algorithm" yaml:"algorithm"`
+	Size      int    `json:"size" yaml:"size"`
+	Curve     string `json:"curve,omitempty" yaml:"curve,omitempty"`
+}
+
+type sansInfo struct {
+	DNSNames       []string `json:"dnsNames" yaml:"dnsNames"`
+	IPAddresses    []string `json:"ipAddresses" yaml:"ipAddresses"`
+	EmailAddresses []string `json:"emailAddresses" yaml:"emailAddresses"`
+	URIs           []string `json:"uris" yaml:"uris"`
+}
+
+type basicConstraints struct {
+	CA      bool `json:"ca" yaml:"ca"`
+	PathLen *int `json:"pathLen" yaml:"pathLen"`
+}
+
+type fingerprints struct {
+	SHA1   string `json:"sha1" yaml:"sha1"`
+	SHA256 string `json:"sha256" yaml:"sha256"`
+}
+
+// attributeTypeNames are short names of well known distinguished name attributes
+var attributeTypeNames = map[string]string{
+	"2.5.4.3":              "CN",
+	"2.5.4.5":              "serialNumber",
+	"2.5.4.6":              "C",
+	"2.5.4.7":              "L",
+	"2.5.4.8":              "ST",
+	"2.5.4.9":              "street",
+	"2.5.4.10":             "O",
+	"2.5.4.11":             "OU",
+	"2.5.4.17":             "postalCode",
+	"1.2.840.113549.1.9.1": "emailAddress",
+}
+
+// keyUsageNames are OpenSSL style names of key usages in bit order
+var keyUsageNames = []struct {
+	usage x509.KeyUsage
+	name  string
+}{
+	{x509.KeyUsageDigitalSignature, "digitalSignature"},
+	{x509.KeyUsageContentCommitment, "contentCommitment"},
+	{x509.KeyUsageKeyEncipherment, "keyEncipherment"},
+	{x509.KeyUsageDataEncipherment, "dataEncipherment"},
+	{x509.KeyUsageKeyAgreement, "keyAgreement"},
+	{x509.KeyUsageCertSign, "keyCertSign"},
+	{x509.KeyUsageCRLSign, "cRLSign"},
+	{x509.KeyUsageEncipherOnly, "encipherOnly"},
+	{x509.KeyUsageDecipherOnly, "decipherOnly"},
+}
+
+// extKeyUsageNames are OpenSSL style names of extended key usages
+var extKeyUsageNames = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageAny:                            "any",
+	x509.ExtKeyUsageServerAuth:                     "serverAuth",
+	x509.ExtKeyUsageClientAuth:                     "clientAuth",
+	x509.ExtKeyUsageCodeSigning:                    "codeSigning",
+	x509.ExtKeyUsageEmailProtection:                "emailProtection",
+	x509.ExtKeyUsageIPSECEndSystem:                 "ipsecEndSystem",
+	x509.ExtKeyUsageIPSECTunnel:                    "ipsecTunnel",
+	x509.ExtKeyUsageIPSECUser:                      "ipsecUser",
+	x509.ExtKeyUsageTimeStamping:                   "timeStamping",
+	x509.ExtKeyUsageOCSPSigning:                    "OCSPSigning",
+	x509.ExtKeyUsageMicrosoftServerGatedCrypto:     "msSGC",
+	x509.ExtKeyUsageNetscapeServerGatedCrypto:      "nsSGC",
+	x509.ExtKeyUsageMicrosoftCommercialCodeSigning: "msCodeCom",
+	x509.ExtKeyUsageMicrosoftKernelCodeSigning:     "msKernelCodeSigning",
+}
+
+func newCertificateInfo(cert *x509.Certificate) certificateInfo {
+	sha1Sum := sha1.Sum(cert.Raw)
+	sha256Sum := sha256.Sum256(cert.Raw)
+
+	info := certificateInfo{
+		Version:            cert.Version,
+		SerialNumber:       hex.EncodeToString(cert.SerialNumber.Bytes()),
+		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+		Issuer:             newNameInfo(cert.Issuer),
+		Subject:            newNameInfo(cert.Subject),
+		Validity: validityInfo{
+			NotBefore: cert.NotBefore.UTC(),
+			NotAfter:  cert.NotAfter.UTC(),
+		},
+		PublicKey:      newPublicKeyInfo(cert.PublicKey),
+		SANs:           newSANsInfo(cert.DNSNames, cert.IPAddresses, cert.EmailAddresses, cert.URIs),
+		KeyUsage:       []string{},
+		ExtKeyUsage:    []string{},
+		SubjectKeyID:   hex.EncodeToString(cert.SubjectKeyId),
+		AuthorityKeyID: hex.EncodeToString(cert.AuthorityKeyId),
+		CRLURLs:        nonNil(cert.CRLDistributionPoints),
+		OCSPURLs:       nonNil(cert.OCSPServer),
+		IssuerURLs:     nonNil(cert.IssuingCertificateURL),
+		Fingerprints: fingerprints{
+			SHA1:   hex.EncodeToString(sha1Sum[:]),
+			SHA256: hex.EncodeToString(sha256Sum[:]),
+		},
+		SPKIPin: spkiPin(cert.RawSubjectPublicKeyInfo),
+	}
+
+	for _, ku := range keyUsageNames {
+		if cert.KeyUsage&ku.usage != 0 {
+			info.KeyUsage = append(info.KeyUsage, ku.name)
+		}
+	}
+
+	for _, eku := range cert.ExtKeyUsage {
+		name, ok := extKeyUsageNames[eku]
+		if !ok {
+			name = fmt.Sprintf("unknown(%d)", eku)
+		}
+		info.ExtKeyUsage = append(info.ExtKeyUsage, name)
+	}
+	for _, oid := range cert.UnknownExtKeyUsage {
+		info.ExtKeyUsage = append(info.ExtKeyUsage, oid.String())
+	}
+
+	if cert.BasicConstraintsValid {
+		info.BasicConstraints = &basicConstraints{CA: cert.IsCA}
+		if cert.IsCA && (cert.MaxPathLen > 0 || cert.MaxPathLenZero) {
+			pathLen := cert.MaxPathLen
+			info.BasicConstraints.PathLen = &pathLen
+		}
+	}
+
+	return info
+}
+
+func newCertificateRequestInfo(csr *x509.CertificateRequest) certificateRequestInfo {
+	return certificateRequestInfo{
+		Version:            csr.Version,
+		SignatureAlgorithm: csr.SignatureAlgorithm.String(),
+		Subject:            newNameInfo(csr.Subject),
+		PublicKey:          newPublicKeyInfo(csr.PublicKey),
+		SANs:               newSANsInfo(csr.DNSNames, csr.IPAddresses, csr.EmailAddresses, csr.URIs),
+		SPKIPin:            spkiPin(csr.RawSubjectPublicKeyInfo),
+	}
+}
+
+func newNameInfo(name pkix.Name) nameInfo {
+	info := nameInfo{String: name.String(), RDNs: []rdnInfo{}}
+
+	// Names contains all attributes in order of the certificate
+	for _, attr := range name.Names {
+		attrType, ok := attributeTypeNames[attr.Type.String()]
+		if !ok {
+			attrType = attr.Type.String()
+		}
+		info.RDNs = append(info.RDNs, rdnInfo{Type: attrType, Value: fmt.Sprint(attr.Value)})
+	}
+
+	return info
+}
+
+func newPublicKeyInfo(pub interface{}) publicKeyInfo {
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		return publicKeyInfo{Algorithm: "RSA", Size: key.N.BitLen()}
+	case *ecdsa.PublicKey:
+		params := key.Curve.Params()
+		return publicKeyInfo{Algorithm: "ECDSA", Size: params.BitSize, Curve: params.Name}
+	case ed25519.PublicKey:
+		return publicKeyInfo{Algorithm: "Ed25519", Size: 256}
+	default:
+		return publicKeyInfo{Algorithm: "unknown"}
+	}
+}
+
+func newSANsInfo(dnsNames []string, ips []net.IP, emails []string, uris []*url.URL) sansInfo {
+	info := sansInfo{
+		DNSNames:       nonNil(dnsNames),
+		IPAddresses:    []string{},
+		EmailAddresses: nonNil(emails),
+		URIs:           []string{},
+	}
+	for _, ip := range ips {
+		info.IPAddresses = append(info.IPAddresses, ip.String())
+	}
+	for _, uri := range uris {
+		info.URIs = append(info.URIs, uri.String())
+	}
+
+	return info
+}
+
+// spkiPin returns base64 encoded SHA-256 hash of SubjectPublicKeyInfo (RFC 7469)
+func spkiPin(rawSPKI []byte) string {
+	sum := sha256.Sum256(rawSPKI)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// nonNil returns an empty slice instead of nil to have stable [] output
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+
+	return values
+}
+
+// formatCertificate returns cert in text, JSON or YAML format
+func formatCertificate(format string, cert *x509.Certificate) (string, error) {
+	if format == formatText {
+		return certificateText(cert)
+	}
+
+	return marshal(format, newCertificateInfo(cert))
+}
+
+// formatCertificateRequest returns csr in text, JSON or YAML format
+func formatCertificateRequest(format string, csr *x509.CertificateRequest) (string, error) {
+	if format == formatText {
+		return certificateRequestText(csr)
+	}
+
+	return marshal(format, newCertificateRequestInfo(csr))
+}
+
+// marshal encodes v in JSON or YAML format
+func marshal(format string, v interface{}) (string, error) {
+	switch format {
+	case formatJSON:
+		out, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(out) + "\n", nil
+	case formatYAML:
+		var out strings.Builder
+		enc := yaml.NewEncoder(&out)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return "", err
+		}
+		return out.String(), nil
+	default:
+		return "", fmt.Errorf("unsupported format %q", format)
+	}
+}
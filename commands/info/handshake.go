@@ -0,0 +1,320 @@
+package info
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yakuter/gossl/pkg/probe"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// handshakeInfo is the JSON and YAML schema of a TLS connection
+type handshakeInfo struct {
+	Host          string            `json:"host" yaml:"host"`
+	ServerName    string            `json:"serverName" yaml:"serverName"`
+	TLSVersion    string            `json:"tlsVersion" yaml:"tlsVersion"`
+	CipherSuite   string            `json:"cipherSuite" yaml:"cipherSuite"`
+	ALPN          string            `json:"alpn" yaml:"alpn"`
+	OCSPStapling  ocspStaplingInfo  `json:"ocspStapling" yaml:"ocspStapling"`
+	SCTs          []sctInfo         `json:"scts" yaml:"scts"`
+	Chain         chainInfo         `json:"chain" yaml:"chain"`
+	Certificates  []certificateInfo `json:"certificates" yaml:"certificates"`
+	HostnameMatch bool              `json:"hostnameMatch" yaml:"hostnameMatch"`
+}
+
+type ocspStaplingInfo struct {
+	Present    bool       `json:"present" yaml:"present"`
+	Verified   bool       `json:"verified" yaml:"verified"`
+	Status     string     `json:"status,omitempty" yaml:"status,omitempty"`
+	ProducedAt *time.Time `json:"producedAt,omitempty" yaml:"producedAt,omitempty"`
+	NextUpdate *time.Time `json:"nextUpdate,omitempty" yaml:"nextUpdate,omitempty"`
+	Error      string     `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// Sources of SCTs
+const (
+	sctSourceCertificate = "certificate"
+	sctSourceTLS         = "tls"
+)
+
+// oidExtensionSCTList is the extension of SCTs embedded in certificate (RFC 6962 3.3)
+var oidExtensionSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+
+// sctInfo is a Signed Certificate Timestamp (RFC 6962) embedded in leaf
+// certificate or received in TLS extension
+type sctInfo struct {
+	Version   int       `json:"version" yaml:"version"`
+	LogID     string    `json:"logId" yaml:"logId"`
+	Timestamp time.Time `json:"timestamp" yaml:"timestamp"`
+	Source    string    `json:"source" yaml:"source"`
+}
+
+// chainInfo describes whether certificates sent by server are ordered from
+// leaf to root and whether they are enough to build a chain to a trusted root
+type chainInfo struct {
+	Ordered    bool   `json:"ordered" yaml:"ordered"`
+	OrderError string `json:"orderError,omitempty" yaml:"orderError,omitempty"`
+	Complete   bool   `json:"complete" yaml:"complete"`
+	Error      string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// newHandshakeInfo returns details of TLS connection to host (host:port) with
+// serverName. Sent chain is verified with roots, or with system roots if roots is nil.
+func newHandshakeInfo(host, serverName string, state tls.ConnectionState, roots *x509.CertPool) handshakeInfo {
+	info := handshakeInfo{
+		Host:         host,
+		ServerName:   serverName,
+		TLSVersion:   probe.VersionName(state.Version),
+		CipherSuite:  tls.CipherSuiteName(state.CipherSuite),
+		ALPN:         state.NegotiatedProtocol,
+		SCTs:         []sctInfo{},
+		Certificates: []certificateInfo{},
+	}
+
+	certs := state.PeerCertificates
+	for _, cert := range certs {
+		info.Certificates = append(info.Certificates, newCertificateInfo(cert))
+	}
+
+	if len(state.OCSPResponse) > 0 {
+		info.OCSPStapling = newOCSPStaplingInfo(state.OCSPResponse, certs)
+	}
+
+	if len(certs) == 0 {
+		info.Chain.Error = "no certificate sent by server"
+		return info
+	}
+
+	// Most certificates embed their SCTs, others are sent in TLS extension
+	embedded, _ := embeddedSCTs(certs[0])
+	info.SCTs = append(info.SCTs, parseSCTs(embedded, sctSourceCertificate)...)
+	info.SCTs = append(info.SCTs, parseSCTs(state.SignedCertificateTimestamps, sctSourceTLS)...)
+
+	info.Chain.Ordered = true
+	if err := checkChainOrder(certs); err != nil {
+		info.Chain.Ordered = false
+		info.Chain.OrderError = err.Error()
+	}
+
+	// Chain is complete if sent intermediates are enough to reach a trusted root
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+	info.Chain.Complete = err == nil
+	if err != nil {
+		info.Chain.Error = err.Error()
+	}
+
+	info.HostnameMatch = certs[0].VerifyHostname(serverName) == nil
+
+	return info
+}
+
+// checkChainOrder checks that every certificate is issued by the next one
+func checkChainOrder(certs []*x509.Certificate) error {
+	for i := 0; i < len(certs)-1; i++ {
+		if err := certs[i].CheckSignatureFrom(certs[i+1]); err != nil {
+			return fmt.Errorf("certificate %d is not issued by certificate %d: %v", i, i+1, err)
+		}
+	}
+
+	return nil
+}
+
+// newOCSPStaplingInfo parses stapled OCSP response of the leaf in certs. Response
+// is verified only if the issuer of the leaf is sent, otherwise it is reported
+// as unverified.
+func newOCSPStaplingInfo(staple []byte, certs []*x509.Certificate) ocspStaplingInfo {
+	info := ocspStaplingInfo{Present: true}
+
+	var (
+		resp *ocsp.Response
+		err  error
+	)
+	if len(certs) > 1 {
+		resp, err = ocsp.ParseResponseForCert(staple, certs[0], certs[1])
+		// Delegated responder must be authorized for OCSP signing (RFC 6960 4.2.2.2)
+		if err == nil && resp.Certificate != nil && !hasExtKeyUsage(resp.Certificate, x509.ExtKeyUsageOCSPSigning) {
+			err = errors.New("OCSP responder certificate does not have OCSPSigning extended key usage")
+		}
+		info.Verified = err == nil
+	} else {
+		resp, err = ocsp.ParseResponse(staple, nil)
+	}
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+
+	info.Status = ocspStatus(resp.Status)
+	info.ProducedAt = &resp.ProducedAt
+	info.NextUpdate = &resp.NextUpdate
+
+	return info
+}
+
+func hasExtKeyUsage(cert *x509.Certificate, usage x509.ExtKeyUsage) bool {
+	for _, u := range cert.ExtKeyUsage {
+		if u == usage {
+			return true
+		}
+	}
+
+	return false
+}
+
+func ocspStatus(status int) string {
+	switch status {
+	case ocsp.Good:
+		return "good"
+	case ocsp.Revoked:
+		return "revoked"
+	default:
+		return "unknown"
+	}
+}
+
+// embeddedSCTs returns serialized SCTs of the SCT list extension of cert
+func embeddedSCTs(cert *x509.Certificate) ([][]byte, error) {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidExtensionSCTList) {
+			continue
+		}
+
+		// TLS encoded SignedCertificateTimestampList in an OCTET STRING
+		var list []byte
+		if rest, err := asn1.Unmarshal(ext.Value, &list); err != nil || len(rest) > 0 {
+			return nil, errors.New("invalid SCT list extension")
+		}
+		if len(list) < 2 || int(binary.BigEndian.Uint16(list)) != len(list)-2 {
+			return nil, errors.New("invalid SCT list length")
+		}
+
+		var scts [][]byte
+		for rest := list[2:]; len(rest) > 0; {
+			if len(rest) < 2 {
+				return nil, errors.New("invalid SCT length")
+			}
+			n := int(binary.BigEndian.Uint16(rest))
+			if len(rest) < 2+n {
+				return nil, errors.New("invalid SCT length")
+			}
+			scts = append(scts, rest[2:2+n])
+			rest = rest[2+n:]
+		}
+
+		return scts, nil
+	}
+
+	return nil, nil
+}
+
+// parseSCTs parses serialized SCTs from source, invalid ones are skipped
+func parseSCTs(raws [][]byte, source string) []sctInfo {
+	var scts []sctInfo
+	for _, raw := range raws {
+		sct, err := parseSCT(raw)
+		if err != nil {
+			continue
+		}
+		sct.Source = source
+		scts = append(scts, sct)
+	}
+
+	return scts
+}
+
+// parseSCT parses version, log ID and timestamp of a serialized SCT (RFC 6962 3.2)
+func parseSCT(raw []byte) (sctInfo, error) {
+	// version (1) + log ID (32) + timestamp (8)
+	if len(raw) < 41 {
+		return sctInfo{}, errors.New("SCT is too short")
+	}
+
+	ms := binary.BigEndian.Uint64(raw[33:41])
+	return sctInfo{
+		Version:   int(raw[0]) + 1,
+		LogID:     base64.StdEncoding.EncodeToString(raw[1:33]),
+		Timestamp: time.UnixMilli(int64(ms)).UTC(),
+	}, nil
+}
+
+// handshakeText returns human-readable text of TLS connection details
+// followed by all certificates sent by server
+func handshakeText(info handshakeInfo, certs []*x509.Certificate) (string, error) {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "TLS Handshake:\n")
+	fmt.Fprintf(&b, "    Host: %s\n", info.Host)
+	fmt.Fprintf(&b, "    Server Name: %s\n", info.ServerName)
+	fmt.Fprintf(&b, "    TLS Version: %s\n", info.TLSVersion)
+	fmt.Fprintf(&b, "    Cipher Suite: %s\n", info.CipherSuite)
+	fmt.Fprintf(&b, "    ALPN Protocol: %s\n", valueOrNone(info.ALPN))
+
+	switch {
+	case !info.OCSPStapling.Present:
+		fmt.Fprintf(&b, "    OCSP Stapling: none\n")
+	case info.OCSPStapling.Error != "":
+		fmt.Fprintf(&b, "    OCSP Stapling: invalid response (%s)\n", info.OCSPStapling.Error)
+	default:
+		status := info.OCSPStapling.Status
+		if !info.OCSPStapling.Verified {
+			status += ", unverified"
+		}
+		fmt.Fprintf(&b, "    OCSP Stapling: %s (produced at %s, next update %s)\n", status,
+			info.OCSPStapling.ProducedAt.UTC().Format(time.RFC3339), info.OCSPStapling.NextUpdate.UTC().Format(time.RFC3339))
+	}
+
+	fmt.Fprintf(&b, "    Signed Certificate Timestamps: %d\n", len(info.SCTs))
+	for _, sct := range info.SCTs {
+		fmt.Fprintf(&b, "        Log ID: %s Timestamp: %s Source: %s\n", sct.LogID, sct.Timestamp.Format(time.RFC3339), sct.Source)
+	}
+
+	if info.Chain.Ordered {
+		fmt.Fprintf(&b, "    Chain Order: correct\n")
+	} else {
+		fmt.Fprintf(&b, "    Chain Order: incorrect (%s)\n", info.Chain.OrderError)
+	}
+	if info.Chain.Complete {
+		fmt.Fprintf(&b, "    Chain Complete: yes\n")
+	} else {
+		fmt.Fprintf(&b, "    Chain Complete: no (%s)\n", info.Chain.Error)
+	}
+	fmt.Fprintf(&b, "    Hostname Match: %s\n", yesNo(info.HostnameMatch))
+
+	for i, cert := range certs {
+		text, err := certificateText(cert)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "\nCertificate %d:\n    Subject: %s\n    Issuer: %s\n%s", i, cert.Subject, cert.Issuer, text)
+	}
+
+	return b.String(), nil
+}
+
+func valueOrNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+
+	return s
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+
+	return "no"
+}
@@ -6,22 +6,26 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"net/url"
 	"os"
 
+	"github.com/yakuter/gossl/pkg/probe"
 	"github.com/yakuter/gossl/pkg/utils"
 
-	"github.com/grantae/certinfo"
 	"github.com/urfave/cli/v2"
 )
 
 const (
 	CmdInfo = "info"
 
-	flagOut  = "out"
-	flagURL  = "url"
-	flagCert = "cert"
-	flagCSR  = "csr"
+	flagOut    = "out"
+	flagURL    = "url"
+	flagCert   = "cert"
+	flagCSR    = "csr"
+	flagCRL    = "crl"
+	flagFormat = "format"
+	flagChain  = "chain"
+	flagEnum   = "enum"
+	flagCAFile = "cafile"
 )
 
 func Command() *cli.Command {
@@ -37,7 +41,7 @@ func Command() *cli.Command {
 }
 
 func Flags() []cli.Flag {
-	return []cli.Flag{
+	flags := []cli.Flag{
 		&cli.StringFlag{
 			Name:        flagOut,
 			Usage:       "Output file name (optional)",
@@ -62,16 +66,54 @@ func Flags() []cli.Flag {
 			DefaultText: "eg, server.csr",
 			Required:    false,
 		},
+		&cli.StringFlag{
+			Name:        flagCRL,
+			Usage:       "Certificate Revocation List (CRL) in PEM or DER format to get details from (optional)",
+			DefaultText: "eg, ca.crl",
+			Required:    false,
+		},
+		&cli.StringFlag{
+			Name:        flagFormat,
+			Usage:       "Output format (text, json or yaml)",
+			DefaultText: formatText,
+			Value:       formatText,
+			Required:    false,
+		},
+		&cli.BoolFlag{
+			Name:     flagChain,
+			Usage:    "Show all certificates sent by server and TLS handshake details, used with url flag (optional)",
+			Required: false,
+		},
+		&cli.BoolFlag{
+			Name:     flagEnum,
+			Usage:    "Try each TLS version, cipher suite and named group, used with url flag (optional)",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:        flagCAFile,
+			Usage:       "CA file to check if chain sent by server is complete, system roots are used by default (optional)",
+			DefaultText: "eg, ca-cert.pem",
+			Required:    false,
+		},
 	}
+
+	return append(flags, probe.Flags()...)
 }
 
 func Action(c *cli.Context) error {
-	if !c.IsSet(flagURL) && !c.IsSet(flagCert) && !c.IsSet(flagCSR) {
+	if !c.IsSet(flagURL) && !c.IsSet(flagCert) && !c.IsSet(flagCSR) && !c.IsSet(flagCRL) {
 		err := errors.New("no flag provided")
 		log.Printf("Failed to get cert resource error: %v", err)
 		return err
 	}
 
+	format := c.String(flagFormat)
+	if format != formatText && format != formatJSON && format != formatYAML {
+		err := fmt.Errorf("unsupported format %q", format)
+		log.Printf("%v", err)
+		return err
+	}
+
 	// Set output
 	output := os.Stdout
 	outputFilePath := output.Name()
@@ -84,18 +126,51 @@ func Action(c *cli.Context) error {
 		result string
 		cert   *x509.Certificate
 		csr    *x509.CertificateRequest
+		opts   *probe.Options
 	)
 
 	if c.IsSet(flagURL) {
+		opts, err = probe.OptionsFromContext(c, utils.StdinPasswordReader{Prompt: "Enter pass phrase: "})
+		if err != nil {
+			log.Printf("Failed to get connection options error: %v", err)
+			return err
+		}
+	}
+
+	if c.Bool(flagChain) && c.Bool(flagEnum) {
+		err = errors.New("chain and enum flags cannot be used together")
+		log.Printf("%v", err)
+		return err
+	}
+
+	if c.IsSet(flagURL) && c.Bool(flagEnum) {
+		u := c.String(flagURL)
+		result, err = enumerateDomain(u, format, opts)
+		if err != nil {
+			log.Printf("failed to enumerate TLS versions and cipher suites of URL %q error: %v", u, err)
+			return err
+		}
+	}
+
+	if c.IsSet(flagURL) && c.Bool(flagChain) {
+		u := c.String(flagURL)
+		result, err = handshakeFromDomain(u, c.String(flagCAFile), format, opts)
+		if err != nil {
+			log.Printf("failed to get TLS handshake details from URL %q error: %v", u, err)
+			return err
+		}
+	}
+
+	if c.IsSet(flagURL) && !c.Bool(flagChain) && !c.Bool(flagEnum) {
 		u := c.String(flagURL)
-		cert, err = readX509FromDomain(u)
+		cert, err = readX509FromDomain(u, opts)
 		if err != nil {
 			log.Printf("failed to get cert details from URL %q error: %v", u, err)
 			return err
 		}
 
 		// Print the certificate
-		result, err = certinfo.CertificateText(cert)
+		result, err = formatCertificate(format, cert)
 		if err != nil {
 			log.Printf("Failed to get cert info from URL error: %v", err)
 			return err
@@ -111,7 +186,7 @@ func Action(c *cli.Context) error {
 		}
 
 		// Print the certificate
-		result, err = certinfo.CertificateText(cert)
+		result, err = formatCertificate(format, cert)
 		if err != nil {
 			log.Printf("Failed to get cert info from cert file %q error: %v", path, err)
 			return err
@@ -127,13 +202,29 @@ func Action(c *cli.Context) error {
 		}
 
 		// Print the certificate
-		result, err = certinfo.CertificateRequestText(csr)
+		result, err = formatCertificateRequest(format, csr)
 		if err != nil {
 			log.Printf("Failed to get CSR info from cert error: %v", err)
 			return err
 		}
 	}
 
+	if c.IsSet(flagCRL) {
+		path := c.String(flagCRL)
+		crl, err := utils.CRLFromFile(path)
+		if err != nil {
+			log.Printf("failed to get CRL from file %q: %v", path, err)
+			return err
+		}
+
+		// Print the CRL
+		result, err = formatCRL(format, crl)
+		if err != nil {
+			log.Printf("Failed to get CRL info from CRL file %q error: %v", path, err)
+			return err
+		}
+	}
+
 	if err = os.WriteFile(outputFilePath, []byte(result), 0o600); err != nil {
 		log.Printf("Failed to write PEM to file %s error: %v", outputFilePath, err)
 		return err
@@ -141,40 +232,95 @@ func Action(c *cli.Context) error {
 	return nil
 }
 
-func readX509FromDomain(uri string) (*x509.Certificate, error) {
-	u, err := url.Parse(uri)
+func readX509FromDomain(uri string, opts *probe.Options) (*x509.Certificate, error) {
+	addr, err := opts.HostPort(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := connectionState(addr, &tls.Config{}, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	// if no schema is used, parse with default scheme //
-	// to get the host name
-	if u.Host == "" {
-		uri = "//" + uri
-		u, err = url.Parse(uri)
+	// Get certificates returned from the server
+	certs := state.PeerCertificates
+	if len(certs) > 0 {
+		// Return the first certificate
+		return certs[0], nil
+	}
+
+	return nil, fmt.Errorf("no certificate returned from %q", addr)
+}
+
+// handshakeFromDomain returns TLS handshake details and all certificates sent by
+// server in text, JSON or YAML format. Chain is verified with CA file if provided.
+func handshakeFromDomain(uri, caFile, format string, opts *probe.Options) (string, error) {
+	addr, err := opts.HostPort(uri)
+	if err != nil {
+		return "", err
+	}
+
+	var roots *x509.CertPool
+	if caFile != "" {
+		certs, err := utils.CertsFromFile(caFile)
 		if err != nil {
-			return nil, err
+			log.Printf("failed to read CA file %q error: %v", caFile, err)
+			return "", err
+		}
+
+		roots = x509.NewCertPool()
+		for _, cert := range certs {
+			roots.AddCert(cert)
 		}
 	}
 
-	uri = u.Host
-	if u.Port() == "" {
-		uri += ":443"
+	// Verification is skipped to be able to inspect broken chains,
+	// chain is checked separately in handshake details
+	config := opts.TLSConfig(&tls.Config{
+		InsecureSkipVerify: true,
+		NextProtos:         []string{"h2", "http/1.1"},
+	}, addr)
+	state, err := connectionState(addr, config, opts)
+	if err != nil {
+		return "", err
+	}
+
+	info := newHandshakeInfo(opts.Address(addr), config.ServerName, state, roots)
+	if format == formatText {
+		return handshakeText(info, state.PeerCertificates)
 	}
 
-	conn, err := tls.Dial("tcp", uri, &tls.Config{})
+	return marshal(format, info)
+}
+
+// enumerateDomain returns TLS versions, cipher suites and named groups
+// supported by server in text, JSON or YAML format
+func enumerateDomain(uri, format string, opts *probe.Options) (string, error) {
+	addr, err := opts.HostPort(uri)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	defer conn.Close()
+	info, err := enumerate(addr, opts)
+	if err != nil {
+		return "", err
+	}
 
-	// Get certificates returned from the server
-	certs := conn.ConnectionState().PeerCertificates
-	if len(certs) > 0 {
-		// Return the first certificate
-		return certs[0], nil
+	if format == formatText {
+		return enumText(info), nil
+	}
+
+	return marshal(format, info)
+}
+
+func connectionState(addr string, config *tls.Config, opts *probe.Options) (tls.ConnectionState, error) {
+	conn, err := opts.Dial(addr, config)
+	if err != nil {
+		return tls.ConnectionState{}, err
 	}
 
-	return nil, fmt.Errorf("no certificate returned from %q", uri)
+	defer conn.Close()
+
+	return conn.ConnectionState(), nil
 }
@@ -1,14 +1,39 @@
 package info_test
 
 import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/yakuter/gossl/commands/crl"
+	"github.com/yakuter/gossl/commands/generate"
 	"github.com/yakuter/gossl/commands/info"
+	"github.com/yakuter/gossl/pkg/utils"
 
 	"github.com/stretchr/testify/require"
 	"github.com/urfave/cli/v2"
+	"golang.org/x/crypto/ocsp"
+	"gopkg.in/yaml.v3"
 )
 
 func TestInfo(t *testing.T) {
@@ -158,3 +183,741 @@ func TestInfo(t *testing.T) {
 		})
 	}
 }
+
+func TestInfoEd25519(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "ed25519.test"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"ed25519.test"},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, pub, priv)
+	require.NoError(t, err)
+
+	tempDir := t.TempDir()
+	certFile := filepath.Join(tempDir, "ed25519.pem")
+	outFile := filepath.Join(tempDir, "ed25519.txt")
+	require.NoError(t, os.WriteFile(certFile, utils.CertToPEM(der), 0o600))
+
+	app := &cli.App{
+		Commands: []*cli.Command{
+			info.Command(),
+		},
+	}
+
+	execName, err := os.Executable()
+	require.NoError(t, err)
+
+	require.NoError(t, app.Run([]string{execName, info.CmdInfo, "--cert", certFile, "--out", outFile}))
+
+	text, err := os.ReadFile(outFile)
+	require.NoError(t, err)
+	require.Contains(t, string(text), "Subject: CN=ed25519.test\n")
+	require.Contains(t, string(text), "Public Key Algorithm: Ed25519")
+	require.Contains(t, string(text), "DNS:ed25519.test")
+	require.Contains(t, string(text), "Digital Signature")
+	require.Contains(t, string(text), "Signature Algorithm: Ed25519")
+	require.NotContains(t, string(text), "Public Key Algorithm: RSA")
+}
+
+func TestInfoFormat(t *testing.T) {
+	execName, err := os.Executable()
+	require.NoError(t, err)
+
+	// Leaf certificate signed by intermediate CA
+	pkiDir := t.TempDir()
+	app := &cli.App{
+		Commands: []*cli.Command{
+			generate.Command(),
+			info.Command(),
+		},
+	}
+	require.NoError(t, app.Run([]string{execName, generate.CmdGenerate,
+		"--out", pkiDir, "--type", "ecdsa", "--intermediate", "--leaf", "api.example.com+10.0.0.1"}))
+
+	leafFile := filepath.Join(pkiDir, "api.example.com-cert.pem")
+	leaf, err := utils.CertFromFile(leafFile)
+	require.NoError(t, err)
+	sha256Sum := sha256.Sum256(leaf.Raw)
+
+	type certInfo struct {
+		SerialNumber string `json:"serialNumber" yaml:"serialNumber"`
+		Subject      struct {
+			String string `json:"string" yaml:"string"`
+			RDNs   []struct {
+				Type  string `json:"type" yaml:"type"`
+				Value string `json:"value" yaml:"value"`
+			} `json:"rdns" yaml:"rdns"`
+		} `json:"subject" yaml:"subject"`
+		Issuer struct {
+			String string `json:"string" yaml:"string"`
+		} `json:"issuer" yaml:"issuer"`
+		Validity struct {
+			NotAfter time.Time `json:"notAfter" yaml:"notAfter"`
+		} `json:"validity" yaml:"validity"`
+		PublicKey struct {
+			Algorithm string `json:"algorithm" yaml:"algorithm"`
+			Size      int    `json:"size" yaml:"size"`
+			Curve     string `json:"curve" yaml:"curve"`
+		} `json:"publicKey" yaml:"publicKey"`
+		SANs struct {
+			DNSNames    []string `json:"dnsNames" yaml:"dnsNames"`
+			IPAddresses []string `json:"ipAddresses" yaml:"ipAddresses"`
+		} `json:"sans" yaml:"sans"`
+		KeyUsage         []string `json:"keyUsage" yaml:"keyUsage"`
+		ExtKeyUsage      []string `json:"extKeyUsage" yaml:"extKeyUsage"`
+		BasicConstraints struct {
+			CA bool `json:"ca" yaml:"ca"`
+		} `json:"basicConstraints" yaml:"basicConstraints"`
+		AuthorityKeyID string `json:"authorityKeyId" yaml:"authorityKeyId"`
+		Fingerprints   struct {
+			SHA256 string `json:"sha256" yaml:"sha256"`
+		} `json:"fingerprints" yaml:"fingerprints"`
+		SPKIPin string `json:"spkiPin" yaml:"spkiPin"`
+	}
+
+	for _, format := range []string{"json", "yaml"} {
+		t.Run(format+" certificate", func(t *testing.T) {
+			outFile := filepath.Join(t.TempDir(), "info."+format)
+			require.NoError(t, app.Run([]string{execName, info.CmdInfo, "--cert", leafFile, "--format", format, "--out", outFile}))
+
+			data, err := os.ReadFile(outFile)
+			require.NoError(t, err)
+
+			var got certInfo
+			if format == "json" {
+				require.NoError(t, json.Unmarshal(data, &got))
+			} else {
+				require.NoError(t, yaml.Unmarshal(data, &got))
+			}
+
+			require.Equal(t, leaf.SerialNumber.Text(16), strings.TrimLeft(got.SerialNumber, "0"))
+			require.Equal(t, "CN=api.example.com", got.Subject.String)
+			require.Equal(t, "CN", got.Subject.RDNs[0].Type)
+			require.Equal(t, "api.example.com", got.Subject.RDNs[0].Value)
+			require.Equal(t, "CN=GoSSL Root CA Intermediate", got.Issuer.String)
+			require.True(t, leaf.NotAfter.Equal(got.Validity.NotAfter))
+			require.Equal(t, "ECDSA", got.PublicKey.Algorithm)
+			require.Equal(t, 256, got.PublicKey.Size)
+			require.Equal(t, "P-256", got.PublicKey.Curve)
+			require.Equal(t, []string{"api.example.com"}, got.SANs.DNSNames)
+			require.Equal(t, []string{"10.0.0.1"}, got.SANs.IPAddresses)
+			require.Equal(t, []string{"digitalSignature"}, got.KeyUsage)
+			require.Equal(t, []string{"serverAuth", "clientAuth"}, got.ExtKeyUsage)
+			require.False(t, got.BasicConstraints.CA)
+			require.NotEmpty(t, got.AuthorityKeyID)
+			require.Equal(t, hex.EncodeToString(sha256Sum[:]), got.Fingerprints.SHA256)
+			require.NotEmpty(t, got.SPKIPin)
+		})
+	}
+
+	t.Run("json CSR", func(t *testing.T) {
+		outFile := filepath.Join(t.TempDir(), "csr.json")
+		require.NoError(t, app.Run([]string{execName, info.CmdInfo, "--csr", "../../testdata/server-req.pem", "--format", "json", "--out", outFile}))
+
+		data, err := os.ReadFile(outFile)
+		require.NoError(t, err)
+
+		var got certInfo
+		require.NoError(t, json.Unmarshal(data, &got))
+		require.Equal(t, "RSA", got.PublicKey.Algorithm)
+		require.Equal(t, 2048, got.PublicKey.Size)
+		require.Equal(t, []string{"127.0.0.1"}, got.SANs.IPAddresses)
+	})
+
+	t.Run("unsupported format error", func(t *testing.T) {
+		require.Error(t, app.Run([]string{execName, info.CmdInfo, "--cert", leafFile, "--format", "xml"}))
+	})
+}
+
+func TestInfoChain(t *testing.T) {
+	execName, err := os.Executable()
+	require.NoError(t, err)
+
+	// Leaf certificate for localhost signed by intermediate CA
+	pkiDir := t.TempDir()
+	app := &cli.App{
+		Commands: []*cli.Command{
+			generate.Command(),
+			info.Command(),
+		},
+	}
+	require.NoError(t, app.Run([]string{execName, generate.CmdGenerate,
+		"--out", pkiDir, "--type", "ecdsa", "--intermediate", "--leaf", "localhost+127.0.0.1"}))
+
+	caFile := filepath.Join(pkiDir, "ca-cert.pem")
+	leafPair, err := tls.LoadX509KeyPair(filepath.Join(pkiDir, "localhost-cert.pem"), filepath.Join(pkiDir, "localhost-key.pem"))
+	require.NoError(t, err)
+	intermediatePair, err := tls.LoadX509KeyPair(filepath.Join(pkiDir, "intermediate-cert.pem"), filepath.Join(pkiDir, "intermediate-key.pem"))
+	require.NoError(t, err)
+	rootCert, err := utils.CertFromFile(caFile)
+	require.NoError(t, err)
+
+	leaf, err := x509.ParseCertificate(leafPair.Certificate[0])
+	require.NoError(t, err)
+	intermediate, err := x509.ParseCertificate(intermediatePair.Certificate[0])
+	require.NoError(t, err)
+
+	// OCSP response signed by intermediate CA
+	now := time.Now().UTC().Truncate(time.Second)
+	staple, err := ocsp.CreateResponse(intermediate, intermediate, ocsp.Response{
+		Status:       ocsp.Good,
+		SerialNumber: leaf.SerialNumber,
+		ThisUpdate:   now,
+		NextUpdate:   now.Add(time.Hour),
+	}, intermediatePair.PrivateKey.(crypto.Signer))
+	require.NoError(t, err)
+
+	// OCSP responses for another certificate and signed by leaf which is not an OCSP responder
+	otherStaple, err := ocsp.CreateResponse(intermediate, intermediate, ocsp.Response{
+		Status:       ocsp.Good,
+		SerialNumber: intermediate.SerialNumber,
+		ThisUpdate:   now,
+		NextUpdate:   now.Add(time.Hour),
+	}, intermediatePair.PrivateKey.(crypto.Signer))
+	require.NoError(t, err)
+	leafStaple, err := ocsp.CreateResponse(intermediate, leaf, ocsp.Response{
+		Status:       ocsp.Good,
+		SerialNumber: leaf.SerialNumber,
+		ThisUpdate:   now,
+		NextUpdate:   now.Add(time.Hour),
+		Certificate:  leaf,
+	}, leafPair.PrivateKey.(crypto.Signer))
+	require.NoError(t, err)
+
+	// SCT with version v1, log ID and timestamp followed by empty extensions and signature
+	logID := bytes.Repeat([]byte{0xab}, 32)
+	timestamp := make([]byte, 8)
+	binary.BigEndian.PutUint64(timestamp, uint64(now.UnixMilli()))
+	sct := append(append(append([]byte{0}, logID...), timestamp...), 0, 0, 4, 3, 0, 0)
+
+	// Leaf with the SCT embedded in SignedCertificateTimestampList extension
+	sctList := append([]byte{0, byte(len(sct) + 2), 0, byte(len(sct))}, sct...)
+	sctExtension, err := asn1.Marshal(sctList)
+	require.NoError(t, err)
+	sctTemplate := *leaf
+	sctTemplate.ExtraExtensions = []pkix.Extension{{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}, Value: sctExtension}}
+	sctLeafDER, err := x509.CreateCertificate(rand.Reader, &sctTemplate, intermediate, leaf.PublicKey, intermediatePair.PrivateKey)
+	require.NoError(t, err)
+
+	type handshake struct {
+		TLSVersion   string `json:"tlsVersion"`
+		CipherSuite  string `json:"cipherSuite"`
+		ALPN         string `json:"alpn"`
+		OCSPStapling struct {
+			Present  bool   `json:"present"`
+			Verified bool   `json:"verified"`
+			Status   string `json:"status"`
+			Error    string `json:"error"`
+		} `json:"ocspStapling"`
+		SCTs []struct {
+			LogID     string    `json:"logId"`
+			Timestamp time.Time `json:"timestamp"`
+			Source    string    `json:"source"`
+		} `json:"scts"`
+		Chain struct {
+			Ordered  bool `json:"ordered"`
+			Complete bool `json:"complete"`
+		} `json:"chain"`
+		HostnameMatch bool `json:"hostnameMatch"`
+		Certificates  []struct {
+			Subject struct {
+				String string `json:"string"`
+			} `json:"subject"`
+		} `json:"certificates"`
+	}
+
+	testCases := []struct {
+		name         string
+		chain        [][]byte
+		staple       []byte
+		scts         [][]byte
+		cafile       string
+		subjects     []string
+		ordered      bool
+		complete     bool
+		ocspStatus   string
+		ocspVerified bool
+		ocspError    bool
+		sctSources   []string
+	}{
+		{
+			name:         "complete chain with OCSP staple and SCT",
+			chain:        [][]byte{leaf.Raw, intermediate.Raw},
+			staple:       staple,
+			scts:         [][]byte{sct},
+			cafile:       caFile,
+			subjects:     []string{"CN=localhost", "CN=GoSSL Root CA Intermediate"},
+			ordered:      true,
+			complete:     true,
+			ocspStatus:   "good",
+			ocspVerified: true,
+			sctSources:   []string{"tls"},
+		},
+		{
+			name:       "unverified OCSP staple without issuer",
+			chain:      [][]byte{leaf.Raw},
+			staple:     staple,
+			cafile:     caFile,
+			subjects:   []string{"CN=localhost"},
+			ordered:    true,
+			complete:   false,
+			ocspStatus: "good",
+		},
+		{
+			name:      "OCSP staple for another certificate",
+			chain:     [][]byte{leaf.Raw, intermediate.Raw},
+			staple:    otherStaple,
+			cafile:    caFile,
+			subjects:  []string{"CN=localhost", "CN=GoSSL Root CA Intermediate"},
+			ordered:   true,
+			complete:  true,
+			ocspError: true,
+		},
+		{
+			name:      "OCSP staple signed by leaf",
+			chain:     [][]byte{leaf.Raw, intermediate.Raw},
+			staple:    leafStaple,
+			cafile:    caFile,
+			subjects:  []string{"CN=localhost", "CN=GoSSL Root CA Intermediate"},
+			ordered:   true,
+			complete:  true,
+			ocspError: true,
+		},
+		{
+			name:       "SCT embedded in leaf certificate",
+			chain:      [][]byte{sctLeafDER, intermediate.Raw},
+			cafile:     caFile,
+			subjects:   []string{"CN=localhost", "CN=GoSSL Root CA Intermediate"},
+			ordered:    true,
+			complete:   true,
+			sctSources: []string{"certificate"},
+		},
+		{
+			name:     "missing intermediate",
+			chain:    [][]byte{leaf.Raw},
+			cafile:   caFile,
+			subjects: []string{"CN=localhost"},
+			ordered:  true,
+			complete: false,
+		},
+		{
+			name:     "wrong order",
+			chain:    [][]byte{leaf.Raw, rootCert.Raw, intermediate.Raw},
+			cafile:   caFile,
+			subjects: []string{"CN=localhost", "CN=GoSSL Root CA", "CN=GoSSL Root CA Intermediate"},
+			ordered:  false,
+			complete: true,
+		},
+		{
+			name:     "untrusted root",
+			chain:    [][]byte{leaf.Raw, intermediate.Raw},
+			cafile:   "../../testdata/ca-cert.pem",
+			subjects: []string{"CN=localhost", "CN=GoSSL Root CA Intermediate"},
+			ordered:  true,
+			complete: false,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.name, func(t *testing.T) {
+			ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			ts.EnableHTTP2 = true
+			ts.TLS = &tls.Config{
+				Certificates: []tls.Certificate{{
+					Certificate:                 tC.chain,
+					PrivateKey:                  leafPair.PrivateKey,
+					OCSPStaple:                  tC.staple,
+					SignedCertificateTimestamps: tC.scts,
+				}},
+			}
+			ts.StartTLS()
+			defer ts.Close()
+
+			outFile := filepath.Join(t.TempDir(), "handshake.json")
+			require.NoError(t, app.Run([]string{execName, info.CmdInfo, "--url", ts.URL, "--chain",
+				"--cafile", tC.cafile, "--format", "json", "--out", outFile}))
+
+			data, err := os.ReadFile(outFile)
+			require.NoError(t, err)
+
+			var got handshake
+			require.NoError(t, json.Unmarshal(data, &got))
+
+			require.Equal(t, "TLS 1.3", got.TLSVersion)
+			require.NotEmpty(t, got.CipherSuite)
+			require.Equal(t, "h2", got.ALPN)
+			require.Equal(t, tC.staple != nil, got.OCSPStapling.Present)
+			require.Equal(t, tC.ocspStatus, got.OCSPStapling.Status)
+			require.Equal(t, tC.ocspVerified, got.OCSPStapling.Verified)
+			require.Equal(t, tC.ocspError, got.OCSPStapling.Error != "")
+			require.Len(t, got.SCTs, len(tC.sctSources))
+			for i, source := range tC.sctSources {
+				require.Equal(t, base64.StdEncoding.EncodeToString(logID), got.SCTs[i].LogID)
+				require.True(t, now.Equal(got.SCTs[i].Timestamp))
+				require.Equal(t, source, got.SCTs[i].Source)
+			}
+			require.Equal(t, tC.ordered, got.Chain.Ordered)
+			require.Equal(t, tC.complete, got.Chain.Complete)
+			require.True(t, got.HostnameMatch)
+
+			var subjects []string
+			for _, cert := range got.Certificates {
+				subjects = append(subjects, cert.Subject.String)
+			}
+			require.Equal(t, tC.subjects, subjects)
+		})
+	}
+
+	t.Run("text output", func(t *testing.T) {
+		ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		ts.TLS = &tls.Config{
+			Certificates: []tls.Certificate{{
+				Certificate: [][]byte{leaf.Raw, intermediate.Raw},
+				PrivateKey:  leafPair.PrivateKey,
+			}},
+		}
+		ts.StartTLS()
+		defer ts.Close()
+
+		outFile := filepath.Join(t.TempDir(), "handshake.txt")
+		require.NoError(t, app.Run([]string{execName, info.CmdInfo, "--url", ts.URL, "--chain", "--cafile", caFile, "--out", outFile}))
+
+		data, err := os.ReadFile(outFile)
+		require.NoError(t, err)
+		require.Contains(t, string(data), "TLS Version: TLS 1.3")
+		require.Contains(t, string(data), "ALPN Protocol: http/1.1")
+		require.Contains(t, string(data), "OCSP Stapling: none")
+		require.Contains(t, string(data), "Chain Order: correct")
+		require.Contains(t, string(data), "Chain Complete: yes")
+		require.Contains(t, string(data), "Certificate 0:\n    Subject: CN=localhost")
+		require.Contains(t, string(data), "Certificate 1:\n    Subject: CN=GoSSL Root CA Intermediate")
+	})
+
+	t.Run("cafile error", func(t *testing.T) {
+		require.Error(t, app.Run([]string{execName, info.CmdInfo, "--url", "127.0.0.1:1", "--chain", "--cafile", "wrong-file"}))
+	})
+}
+
+func TestInfoURLOptions(t *testing.T) {
+	execName, err := os.Executable()
+	require.NoError(t, err)
+
+	pkiDir := t.TempDir()
+	app := &cli.App{
+		Commands: []*cli.Command{
+			generate.Command(),
+			info.Command(),
+		},
+	}
+	require.NoError(t, app.Run([]string{execName, generate.CmdGenerate,
+		"--out", pkiDir, "--type", "ecdsa", "--leaf", "api.example.com"}))
+
+	serverCert, err := tls.LoadX509KeyPair(filepath.Join(pkiDir, "api.example.com-cert.pem"), filepath.Join(pkiDir, "api.example.com-key.pem"))
+	require.NoError(t, err)
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.TLS = &tls.Config{Certificates: []tls.Certificate{serverCert}}
+	ts.StartTLS()
+	defer ts.Close()
+
+	t.Run("connect to IP with server name", func(t *testing.T) {
+		outFile := filepath.Join(t.TempDir(), "handshake.json")
+		require.NoError(t, app.Run([]string{execName, info.CmdInfo, "--url", "api.example.com", "--chain",
+			"--connect", ts.Listener.Addr().String(), "--tls-max", "1.2",
+			"--cafile", filepath.Join(pkiDir, "ca-cert.pem"), "--format", "json", "--out", outFile}))
+
+		data, err := os.ReadFile(outFile)
+		require.NoError(t, err)
+
+		var got struct {
+			Host          string `json:"host"`
+			ServerName    string `json:"serverName"`
+			TLSVersion    string `json:"tlsVersion"`
+			HostnameMatch bool   `json:"hostnameMatch"`
+		}
+		require.NoError(t, json.Unmarshal(data, &got))
+		require.Equal(t, ts.Listener.Addr().String(), got.Host)
+		require.Equal(t, "api.example.com", got.ServerName)
+		require.Equal(t, "TLS 1.2", got.TLSVersion)
+		require.True(t, got.HostnameMatch)
+	})
+
+	t.Run("timeout error", func(t *testing.T) {
+		// Listener accepts connections but never completes TLS handshake
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer ln.Close()
+
+		start := time.Now()
+		require.Error(t, app.Run([]string{execName, info.CmdInfo, "--url", ln.Addr().String(), "--timeout", "100ms"}))
+		require.Less(t, time.Since(start), 5*time.Second)
+	})
+
+	t.Run("TLS version error", func(t *testing.T) {
+		require.Error(t, app.Run([]string{execName, info.CmdInfo, "--url", ts.URL, "--chain", "--tls-min", "1.3", "--tls-max", "1.2"}))
+	})
+}
+
+func TestInfoEnum(t *testing.T) {
+	execName, err := os.Executable()
+	require.NoError(t, err)
+
+	pkiDir := t.TempDir()
+	app := &cli.App{
+		Commands: []*cli.Command{
+			generate.Command(),
+			info.Command(),
+		},
+	}
+	require.NoError(t, app.Run([]string{execName, generate.CmdGenerate,
+		"--out", pkiDir, "--type", "ecdsa", "--leaf", "localhost+127.0.0.1"}))
+
+	serverCert, err := tls.LoadX509KeyPair(filepath.Join(pkiDir, "localhost-cert.pem"), filepath.Join(pkiDir, "localhost-key.pem"))
+	require.NoError(t, err)
+
+	type enum struct {
+		Versions []struct {
+			Version      string `json:"version"`
+			Supported    bool   `json:"supported"`
+			CipherSuites []struct {
+				Name     string `json:"name"`
+				ID       string `json:"id"`
+				Insecure bool   `json:"insecure"`
+			} `json:"cipherSuites"`
+			PreferenceOrder []string `json:"preferenceOrder"`
+		} `json:"versions"`
+		NamedGroups          []string `json:"namedGroups"`
+		InsecureVersions     []string `json:"insecureVersions"`
+		InsecureCipherSuites []string `json:"insecureCipherSuites"`
+	}
+
+	testCases := []struct {
+		name                 string
+		config               *tls.Config
+		supported            []string
+		tls12Suites          []string
+		namedGroups          []string
+		insecureVersions     []string
+		insecureCipherSuites []string
+	}{
+		{
+			name: "TLS 1.2 only with restricted suites and groups",
+			config: &tls.Config{
+				MinVersion: tls.VersionTLS12,
+				MaxVersion: tls.VersionTLS12,
+				CipherSuites: []uint16{
+					tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+					tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+				},
+				CurvePreferences: []tls.CurveID{tls.CurveP256, tls.CurveP384},
+			},
+			supported: []string{"TLS 1.2"},
+			tls12Suites: []string{
+				"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+				"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256",
+			},
+			namedGroups:          []string{"P-256", "P-384"},
+			insecureVersions:     []string{},
+			insecureCipherSuites: []string{},
+		},
+		{
+			name: "TLS 1.2 and 1.3 with insecure suite",
+			config: &tls.Config{
+				MinVersion: tls.VersionTLS12,
+				CipherSuites: []uint16{
+					tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+					tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256,
+				},
+			},
+			supported: []string{"TLS 1.2", "TLS 1.3"},
+			tls12Suites: []string{
+				"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+				"TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256",
+			},
+			namedGroups:          []string{"X25519", "P-256", "P-384", "P-521"},
+			insecureVersions:     []string{},
+			insecureCipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256"},
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.name, func(t *testing.T) {
+			ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			ts.TLS = tC.config
+			ts.TLS.Certificates = []tls.Certificate{serverCert}
+			ts.StartTLS()
+			defer ts.Close()
+
+			outFile := filepath.Join(t.TempDir(), "enum.json")
+			require.NoError(t, app.Run([]string{execName, info.CmdInfo, "--url", ts.URL, "--enum",
+				"--tls-max", "1.2", "--format", "json", "--out", outFile}))
+
+			data, err := os.ReadFile(outFile)
+			require.NoError(t, err)
+
+			var got enum
+			require.NoError(t, json.Unmarshal(data, &got))
+
+			var supported []string
+			for _, v := range got.Versions {
+				if v.Supported {
+					supported = append(supported, v.Version)
+				}
+				if v.Version != "TLS 1.2" {
+					continue
+				}
+
+				var suites []string
+				for _, s := range v.CipherSuites {
+					suites = append(suites, s.Name)
+				}
+				require.ElementsMatch(t, tC.tls12Suites, suites)
+				require.ElementsMatch(t, tC.tls12Suites, v.PreferenceOrder)
+			}
+			require.Equal(t, tC.supported, supported)
+			require.Equal(t, tC.namedGroups, got.NamedGroups)
+			require.Equal(t, tC.insecureVersions, got.InsecureVersions)
+			require.Equal(t, tC.insecureCipherSuites, got.InsecureCipherSuites)
+		})
+	}
+
+	t.Run("RSA key exchange with one curve", func(t *testing.T) {
+		rsaDir := t.TempDir()
+		require.NoError(t, app.Run([]string{execName, generate.CmdGenerate,
+			"--out", rsaDir, "--type", "rsa", "--leaf", "localhost+127.0.0.1"}))
+		rsaCert, err := tls.LoadX509KeyPair(filepath.Join(rsaDir, "localhost-cert.pem"), filepath.Join(rsaDir, "localhost-key.pem"))
+		require.NoError(t, err)
+
+		// Handshakes with RSA key exchange do not use the offered group
+		ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		ts.TLS = &tls.Config{
+			Certificates: []tls.Certificate{rsaCert},
+			MinVersion:   tls.VersionTLS12,
+			MaxVersion:   tls.VersionTLS12,
+			CipherSuites: []uint16{
+				tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+				tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+			},
+			CurvePreferences: []tls.CurveID{tls.CurveP384},
+		}
+		ts.StartTLS()
+		defer ts.Close()
+
+		outFile := filepath.Join(t.TempDir(), "enum.json")
+		require.NoError(t, app.Run([]string{execName, info.CmdInfo, "--url", ts.URL, "--enum",
+			"--format", "json", "--out", outFile}))
+
+		data, err := os.ReadFile(outFile)
+		require.NoError(t, err)
+
+		var got enum
+		require.NoError(t, json.Unmarshal(data, &got))
+		require.Equal(t, []string{"P-384"}, got.NamedGroups)
+	})
+
+	t.Run("text output", func(t *testing.T) {
+		ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		ts.TLS = &tls.Config{Certificates: []tls.Certificate{serverCert}, MinVersion: tls.VersionTLS13}
+		ts.StartTLS()
+		defer ts.Close()
+
+		outFile := filepath.Join(t.TempDir(), "enum.txt")
+		require.NoError(t, app.Run([]string{execName, info.CmdInfo, "--url", ts.URL, "--enum", "--out", outFile}))
+
+		data, err := os.ReadFile(outFile)
+		require.NoError(t, err)
+		require.Contains(t, string(data), "TLS 1.2: not supported\n")
+		require.Contains(t, string(data), "TLS 1.3: supported\n        1. TLS_")
+		require.Contains(t, string(data), "Insecure Cipher Suites: none\n")
+	})
+
+	t.Run("unreachable server error", func(t *testing.T) {
+		require.Error(t, app.Run([]string{execName, info.CmdInfo, "--url", "127.0.0.1:1", "--enum"}))
+	})
+
+	t.Run("chain and enum error", func(t *testing.T) {
+		require.Error(t, app.Run([]string{execName, info.CmdInfo, "--url", "127.0.0.1:1", "--enum", "--chain"}))
+	})
+}
+
+func TestInfoCRL(t *testing.T) {
+	execName, err := os.Executable()
+	require.NoError(t, err)
+
+	app := &cli.App{
+		Commands: []*cli.Command{
+			generate.Command(),
+			crl.Command(),
+			info.Command(),
+		},
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, app.Run([]string{execName, generate.CmdGenerate, "--out", dir, "--type", "ecdsa"}))
+
+	crlFile := filepath.Join(dir, "ca.crl")
+	require.NoError(t, app.Run([]string{execName, crl.CmdCRL,
+		"--cacert", filepath.Join(dir, "ca-cert.pem"), "--cakey", filepath.Join(dir, "ca-key.pem"),
+		"--revoke", "0x1f:keyCompromise:2024-01-31", "--revoke", "32", "--out", crlFile}))
+
+	// Same CRL in DER format
+	pemData, err := os.ReadFile(crlFile)
+	require.NoError(t, err)
+	block, _ := pem.Decode(pemData)
+	require.NotNil(t, block)
+	derFile := filepath.Join(dir, "ca.der")
+	require.NoError(t, os.WriteFile(derFile, block.Bytes, 0o600))
+
+	t.Run("text", func(t *testing.T) {
+		outFile := filepath.Join(t.TempDir(), "crl.txt")
+		require.NoError(t, app.Run([]string{execName, info.CmdInfo, "--crl", crlFile, "--out", outFile}))
+
+		text, err := os.ReadFile(outFile)
+		require.NoError(t, err)
+		require.Contains(t, string(text), "Issuer: CN=GoSSL Root CA")
+		require.Contains(t, string(text), "Signature Algorithm: ECDSA-SHA256")
+		require.Contains(t, string(text), "CRL Number: 1")
+		require.Contains(t, string(text), "Serial Number: 1F\n        Revocation Date: 2024-01-31T00:00:00Z\n        Reason: keyCompromise\n")
+		require.Contains(t, string(text), "Serial Number: 20\n")
+	})
+
+	for _, path := range []string{crlFile, derFile} {
+		t.Run("json "+filepath.Ext(path), func(t *testing.T) {
+			outFile := filepath.Join(t.TempDir(), "crl.json")
+			require.NoError(t, app.Run([]string{execName, info.CmdInfo, "--crl", path, "--format", "json", "--out", outFile}))
+
+			data, err := os.ReadFile(outFile)
+			require.NoError(t, err)
+
+			var got struct {
+				Version    int        `json:"version"`
+				NextUpdate *time.Time `json:"nextUpdate"`
+				Number     string     `json:"crlNumber"`
+				Expired    bool       `json:"expired"`
+				Revoked    []struct {
+					SerialNumber string `json:"serialNumber"`
+					Reason       string `json:"reason"`
+				} `json:"revokedCertificates"`
+			}
+			require.NoError(t, json.Unmarshal(data, &got))
+			require.Equal(t, 2, got.Version)
+			require.NotNil(t, got.NextUpdate)
+			require.Equal(t, "1", got.Number)
+			require.False(t, got.Expired)
+			require.Len(t, got.Revoked, 2)
+			require.Equal(t, "1f", got.Revoked[0].SerialNumber)
+			require.Equal(t, "keyCompromise", got.Revoked[0].Reason)
+			require.Empty(t, got.Revoked[1].Reason)
+		})
+	}
+
+	t.Run("wrong CRL file error", func(t *testing.T) {
+		require.Error(t, app.Run([]string{execName, info.CmdInfo, "--crl", filepath.Join(dir, "ca-cert.pem")}))
+	})
+}
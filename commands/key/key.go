@@ -1,6 +1,9 @@
 package key
 
 import (
+	"crypto"
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
@@ -17,6 +20,14 @@ const (
 	flagOut        = "out"
 	flagBits       = "bits"
 	flagWithPublic = "withpub"
+	flagType       = "type"
+	flagCurve      = "curve"
+	flagFormat     = "format"
+	flagEncrypt    = "encrypt"
+	flagPassword   = "password"
+
+	formatPKCS1 = "pkcs1"
+	formatPKCS8 = "pkcs8"
 )
 
 func Command() *cli.Command {
@@ -25,8 +36,8 @@ func Command() *cli.Command {
 		HelpName:    CmdKey,
 		Action:      Action,
 		ArgsUsage:   ` `,
-		Usage:       `generates RSA private and public key.`,
-		Description: `Generates RSA private and public key with provided number of bits.`,
+		Usage:       `generates private and public key.`,
+		Description: `Generates RSA, ECDSA or Ed25519 private and public key with provided number of bits or curve.`,
 		Flags:       Flags(),
 	}
 }
@@ -39,9 +50,25 @@ func Flags() []cli.Flag {
 			Required: false,
 		},
 		&cli.UintFlag{
-			Name:     flagBits,
-			Usage:    "Number of bits",
-			Required: true,
+			Name:        flagBits,
+			Usage:       "Number of bits (rsa only)",
+			DefaultText: "2048",
+			Value:       2048,
+			Required:    false,
+		},
+		&cli.StringFlag{
+			Name:        flagType,
+			Usage:       "Key type (rsa, ecdsa or ed25519)",
+			DefaultText: utils.KeyTypeRSA,
+			Value:       utils.KeyTypeRSA,
+			Required:    false,
+		},
+		&cli.StringFlag{
+			Name:        flagCurve,
+			Usage:       "Elliptic curve (P-256, P-384 or P-521) (ecdsa only)",
+			DefaultText: "P-256",
+			Value:       "P-256",
+			Required:    false,
 		},
 		&cli.BoolFlag{
 			Name:        flagWithPublic,
@@ -49,19 +76,41 @@ func Flags() []cli.Flag {
 			Required:    false,
 			DefaultText: "false",
 		},
+		&cli.StringFlag{
+			Name:        flagFormat,
+			Usage:       "Private key format (pkcs1 or pkcs8), pkcs1 means SEC1 for ecdsa and pkcs8 for ed25519 keys",
+			DefaultText: formatPKCS1,
+			Value:       formatPKCS1,
+			Required:    false,
+		},
+		&cli.BoolFlag{
+			Name:        flagEncrypt,
+			Usage:       "Encrypt private key with a pass phrase (pkcs8 only)",
+			Required:    false,
+			DefaultText: "false",
+		},
+		&cli.StringFlag{
+			Name:     flagPassword,
+			Usage:    "Pass phrase to encrypt private key with, asked if not set (optional)",
+			Required: false,
+		},
 	}
 }
 
 func Action(c *cli.Context) error {
 	// Generate private key
-	privateKey, err := utils.GeneratePrivateKey(int(c.Uint(flagBits)))
+	privateKey, err := utils.GenerateKey(c.String(flagType), int(c.Uint(flagBits)), c.String(flagCurve))
 	if err != nil {
-		log.Printf("Failed to generate RSA Private Key with bit size %d error: %v", c.Uint(flagBits), err)
+		log.Printf("Failed to generate %s Private Key error: %v", c.String(flagType), err)
 		return err
 	}
 
-	// Encode Private Key from RSA to PEM format
-	privateKeyBytes := utils.PrivateKeyToPEM(privateKey)
+	// Encode Private Key to PEM format
+	privateKeyBytes, err := privateKeyToPEM(c, privateKey)
+	if err != nil {
+		log.Printf("Failed to encode Private Key error: %v", err)
+		return err
+	}
 
 	// Set output
 	var (
@@ -85,7 +134,11 @@ func Action(c *cli.Context) error {
 
 	// Export public key if flag is set
 	if c.Bool(flagWithPublic) {
-		publicKeyBytes := utils.PublicKeyToPEM(&privateKey.PublicKey)
+		publicKeyBytes, err := utils.AnyPublicKeyToPEM(privateKey.Public())
+		if err != nil {
+			log.Printf("Failed to encode Public Key error: %v", err)
+			return err
+		}
 
 		// Write public key to output
 		if err = os.WriteFile(outputPubFilePath, publicKeyBytes, 0o600); err != nil {
@@ -97,3 +150,54 @@ func Action(c *cli.Context) error {
 
 	return nil
 }
+
+// privateKeyToPEM encodes private key in format set by flags and
+// encrypts it with pass phrase if encrypt flag is set
+func privateKeyToPEM(c *cli.Context, privateKey crypto.Signer) ([]byte, error) {
+	format := c.String(flagFormat)
+	if format != formatPKCS1 && format != formatPKCS8 {
+		return nil, fmt.Errorf("unsupported private key format %q", format)
+	}
+
+	if c.Bool(flagEncrypt) {
+		if c.IsSet(flagFormat) && format != formatPKCS8 {
+			return nil, errors.New("encrypt flag is only allowed to be used with pkcs8 format")
+		}
+
+		password, err := newPassword(c)
+		if err != nil {
+			return nil, err
+		}
+
+		return utils.EncryptedPrivateKeyToPEM(privateKey, []byte(password))
+	}
+
+	if format == formatPKCS8 {
+		return utils.PKCS8PrivateKeyToPEM(privateKey)
+	}
+
+	return utils.AnyPrivateKeyToPEM(privateKey)
+}
+
+// newPassword returns password flag or asks pass phrase twice from terminal
+func newPassword(c *cli.Context) (string, error) {
+	if c.IsSet(flagPassword) {
+		return c.String(flagPassword), nil
+	}
+
+	password, err := utils.StdinPasswordReader{Prompt: "Enter pass phrase: "}.ReadPassword()
+	if err != nil {
+		return "", err
+	}
+
+	verify, err := utils.StdinPasswordReader{Prompt: "Verifying - Enter pass phrase: "}.ReadPassword()
+	if err != nil {
+		return "", err
+	}
+
+	if password != verify {
+		return "", errors.New("pass phrases do not match")
+	}
+
+	return password, nil
+}
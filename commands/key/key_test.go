@@ -1,11 +1,19 @@
 package key_test
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
 	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/yakuter/gossl/commands/key"
+	"github.com/yakuter/gossl/pkg/utils"
 
 	"github.com/stretchr/testify/require"
 	"github.com/urfave/cli/v2"
@@ -23,20 +31,103 @@ func TestKey(t *testing.T) {
 
 	tempDir := t.TempDir()
 	outFilePath := filepath.Join(tempDir, "private.key")
+	pubFilePath := filepath.Join(tempDir, "private.pub")
 
 	testCases := []struct {
 		name      string
 		out       string
 		filePath  string
 		numbits   string
+		keyType   string
+		curve     string
+		format    string
+		password  string
+		pemType   string
+		pubType   string
+		key       crypto.Signer
 		shouldErr bool
 	}{
 		{
 			name:      "valid private key",
 			out:       outFilePath,
 			numbits:   "1024",
+			pemType:   "RSA PRIVATE KEY",
+			pubType:   "RSA PUBLIC KEY",
+			key:       &rsa.PrivateKey{},
 			shouldErr: false,
 		},
+		{
+			name:      "valid ecdsa private key",
+			out:       outFilePath,
+			numbits:   "1024",
+			keyType:   "ecdsa",
+			curve:     "P-384",
+			pemType:   "EC PRIVATE KEY",
+			pubType:   "PUBLIC KEY",
+			key:       &ecdsa.PrivateKey{},
+			shouldErr: false,
+		},
+		{
+			name:      "valid ed25519 private key",
+			out:       outFilePath,
+			numbits:   "1024",
+			keyType:   "ed25519",
+			pemType:   "PRIVATE KEY",
+			pubType:   "PUBLIC KEY",
+			key:       ed25519.PrivateKey{},
+			shouldErr: false,
+		},
+		{
+			name:      "unsupported key type",
+			out:       outFilePath,
+			numbits:   "1024",
+			keyType:   "dsa",
+			shouldErr: true,
+		},
+		{
+			name:      "unsupported curve",
+			out:       outFilePath,
+			numbits:   "1024",
+			keyType:   "ecdsa",
+			curve:     "P-224",
+			shouldErr: true,
+		},
+		{
+			name:      "valid pkcs8 private key",
+			out:       outFilePath,
+			numbits:   "1024",
+			format:    "pkcs8",
+			pemType:   "PRIVATE KEY",
+			pubType:   "RSA PUBLIC KEY",
+			key:       &rsa.PrivateKey{},
+			shouldErr: false,
+		},
+		{
+			name:      "valid encrypted private key",
+			out:       outFilePath,
+			numbits:   "1024",
+			keyType:   "ecdsa",
+			password:  "s3cret",
+			pemType:   "ENCRYPTED PRIVATE KEY",
+			pubType:   "PUBLIC KEY",
+			key:       &ecdsa.PrivateKey{},
+			shouldErr: false,
+		},
+		{
+			name:      "encrypted pkcs1 private key error",
+			out:       outFilePath,
+			numbits:   "1024",
+			format:    "pkcs1",
+			password:  "s3cret",
+			shouldErr: true,
+		},
+		{
+			name:      "unsupported format",
+			out:       outFilePath,
+			numbits:   "1024",
+			format:    "der",
+			shouldErr: true,
+		},
 		{
 			name:      "out file not found error",
 			out:       "",
@@ -60,11 +151,51 @@ func TestKey(t *testing.T) {
 	for _, tC := range testCases {
 		t.Run(tC.name, func(t *testing.T) {
 			testArgs := []string{execName, key.CmdKey, "-out", tC.out, "-bits", tC.numbits, "-withpub"}
+			if tC.keyType != "" {
+				testArgs = append(testArgs, "-type", tC.keyType)
+			}
+			if tC.curve != "" {
+				testArgs = append(testArgs, "-curve", tC.curve)
+			}
+			if tC.format != "" {
+				testArgs = append(testArgs, "-format", tC.format)
+			}
+			if tC.password != "" {
+				testArgs = append(testArgs, "-encrypt", "-password", tC.password)
+			}
 			if tC.shouldErr {
 				require.Error(t, app.Run(testArgs))
+				return
+			}
+			require.NoError(t, app.Run(testArgs))
+
+			data, err := os.ReadFile(outFilePath)
+			require.NoError(t, err)
+			block, _ := pem.Decode(data)
+			require.NotNil(t, block)
+			require.Equal(t, tC.pemType, block.Type)
+
+			privateKey, err := utils.PrivateKeyFromFileWithPassword(outFilePath, utils.StaticPasswordReader{Password: tC.password})
+			require.NoError(t, err)
+			require.IsType(t, tC.key, privateKey)
+			if tC.curve != "" {
+				require.Equal(t, elliptic.P384(), privateKey.(*ecdsa.PrivateKey).Curve)
+			}
+
+			data, err = os.ReadFile(pubFilePath)
+			require.NoError(t, err)
+			block, _ = pem.Decode(data)
+			require.NotNil(t, block)
+			require.Equal(t, tC.pubType, block.Type)
+
+			var publicKey crypto.PublicKey
+			if block.Type == "RSA PUBLIC KEY" {
+				publicKey, err = x509.ParsePKCS1PublicKey(block.Bytes)
 			} else {
-				require.NoError(t, app.Run(testArgs))
+				publicKey, err = x509.ParsePKIXPublicKey(block.Bytes)
 			}
+			require.NoError(t, err)
+			require.True(t, privateKey.Public().(interface{ Equal(crypto.PublicKey) bool }).Equal(publicKey))
 		})
 	}
 }
@@ -0,0 +1,209 @@
+package ocsp
+
+import (
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/yakuter/gossl/pkg/utils"
+
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	CmdOCSP  = "ocsp"
+	CmdServe = "serve"
+
+	flagAddr      = "addr"
+	flagCACert    = "cacert"
+	flagCAKey     = "cakey"
+	flagSigner    = "signer"
+	flagSignerKey = "signerKey"
+	flagPassword  = "password"
+	flagIndex     = "index"
+	flagCRL       = "crl"
+	flagValidity  = "validity"
+)
+
+func Command() *cli.Command {
+	return &cli.Command{
+		Name:        CmdOCSP,
+		HelpName:    CmdOCSP,
+		Usage:       `runs OCSP services.`,
+		Description: `Runs Online Certificate Status Protocol (OCSP) services of a CA.`,
+		Subcommands: []*cli.Command{
+			{
+				Name:      CmdServe,
+				HelpName:  CmdOCSP + " " + CmdServe,
+				Action:    ServeAction,
+				ArgsUsage: ` `,
+				Usage:     `runs an HTTP OCSP responder for a CA.`,
+				Description: `Runs an HTTP OCSP responder (RFC 6960) answering GET and POST requests
+for certificates issued by CA. Status is read from an OpenSSL CA index file or
+a CRL, both are reloaded when they change. Responses are signed with CA key or a
+delegated OCSP signing certificate and cached until half of their validity.`,
+				Flags: ServeFlags(),
+			},
+		},
+	}
+}
+
+func ServeFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        flagAddr,
+			Usage:       "Address to listen on",
+			DefaultText: ":8888",
+			Value:       ":8888",
+			Required:    false,
+		},
+		&cli.StringFlag{
+			Name:        flagCACert,
+			Usage:       "CA cert file of certificates to answer for (required)",
+			DefaultText: "eg, ./ca.pem",
+			Required:    true,
+		},
+		&cli.StringFlag{
+			Name:        flagCAKey,
+			Usage:       "CA private key file to sign responses with (required unless signer is set)",
+			DefaultText: "eg, ./ca.key",
+			Required:    false,
+		},
+		&cli.StringFlag{
+			Name:        flagSigner,
+			Usage:       "Delegated OCSP signing cert issued by CA with OCSPSigning extended key usage (optional)",
+			DefaultText: "eg, ./ocsp.pem",
+			Required:    false,
+		},
+		&cli.StringFlag{
+			Name:        flagSignerKey,
+			Usage:       "Private key file of delegated OCSP signing cert (required with signer)",
+			DefaultText: "eg, ./ocsp.key",
+			Required:    false,
+		},
+		&cli.StringFlag{
+			Name:     flagPassword,
+			Usage:    "Pass phrase of encrypted signing key, asked if not set (optional)",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:        flagIndex,
+			Usage:       "OpenSSL CA index file, serials not in it are unknown (required unless crl is set)",
+			DefaultText: "eg, ./index.txt",
+			Required:    false,
+		},
+		&cli.StringFlag{
+			Name:        flagCRL,
+			Usage:       "CRL of CA, serials not in it are good (required unless index is set)",
+			DefaultText: "eg, ./ca.crl",
+			Required:    false,
+		},
+		&cli.DurationFlag{
+			Name:     flagValidity,
+			Usage:    "Validity of responses, next update is this update plus validity",
+			Value:    time.Hour,
+			Required: false,
+		},
+	}
+}
+
+func ServeAction(c *cli.Context) error {
+	if c.IsSet(flagIndex) == c.IsSet(flagCRL) {
+		return errors.New("Please provide one of index or crl flags")
+	}
+
+	if c.IsSet(flagCAKey) == c.IsSet(flagSigner) {
+		return errors.New("Please provide one of cakey or signer flags")
+	}
+
+	if c.IsSet(flagSigner) != c.IsSet(flagSignerKey) {
+		return errors.New("Please provide signer and signerKey flags together")
+	}
+
+	if c.Duration(flagValidity) <= 0 {
+		return errors.New("validity must be positive")
+	}
+
+	caCert, err := utils.CertFromFile(c.String(flagCACert))
+	if err != nil {
+		log.Printf("Failed to get CA cert from file %s error: %v", c.String(flagCACert), err)
+		return err
+	}
+
+	signer, key, err := signingCert(c, caCert)
+	if err != nil {
+		log.Printf("Failed to get signing cert error: %v", err)
+		return err
+	}
+
+	db := &database{path: c.String(flagIndex), ca: caCert}
+	if c.IsSet(flagCRL) {
+		db.path, db.crl = c.String(flagCRL), true
+	}
+	if err = db.reload(); err != nil {
+		log.Printf("Failed to load revocation database %s error: %v", db.path, err)
+		return err
+	}
+
+	r := &responder{
+		ca:       caCert,
+		signer:   signer,
+		key:      key,
+		db:       db,
+		validity: c.Duration(flagValidity),
+		cache:    map[string]cachedResponse{},
+	}
+
+	log.Printf("OCSP responder of %q listening on %s", caCert.Subject, c.String(flagAddr))
+	return http.ListenAndServe(c.String(flagAddr), r)
+}
+
+// signingCert returns the cert and key responses are signed with. Delegated
+// signer must be issued by CA and have OCSPSigning extended key usage.
+func signingCert(c *cli.Context, caCert *x509.Certificate) (*x509.Certificate, crypto.Signer, error) {
+	if !c.IsSet(flagSigner) {
+		key, err := utils.PrivateKeyFromFileWithPassword(c.String(flagCAKey), utils.KeyPasswordReader(c, flagPassword, c.String(flagCAKey)))
+		if err != nil {
+			return nil, nil, err
+		}
+		return caCert, key, matchKey(caCert, key)
+	}
+
+	signer, err := utils.CertFromFile(c.String(flagSigner))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err = signer.CheckSignatureFrom(caCert); err != nil {
+		return nil, nil, fmt.Errorf("signer is not issued by CA: %w", err)
+	}
+
+	ocspSigning := false
+	for _, eku := range signer.ExtKeyUsage {
+		ocspSigning = ocspSigning || eku == x509.ExtKeyUsageOCSPSigning
+	}
+	if !ocspSigning {
+		return nil, nil, errors.New("signer does not have OCSPSigning extended key usage")
+	}
+
+	key, err := utils.PrivateKeyFromFileWithPassword(c.String(flagSignerKey), utils.KeyPasswordReader(c, flagPassword, c.String(flagSignerKey)))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return signer, key, matchKey(signer, key)
+}
+
+// matchKey checks that key is the private key of cert
+func matchKey(cert *x509.Certificate, key crypto.Signer) error {
+	pub, ok := key.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !pub.Equal(cert.PublicKey) {
+		return fmt.Errorf("private key does not match cert %q", cert.Subject)
+	}
+
+	return nil
+}
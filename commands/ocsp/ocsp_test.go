@@ -0,0 +1,295 @@
+package ocsp_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yakuter/gossl/commands/generate"
+	gosslocsp "github.com/yakuter/gossl/commands/ocsp"
+	"github.com/yakuter/gossl/pkg/utils"
+
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/crypto/ocsp"
+)
+
+// freeAddr returns a local address which is not in use
+func freeAddr(t *testing.T) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	return ln.Addr().String()
+}
+
+// serve runs ocsp serve with args in background and returns its URL
+func serve(t *testing.T, app *cli.App, args ...string) string {
+	t.Helper()
+
+	execName, err := os.Executable()
+	require.NoError(t, err)
+
+	addr := freeAddr(t)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- app.Run(append([]string{execName, gosslocsp.CmdOCSP, gosslocsp.CmdServe, "--addr", addr}, args...))
+	}()
+
+	var runErr error
+	require.Eventually(t, func() bool {
+		select {
+		case runErr = <-errc:
+			return true
+		default:
+		}
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+		}
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, runErr)
+
+	return "http://" + addr
+}
+
+// newCert returns a certificate with serial issued by CA
+func newCert(t *testing.T, ca *x509.Certificate, caKey crypto.Signer, serial int64, eku ...x509.ExtKeyUsage) (*x509.Certificate, crypto.Signer) {
+	t.Helper()
+
+	key, err := utils.GenerateKey(utils.KeyTypeECDSA, 0, "P-256")
+	require.NoError(t, err)
+
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "cert"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  eku,
+	}, ca, key.Public(), caKey)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return cert, key
+}
+
+// query sends an OCSP request of cert with GET or POST and returns the parsed response
+func query(t *testing.T, server, method string, cert, ca *x509.Certificate, opts *ocsp.RequestOptions) (*ocsp.Response, error) {
+	t.Helper()
+
+	req, err := ocsp.CreateRequest(cert, ca, opts)
+	require.NoError(t, err)
+
+	var resp *http.Response
+	if method == http.MethodGet {
+		resp, err = http.Get(server + "/" + url.PathEscape(base64.StdEncoding.EncodeToString(req)))
+	} else {
+		resp, err = http.Post(server, "application/ocsp-request", bytes.NewReader(req))
+	}
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/ocsp-response", resp.Header.Get("Content-Type"))
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return ocsp.ParseResponseForCert(body, cert, ca)
+}
+
+func TestOCSPServe(t *testing.T) {
+	execName, err := os.Executable()
+	require.NoError(t, err)
+
+	app := &cli.App{
+		Commands: []*cli.Command{
+			generate.Command(),
+			gosslocsp.Command(),
+		},
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, app.Run([]string{execName, generate.CmdGenerate, "--out", dir, "--type", "ecdsa"}))
+	caCertFile := filepath.Join(dir, "ca-cert.pem")
+	caKeyFile := filepath.Join(dir, "ca-key.pem")
+
+	ca, err := utils.CertFromFile(caCertFile)
+	require.NoError(t, err)
+	caKey, err := utils.PrivateKeyFromFile(caKeyFile)
+	require.NoError(t, err)
+
+	good, _ := newCert(t, ca, caKey, 0x10)
+	revoked, _ := newCert(t, ca, caKey, 0x11)
+	unknown, _ := newCert(t, ca, caKey, 0x12)
+
+	// OpenSSL CA index with a valid and a revoked certificate
+	indexFile := filepath.Join(dir, "index.txt")
+	require.NoError(t, os.WriteFile(indexFile, []byte(
+		"V\t341231235959Z\t\t10\tunknown\t/CN=good\n"+
+			"R\t341231235959Z\t240131000000Z,keyCompromise\t11\tunknown\t/CN=revoked\n"), 0o600))
+
+	// CRL revoking the second certificate
+	crlDER, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		RevokedCertificates: []pkix.RevokedCertificate{{SerialNumber: big.NewInt(0x11), RevocationTime: time.Now()}},
+		Number:              big.NewInt(1),
+		ThisUpdate:          time.Now(),
+		NextUpdate:          time.Now().Add(time.Hour),
+	}, ca, caKey)
+	require.NoError(t, err)
+	crlFile := filepath.Join(dir, "ca.crl")
+	require.NoError(t, os.WriteFile(crlFile, utils.CRLToPEM(crlDER), 0o600))
+
+	// Delegated OCSP signing certificate
+	signer, signerKey := newCert(t, ca, caKey, 0x20, x509.ExtKeyUsageOCSPSigning)
+	signerFile := filepath.Join(dir, "ocsp.pem")
+	require.NoError(t, os.WriteFile(signerFile, utils.CertToPEM(signer.Raw), 0o600))
+	signerKeyPEM, err := utils.AnyPrivateKeyToPEM(signerKey)
+	require.NoError(t, err)
+	signerKeyFile := filepath.Join(dir, "ocsp-key.pem")
+	require.NoError(t, os.WriteFile(signerKeyFile, signerKeyPEM, 0o600))
+
+	testCases := []struct {
+		name    string
+		args    []string
+		unknown int
+	}{
+		{
+			name:    "index signed by CA",
+			args:    []string{"--cacert", caCertFile, "--cakey", caKeyFile, "--index", indexFile},
+			unknown: ocsp.Unknown,
+		},
+		{
+			name:    "CRL signed by delegated signer",
+			args:    []string{"--cacert", caCertFile, "--signer", signerFile, "--signerKey", signerKeyFile, "--crl", crlFile},
+			unknown: ocsp.Good,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.name, func(t *testing.T) {
+			server := serve(t, app, tC.args...)
+
+			for _, method := range []string{http.MethodGet, http.MethodPost} {
+				resp, err := query(t, server, method, good, ca, nil)
+				require.NoError(t, err)
+				require.Equal(t, ocsp.Good, resp.Status)
+				require.True(t, resp.NextUpdate.After(time.Now()))
+
+				resp, err = query(t, server, method, revoked, ca, nil)
+				require.NoError(t, err)
+				require.Equal(t, ocsp.Revoked, resp.Status)
+
+				resp, err = query(t, server, method, unknown, ca, nil)
+				require.NoError(t, err)
+				require.Equal(t, tC.unknown, resp.Status)
+			}
+		})
+	}
+
+	t.Run("index reload and revocation reason", func(t *testing.T) {
+		reloadIndex := filepath.Join(t.TempDir(), "index.txt")
+		require.NoError(t, os.WriteFile(reloadIndex, []byte("V\t341231235959Z\t\t10\tunknown\t/CN=good\n"), 0o600))
+
+		server := serve(t, app, "--cacert", caCertFile, "--cakey", caKeyFile, "--index", reloadIndex)
+		resp, err := query(t, server, http.MethodPost, good, ca, nil)
+		require.NoError(t, err)
+		require.Equal(t, ocsp.Good, resp.Status)
+
+		// Cached response is dropped when index changes
+		require.NoError(t, os.WriteFile(reloadIndex, []byte("R\t341231235959Z\t240131000000Z,CACompromise\t10\tunknown\t/CN=good\n"), 0o600))
+		require.NoError(t, os.Chtimes(reloadIndex, time.Now().Add(time.Minute), time.Now().Add(time.Minute)))
+
+		resp, err = query(t, server, http.MethodPost, good, ca, nil)
+		require.NoError(t, err)
+		require.Equal(t, ocsp.Revoked, resp.Status)
+		require.Equal(t, ocsp.CACompromise, resp.RevocationReason)
+		require.Equal(t, "2024-01-31T00:00:00Z", resp.RevokedAt.UTC().Format(time.RFC3339))
+	})
+
+	t.Run("SHA-256 request", func(t *testing.T) {
+		server := serve(t, app, "--cacert", caCertFile, "--cakey", caKeyFile, "--index", indexFile)
+
+		// SHA-1 response of the same serial must not be served from cache
+		resp, err := query(t, server, http.MethodPost, revoked, ca, nil)
+		require.NoError(t, err)
+		require.Equal(t, crypto.SHA1, resp.IssuerHash)
+
+		for _, method := range []string{http.MethodGet, http.MethodPost} {
+			resp, err = query(t, server, method, revoked, ca, &ocsp.RequestOptions{Hash: crypto.SHA256})
+			require.NoError(t, err)
+			require.Equal(t, ocsp.Revoked, resp.Status)
+			require.Equal(t, crypto.SHA256, resp.IssuerHash)
+		}
+	})
+
+	t.Run("request of another CA", func(t *testing.T) {
+		server := serve(t, app, "--cacert", caCertFile, "--cakey", caKeyFile, "--index", indexFile)
+
+		other, err := utils.CertFromFile("../../testdata/ca-cert.pem")
+		require.NoError(t, err)
+		req, err := ocsp.CreateRequest(good, other, nil)
+		require.NoError(t, err)
+
+		resp, err := http.Post(server, "application/ocsp-request", bytes.NewReader(req))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, ocsp.UnauthorizedErrorResponse, body)
+	})
+
+	errorCases := []struct {
+		name string
+		args []string
+	}{
+		{
+			name: "missing database error",
+			args: []string{"--cacert", caCertFile, "--cakey", caKeyFile},
+		},
+		{
+			name: "both index and crl error",
+			args: []string{"--cacert", caCertFile, "--cakey", caKeyFile, "--index", indexFile, "--crl", crlFile},
+		},
+		{
+			name: "missing signing key error",
+			args: []string{"--cacert", caCertFile, "--index", indexFile},
+		},
+		{
+			name: "signer without OCSP signing usage error",
+			args: []string{"--cacert", caCertFile, "--signer", filepath.Join(dir, "ca-cert.pem"), "--signerKey", caKeyFile, "--index", indexFile},
+		},
+		{
+			name: "key of another cert error",
+			args: []string{"--cacert", caCertFile, "--cakey", signerKeyFile, "--index", indexFile},
+		},
+		{
+			name: "CRL of another CA error",
+			args: []string{"--cacert", "../../testdata/ca-cert.pem", "--cakey", "../../testdata/ca-key.pem", "--crl", crlFile},
+		},
+	}
+
+	for _, tC := range errorCases {
+		t.Run(tC.name, func(t *testing.T) {
+			args := append([]string{execName, gosslocsp.CmdOCSP, gosslocsp.CmdServe, "--addr", freeAddr(t)}, tC.args...)
+			require.Error(t, app.Run(args))
+		})
+	}
+}
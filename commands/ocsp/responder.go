@@ -0,0 +1,320 @@
+package ocsp
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yakuter/gossl/pkg/utils"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// maxRequestSize limits size of OCSP requests sent with POST
+const maxRequestSize = 10 << 10
+
+// certStatus is revocation status of a serial number in database
+type certStatus struct {
+	revoked   bool
+	revokedAt time.Time
+	reason    int
+}
+
+// database holds statuses read from an OpenSSL CA index file or a CRL and
+// reloads them when the file changes. Serials missing in an index are
+// unknown, serials missing in a CRL are good.
+type database struct {
+	path     string
+	crl      bool
+	ca       *x509.Certificate
+	modTime  time.Time
+	statuses map[string]certStatus
+}
+
+// reload reads database file if it is modified since the last read
+func (db *database) reload() error {
+	info, err := os.Stat(db.path)
+	if err != nil {
+		return err
+	}
+	if db.statuses != nil && info.ModTime().Equal(db.modTime) {
+		return nil
+	}
+
+	var statuses map[string]certStatus
+	if db.crl {
+		statuses, err = readCRL(db.path, db.ca)
+	} else {
+		statuses, err = readIndex(db.path)
+	}
+	if err != nil {
+		return err
+	}
+
+	db.statuses, db.modTime = statuses, info.ModTime()
+	return nil
+}
+
+// status returns status of serial, false if it is unknown
+func (db *database) status(serial *big.Int) (certStatus, bool) {
+	s, ok := db.statuses[serial.String()]
+	if db.crl && !ok {
+		return certStatus{}, true
+	}
+
+	return s, ok
+}
+
+// readCRL returns revoked serials of CRL signed by CA
+func readCRL(path string, ca *x509.Certificate) (map[string]certStatus, error) {
+	crl, err := utils.CRLFromFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = ca.CheckCRLSignature(crl); err != nil {
+		return nil, fmt.Errorf("CRL is not issued by CA: %w", err)
+	}
+
+	statuses := map[string]certStatus{}
+	for _, entry := range crl.TBSCertList.RevokedCertificates {
+		s := certStatus{revoked: true, revokedAt: entry.RevocationTime, reason: ocsp.Unspecified}
+		if reason, ok := utils.CRLReason(entry); ok {
+			s.reason = reason
+		}
+		statuses[entry.SerialNumber.String()] = s
+	}
+
+	return statuses, nil
+}
+
+// indexTimeLayout is the time format of OpenSSL CA index file
+const indexTimeLayout = "060102150405Z"
+
+// readIndex reads OpenSSL CA index file. Each line has tab separated status
+// (V valid, R revoked or E expired), expiry date, revocation date with optional
+// reason, serial in hex, file name and subject.
+func readIndex(path string) (map[string]certStatus, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	statuses := map[string]certStatus{}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("line %d: expected at least 4 tab separated fields", n)
+		}
+
+		serial, ok := new(big.Int).SetString(fields[3], 16)
+		if !ok {
+			return nil, fmt.Errorf("line %d: invalid serial %q", n, fields[3])
+		}
+
+		var s certStatus
+		switch fields[0] {
+		case "V", "E":
+		case "R":
+			if s, err = parseRevocation(fields[2]); err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+		default:
+			return nil, fmt.Errorf("line %d: unknown status %q", n, fields[0])
+		}
+		statuses[serial.String()] = s
+	}
+
+	return statuses, scanner.Err()
+}
+
+// parseRevocation parses revocation date with optional reason, eg, 240131000000Z,keyCompromise
+func parseRevocation(s string) (certStatus, error) {
+	date, reasonName, _ := strings.Cut(s, ",")
+
+	revokedAt, err := time.Parse(indexTimeLayout, date)
+	if err != nil {
+		return certStatus{}, fmt.Errorf("invalid revocation date %q", date)
+	}
+
+	status := certStatus{revoked: true, revokedAt: revokedAt, reason: ocsp.Unspecified}
+	if reasonName != "" {
+		reason, ok := findReason(reasonName)
+		if !ok {
+			return certStatus{}, fmt.Errorf("unknown revocation reason %q", reasonName)
+		}
+		status.reason = reason
+	}
+
+	return status, nil
+}
+
+// findReason looks up reason case insensitively, OpenSSL writes CACompromise
+func findReason(name string) (int, bool) {
+	for n, code := range utils.CRLReasons {
+		if strings.EqualFold(n, name) {
+			return code, true
+		}
+	}
+
+	return 0, false
+}
+
+// cachedResponse is a signed response reused until refreshAt
+type cachedResponse struct {
+	der       []byte
+	refreshAt time.Time
+}
+
+// responder answers OCSP requests for certificates issued by ca
+type responder struct {
+	ca       *x509.Certificate
+	signer   *x509.Certificate
+	key      crypto.Signer
+	db       *database
+	validity time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedResponse
+}
+
+func (r *responder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var (
+		der []byte
+		err error
+	)
+
+	switch req.Method {
+	case http.MethodGet:
+		// Request is URL encoded base64 in path, it may contain /
+		var path string
+		path, err = url.PathUnescape(req.URL.EscapedPath())
+		if err == nil {
+			der, err = base64.StdEncoding.DecodeString(strings.TrimPrefix(path, "/"))
+		}
+	case http.MethodPost:
+		der, err = io.ReadAll(io.LimitReader(req.Body, maxRequestSize))
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	resp := ocsp.MalformedRequestErrorResponse
+	if err == nil {
+		resp = r.respond(der)
+	}
+
+	w.Header().Set("Content-Type", "application/ocsp-response")
+	if _, err = w.Write(resp); err != nil {
+		log.Printf("Failed to write OCSP response error: %v", err)
+	}
+}
+
+// respond returns a signed response for DER encoded OCSP request
+func (r *responder) respond(der []byte) []byte {
+	req, err := ocsp.ParseRequest(der)
+	if err != nil {
+		return ocsp.MalformedRequestErrorResponse
+	}
+
+	if !r.issuedByCA(req) {
+		return ocsp.UnauthorizedErrorResponse
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Cached responses are dropped when database file changes
+	modTime := r.db.modTime
+	if err = r.db.reload(); err != nil {
+		log.Printf("Failed to reload revocation database %s error: %v", r.db.path, err)
+		return ocsp.InternalErrorErrorResponse
+	}
+	if !r.db.modTime.Equal(modTime) {
+		r.cache = map[string]cachedResponse{}
+	}
+
+	// Response CertID must use hash algorithm of the request
+	now := time.Now()
+	key := fmt.Sprintf("%d/%s", req.HashAlgorithm, req.SerialNumber)
+	if cached, ok := r.cache[key]; ok && now.Before(cached.refreshAt) {
+		return cached.der
+	}
+
+	template := ocsp.Response{
+		Status:       ocsp.Unknown,
+		SerialNumber: req.SerialNumber,
+		IssuerHash:   req.HashAlgorithm,
+		ThisUpdate:   now.Add(-time.Minute),
+		NextUpdate:   now.Add(r.validity),
+	}
+	if status, ok := r.db.status(req.SerialNumber); ok {
+		template.Status = ocsp.Good
+		if status.revoked {
+			template.Status = ocsp.Revoked
+			template.RevokedAt = status.revokedAt
+			template.RevocationReason = status.reason
+		}
+	}
+
+	// Delegated signer certificate is sent for clients to verify it
+	if r.signer != r.ca {
+		template.Certificate = r.signer
+	}
+
+	resp, err := ocsp.CreateResponse(r.ca, r.signer, template, r.key)
+	if err != nil {
+		log.Printf("Failed to create OCSP response error: %v", err)
+		return ocsp.InternalErrorErrorResponse
+	}
+
+	r.cache[key] = cachedResponse{der: resp, refreshAt: now.Add(r.validity / 2)}
+	return resp
+}
+
+// issuedByCA checks issuer name and key hashes of request
+func (r *responder) issuedByCA(req *ocsp.Request) bool {
+	if !req.HashAlgorithm.Available() {
+		return false
+	}
+
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(r.ca.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return false
+	}
+
+	h := req.HashAlgorithm.New()
+	h.Write(r.ca.RawSubject)
+	nameHash := h.Sum(nil)
+
+	h.Reset()
+	h.Write(spki.PublicKey.RightAlign())
+	keyHash := h.Sum(nil)
+
+	return bytes.Equal(nameHash, req.IssuerNameHash) && bytes.Equal(keyHash, req.IssuerKeyHash)
+}
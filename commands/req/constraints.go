@@ -0,0 +1,112 @@
+package req
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// constrainCA sets path length and name constraints of CA template from flags.
+// Flags take precedence over the constraints set by profile.
+func constrainCA(c *cli.Context, t *x509.Certificate) error {
+	if !t.IsCA && (c.IsSet(flagPathLen) || c.IsSet(flagPermitted) || c.IsSet(flagExcluded)) {
+		return errors.New("pathLen and name constraints are only allowed for CA")
+	}
+
+	if c.IsSet(flagPathLen) {
+		pathLen := c.Int(flagPathLen)
+		if pathLen < 0 {
+			return fmt.Errorf("invalid pathLen %d", pathLen)
+		}
+		t.MaxPathLen = pathLen
+		t.MaxPathLenZero = pathLen == 0
+	}
+
+	if c.IsSet(flagPermitted) || c.IsSet(flagExcluded) {
+		if err := setNameConstraints(t, c.StringSlice(flagPermitted), c.StringSlice(flagExcluded)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setNameConstraints replaces name constraints of template with permitted and
+// excluded names in "dns:", "ip:", "email:" or "uri:" prefixed format.
+// IP constraints are in CIDR notation, a single IP address is allowed too.
+func setNameConstraints(t *x509.Certificate, permitted, excluded []string) error {
+	t.PermittedDNSDomains, t.ExcludedDNSDomains = nil, nil
+	t.PermittedIPRanges, t.ExcludedIPRanges = nil, nil
+	t.PermittedEmailAddresses, t.ExcludedEmailAddresses = nil, nil
+	t.PermittedURIDomains, t.ExcludedURIDomains = nil, nil
+
+	for _, name := range permitted {
+		if err := addNameConstraint(name, &t.PermittedDNSDomains, &t.PermittedIPRanges,
+			&t.PermittedEmailAddresses, &t.PermittedURIDomains); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range excluded {
+		if err := addNameConstraint(name, &t.ExcludedDNSDomains, &t.ExcludedIPRanges,
+			&t.ExcludedEmailAddresses, &t.ExcludedURIDomains); err != nil {
+			return err
+		}
+	}
+
+	// Name constraints must be critical (RFC 5280 4.2.1.10)
+	t.PermittedDNSDomainsCritical = len(permitted) > 0 || len(excluded) > 0
+
+	return nil
+}
+
+func addNameConstraint(name string, dnsNames *[]string, ipRanges *[]*net.IPNet, emails, uris *[]string) error {
+	prefix, value, found := strings.Cut(name, ":")
+	if !found || value == "" {
+		return fmt.Errorf("invalid name constraint %q, it must have dns, ip, email or uri prefix", name)
+	}
+
+	switch strings.ToLower(prefix) {
+	case "dns":
+		*dnsNames = append(*dnsNames, value)
+	case "ip":
+		ipRange, err := parseIPRange(value)
+		if err != nil {
+			return err
+		}
+		*ipRanges = append(*ipRanges, ipRange)
+	case "email":
+		*emails = append(*emails, value)
+	case "uri":
+		*uris = append(*uris, value)
+	default:
+		return fmt.Errorf("unknown name constraint type %q", prefix)
+	}
+
+	return nil
+}
+
+// parseIPRange parses CIDR like "10.0.0.0/8" or a single IP address
+func parseIPRange(s string) (*net.IPNet, error) {
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", s)
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+	}
+
+	_, ipRange, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IP range %q", s)
+	}
+
+	return ipRange, nil
+}
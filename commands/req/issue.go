@@ -0,0 +1,68 @@
+package req
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"log"
+	"math/big"
+)
+
+// RootCAProfile returns the profile of a self-signed root CA
+func RootCAProfile() *Profile {
+	return &Profile{
+		KeyUsage:         []string{"keyCertSign", "cRLSign", "digitalSignature"},
+		BasicConstraints: &BasicConstraints{CA: true},
+	}
+}
+
+// IntermediateCAProfile returns the profile of an intermediate CA which
+// can only issue end-entity certificates
+func IntermediateCAProfile() *Profile {
+	pathLen := 0
+	return &Profile{
+		KeyUsage:         []string{"keyCertSign", "cRLSign", "digitalSignature"},
+		BasicConstraints: &BasicConstraints{CA: true, PathLen: &pathLen},
+	}
+}
+
+// LeafProfile returns the profile of an end-entity certificate
+// which can be used by TLS servers and clients
+func LeafProfile() *Profile {
+	return &Profile{
+		KeyUsage:         []string{"digitalSignature", "keyEncipherment"},
+		ExtKeyUsage:      []string{"serverAuth", "clientAuth"},
+		BasicConstraints: &BasicConstraints{CA: false},
+	}
+}
+
+// Issue creates a certificate for publicKey with subject, SANs (eg, "dns:example.com")
+// and extensions described in profile p. Certificate is signed by parent with
+// parentKey, or self-signed with parentKey if parent is nil.
+func Issue(p *Profile, subj pkix.Name, sans []string, days uint, serial *big.Int,
+	publicKey crypto.PublicKey, parent *x509.Certificate, parentKey crypto.Signer) (*x509.Certificate, error) {
+	names, err := parseSANs(sans)
+	if err != nil {
+		log.Printf("Failed to parse SANs error: %v", err)
+		return nil, err
+	}
+
+	t, err := p.certTemplate(subj, names, days, serial)
+	if err != nil {
+		log.Printf("Failed to generate template from profile error: %v", err)
+		return nil, err
+	}
+
+	if parent == nil {
+		parent = t
+	}
+
+	certx509, err := x509.CreateCertificate(rand.Reader, t, parent, publicKey, parentKey)
+	if err != nil {
+		log.Printf("Failed to create certificate error: %v", err)
+		return nil, err
+	}
+
+	return x509.ParseCertificate(certx509)
+}
@@ -0,0 +1,217 @@
+package req
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Profile describes subject, SANs and extensions of a certificate. It is
+// read from a YAML or JSON file and replaces the default values of cert command.
+type Profile struct {
+	Subject            *ProfileSubject   `json:"subject" yaml:"subject"`
+	SANs               []string          `json:"sans" yaml:"sans"`
+	Days               uint              `json:"days" yaml:"days"`
+	KeyUsage           []string          `json:"keyUsage" yaml:"keyUsage"`
+	ExtKeyUsage        []string          `json:"extKeyUsage" yaml:"extKeyUsage"`
+	BasicConstraints   *BasicConstraints `json:"basicConstraints" yaml:"basicConstraints"`
+	NameConstraints    *NameConstraints  `json:"nameConstraints" yaml:"nameConstraints"`
+	Policies           []string          `json:"policies" yaml:"policies"`
+	CRLURLs            []string          `json:"crlDistributionPoints" yaml:"crlDistributionPoints"`
+	OCSPURLs           []string          `json:"ocspServers" yaml:"ocspServers"`
+	IssuerURLs         []string          `json:"issuingCertificateURLs" yaml:"issuingCertificateURLs"`
+	SignatureAlgorithm string            `json:"signatureAlgorithm" yaml:"signatureAlgorithm"`
+}
+
+// ProfileSubject is the subject of a certificate described in profile
+type ProfileSubject struct {
+	CommonName         string   `json:"commonName" yaml:"commonName"`
+	Country            []string `json:"country" yaml:"country"`
+	Province           []string `json:"province" yaml:"province"`
+	Locality           []string `json:"locality" yaml:"locality"`
+	Organization       []string `json:"organization" yaml:"organization"`
+	OrganizationalUnit []string `json:"organizationalUnit" yaml:"organizationalUnit"`
+	StreetAddress      []string `json:"streetAddress" yaml:"streetAddress"`
+	PostalCode         []string `json:"postalCode" yaml:"postalCode"`
+	Email              string   `json:"email" yaml:"email"`
+}
+
+// BasicConstraints describes whether certificate is a CA and its maximum path length
+type BasicConstraints struct {
+	CA      bool `json:"ca" yaml:"ca"`
+	PathLen *int `json:"pathLen" yaml:"pathLen"`
+}
+
+// NameConstraints describes names a CA is allowed to issue certificates for,
+// in "dns:", "ip:", "email:" or "uri:" prefixed format
+type NameConstraints struct {
+	Permitted []string `json:"permitted" yaml:"permitted"`
+	Excluded  []string `json:"excluded" yaml:"excluded"`
+}
+
+// readProfile reads profile from a JSON file if its extension is .json,
+// otherwise from a YAML file
+func readProfile(path string) (*Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("Failed to read profile file %q error: %v", path, err)
+		return nil, err
+	}
+
+	p := &Profile{}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, p)
+	} else {
+		err = yaml.Unmarshal(data, p)
+	}
+	if err != nil {
+		log.Printf("Failed to parse profile file %q error: %v", path, err)
+		return nil, err
+	}
+
+	// Parse once to report errors before asking any questions
+	if _, err = p.certTemplate(pkix.Name{}, altNames{}, 0, big.NewInt(0)); err != nil {
+		log.Printf("Invalid profile file %q error: %v", path, err)
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// isCA reports whether profile describes a Certificate Authority
+func (p *Profile) isCA() bool {
+	return p != nil && p.BasicConstraints != nil && p.BasicConstraints.CA
+}
+
+// name returns subject of profile with e-mail address separately
+func (p *Profile) name() (pkix.Name, string) {
+	if p == nil || p.Subject == nil {
+		return pkix.Name{}, ""
+	}
+
+	return pkix.Name{
+		CommonName:         p.Subject.CommonName,
+		Country:            p.Subject.Country,
+		Province:           p.Subject.Province,
+		Locality:           p.Subject.Locality,
+		Organization:       p.Subject.Organization,
+		OrganizationalUnit: p.Subject.OrganizationalUnit,
+		StreetAddress:      p.Subject.StreetAddress,
+		PostalCode:         p.Subject.PostalCode,
+	}, p.Subject.Email
+}
+
+// certTemplate returns certificate template with extensions described in profile
+func (p *Profile) certTemplate(subj pkix.Name, sans altNames, days uint, serial *big.Int) (*x509.Certificate, error) {
+	keyUsage, err := parseKeyUsage(p.KeyUsage)
+	if err != nil {
+		return nil, err
+	}
+
+	extKeyUsage, err := parseExtKeyUsage(p.ExtKeyUsage)
+	if err != nil {
+		return nil, err
+	}
+
+	sigAlg, err := parseSignatureAlgorithm(p.SignatureAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               subj,
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().AddDate(0, 0, int(days)),
+		KeyUsage:              keyUsage,
+		ExtKeyUsage:           extKeyUsage,
+		SignatureAlgorithm:    sigAlg,
+		DNSNames:              sans.DNSNames,
+		IPAddresses:           sans.IPAddresses,
+		EmailAddresses:        sans.EmailAddresses,
+		URIs:                  sans.URIs,
+		CRLDistributionPoints: p.CRLURLs,
+		OCSPServer:            p.OCSPURLs,
+		IssuingCertificateURL: p.IssuerURLs,
+		BasicConstraintsValid: true,
+	}
+
+	if p.BasicConstraints != nil {
+		t.IsCA = p.BasicConstraints.CA
+		if pathLen := p.BasicConstraints.PathLen; pathLen != nil {
+			if !t.IsCA {
+				return nil, fmt.Errorf("pathLen is only allowed for CA")
+			}
+			if *pathLen < 0 {
+				return nil, fmt.Errorf("invalid pathLen %d", *pathLen)
+			}
+			t.MaxPathLen = *pathLen
+			t.MaxPathLenZero = *pathLen == 0
+		}
+	}
+
+	if p.NameConstraints != nil {
+		if !t.IsCA {
+			return nil, fmt.Errorf("nameConstraints is only allowed for CA")
+		}
+		if err = setNameConstraints(t, p.NameConstraints.Permitted, p.NameConstraints.Excluded); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, policy := range p.Policies {
+		oid, err := parseOID(policy)
+		if err != nil {
+			return nil, err
+		}
+		t.PolicyIdentifiers = append(t.PolicyIdentifiers, oid)
+	}
+
+	return t, nil
+}
+
+// parseSignatureAlgorithm parses signature algorithm name like "SHA256-RSA"
+// or "ECDSA-SHA384". Empty name means the default algorithm of the key.
+func parseSignatureAlgorithm(name string) (x509.SignatureAlgorithm, error) {
+	if name == "" {
+		return x509.UnknownSignatureAlgorithm, nil
+	}
+
+	for alg := x509.MD2WithRSA; alg <= x509.PureEd25519; alg++ {
+		if strings.EqualFold(alg.String(), name) {
+			return alg, nil
+		}
+	}
+
+	return x509.UnknownSignatureAlgorithm, fmt.Errorf("unknown signature algorithm %q", name)
+}
+
+// parseOID parses dotted object identifier like "2.23.140.1.2.1"
+func parseOID(s string) (asn1.ObjectIdentifier, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid OID %q", s)
+	}
+
+	oid := make(asn1.ObjectIdentifier, len(parts))
+	for i := range parts {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid OID %q", s)
+		}
+		oid[i] = n
+	}
+
+	return oid, nil
+}
@@ -0,0 +1,131 @@
+package req_test
+
+import (
+	"bytes"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/yakuter/gossl/commands/key"
+	"github.com/yakuter/gossl/commands/req"
+	"github.com/yakuter/gossl/pkg/utils"
+
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func TestProfile(t *testing.T) {
+	const (
+		profilesDir = "../../testdata/profiles"
+		csrFile     = "../../testdata/server-req.pem"
+		caCert      = "../../testdata/ca-cert.pem"
+		caKey       = "../../testdata/ca-key.pem"
+	)
+
+	execName, err := os.Executable()
+	require.NoError(t, err)
+
+	tempDir := t.TempDir()
+	testKey := filepath.Join(tempDir, "test.key")
+	invalidProfile := filepath.Join(tempDir, "invalid.yaml")
+	require.NoError(t, os.WriteFile(invalidProfile, []byte("keyUsage: [wrongUsage]\n"), 0o600))
+
+	keyApp := &cli.App{Commands: []*cli.Command{key.Command()}}
+	require.NoError(t, keyApp.Run([]string{execName, key.CmdKey, "-out", testKey, "-type", "ecdsa"}))
+
+	app := &cli.App{
+		Commands: []*cli.Command{
+			req.Command(&bytes.Buffer{}),
+		},
+	}
+
+	t.Run("root CA profile", func(t *testing.T) {
+		outFile := filepath.Join(tempDir, "root.pem")
+		require.NoError(t, app.Run([]string{execName, req.CmdCert,
+			"--key", testKey,
+			"--out", outFile,
+			"--profile", filepath.Join(profilesDir, "root-ca.yaml"),
+		}))
+
+		cert, err := utils.CertFromFile(outFile)
+		require.NoError(t, err)
+		require.True(t, cert.IsCA)
+		require.Equal(t, 1, cert.MaxPathLen)
+		require.Equal(t, "GoSSL Root CA", cert.Subject.CommonName)
+		require.Equal(t, x509.KeyUsageCertSign|x509.KeyUsageCRLSign|x509.KeyUsageDigitalSignature, cert.KeyUsage)
+		require.Empty(t, cert.ExtKeyUsage)
+		require.Empty(t, cert.IPAddresses)
+		require.Equal(t, 3650, int(cert.NotAfter.Sub(cert.NotBefore).Hours()/24))
+	})
+
+	t.Run("server profile with CSR signing", func(t *testing.T) {
+		outFile := filepath.Join(tempDir, "server.pem")
+		require.NoError(t, app.Run([]string{execName, req.CmdCert,
+			"--csr", csrFile,
+			"--cacert", caCert,
+			"--cakey", caKey,
+			"--out", outFile,
+			"--profile", filepath.Join(profilesDir, "server.yaml"),
+		}))
+
+		cert, err := utils.CertFromFile(outFile)
+		require.NoError(t, err)
+		require.False(t, cert.IsCA)
+		require.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}, cert.ExtKeyUsage)
+		require.Equal(t, []string{"http://pki.example.com/intermediate.crl"}, cert.CRLDistributionPoints)
+		require.Equal(t, []string{"http://ocsp.example.com"}, cert.OCSPServer)
+		require.Equal(t, "2.23.140.1.2.2", cert.PolicyIdentifiers[0].String())
+		require.Equal(t, x509.SHA384WithRSA, cert.SignatureAlgorithm)
+		require.Equal(t, 90, int(cert.NotAfter.Sub(cert.NotBefore).Hours()/24))
+	})
+
+	t.Run("flags take precedence over profile", func(t *testing.T) {
+		outFile := filepath.Join(tempDir, "server-flags.pem")
+		require.NoError(t, app.Run([]string{execName, req.CmdCert,
+			"--csr", csrFile,
+			"--cacert", caCert,
+			"--cakey", caKey,
+			"--out", outFile,
+			"--days", "10",
+			"--extKeyUsage", "clientAuth",
+			"--profile", filepath.Join(profilesDir, "server.yaml"),
+		}))
+
+		cert, err := utils.CertFromFile(outFile)
+		require.NoError(t, err)
+		require.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}, cert.ExtKeyUsage)
+		require.Equal(t, 10, int(cert.NotAfter.Sub(cert.NotBefore).Hours()/24))
+	})
+
+	t.Run("JSON client profile with CSR generation", func(t *testing.T) {
+		outFile := filepath.Join(tempDir, "client.csr")
+		require.NoError(t, app.Run([]string{execName, req.CmdCert,
+			"--key", testKey,
+			"--out", outFile,
+			"--profile", filepath.Join(profilesDir, "client.json"),
+		}))
+
+		csr, err := utils.CSRFromFile(outFile)
+		require.NoError(t, err)
+		require.Equal(t, "client.example.com", csr.Subject.CommonName)
+		require.Equal(t, []string{"client.example.com"}, csr.DNSNames)
+		require.Equal(t, []string{"client@example.com"}, csr.EmailAddresses)
+	})
+
+	t.Run("invalid profile error", func(t *testing.T) {
+		require.Error(t, app.Run([]string{execName, req.CmdCert,
+			"--key", testKey,
+			"--out", filepath.Join(tempDir, "invalid.pem"),
+			"--profile", invalidProfile,
+		}))
+	})
+
+	t.Run("missing profile error", func(t *testing.T) {
+		require.Error(t, app.Run([]string{execName, req.CmdCert,
+			"--key", testKey,
+			"--out", filepath.Join(tempDir, "missing.pem"),
+			"--profile", "wrong-file",
+		}))
+	})
+}
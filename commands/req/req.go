@@ -1,18 +1,16 @@
 package req
 
 import (
+	"crypto"
 	"crypto/rand"
-	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
-	"encoding/asn1"
 	"errors"
 	"io"
 	"log"
 	"math/big"
 	"net"
 	"os"
-	"strings"
 	"time"
 
 	"github.com/yakuter/gossl/pkg/utils"
@@ -28,6 +26,19 @@ const (
 	flagDays   = "days"
 	flagSerial = "serial"
 	flagIsCA   = "isCA"
+
+	flagCSR         = "csr"
+	flagCACert      = "cacert"
+	flagCAKey       = "cakey"
+	flagKeyUsage    = "keyUsage"
+	flagExtKeyUsage = "extKeyUsage"
+	flagPassword    = "password"
+	flagSubj        = "subj"
+	flagSAN         = "san"
+	flagProfile     = "profile"
+	flagPathLen     = "pathLen"
+	flagPermitted   = "permitted"
+	flagExcluded    = "excluded"
 )
 
 func Command(reader io.Reader) *cli.Command {
@@ -46,8 +57,8 @@ func Flags() []cli.Flag {
 	return []cli.Flag{
 		&cli.StringFlag{
 			Name:     flagKey,
-			Usage:    "private key (required)",
-			Required: true,
+			Usage:    "Private key to generate CSR or CA with (required unless csr is set)",
+			Required: false,
 		},
 		&cli.StringFlag{
 			Name:        flagOut,
@@ -64,14 +75,86 @@ func Flags() []cli.Flag {
 		},
 		&cli.Uint64Flag{
 			Name:        flagSerial,
-			Usage:       "Serial number to use in certificate",
-			DefaultText: "123456",
-			Value:       1,
+			Usage:       "Serial number to use in certificate, random 128-bit serial if not set",
+			DefaultText: "random",
 			Required:    false,
 		},
 		&cli.BoolFlag{
 			Name:     flagIsCA,
-			Usage:    "Is Root Certificate Authority (CA) flag",
+			Usage:    "Is Certificate Authority (CA) flag, root CA with key or intermediate CA with csr",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:        flagCSR,
+			Usage:       "CSR file to sign with CA cert and CA key (optional)",
+			DefaultText: "eg, ./server.csr",
+			Required:    false,
+		},
+		&cli.StringFlag{
+			Name:        flagCACert,
+			Usage:       "CA cert file to sign CSR with (required with csr)",
+			DefaultText: "eg, ./ca.pem",
+			Required:    false,
+		},
+		&cli.StringFlag{
+			Name:        flagCAKey,
+			Usage:       "CA private key file to sign CSR with (required with csr)",
+			DefaultText: "eg, ./ca.key",
+			Required:    false,
+		},
+		&cli.StringSliceFlag{
+			Name:        flagKeyUsage,
+			Usage:       "Key usages of the signed certificate (eg, digitalSignature,keyEncipherment)",
+			DefaultText: "digitalSignature,keyEncipherment",
+			Value:       cli.NewStringSlice("digitalSignature", "keyEncipherment"),
+			Required:    false,
+		},
+		&cli.StringSliceFlag{
+			Name:        flagExtKeyUsage,
+			Usage:       "Extended key usages of the signed certificate (eg, serverAuth,clientAuth)",
+			DefaultText: "serverAuth,clientAuth",
+			Value:       cli.NewStringSlice("serverAuth", "clientAuth"),
+			Required:    false,
+		},
+		&cli.StringFlag{
+			Name:        flagSubj,
+			Usage:       "Subject in OpenSSL format, asked if not set (optional)",
+			DefaultText: "eg, /C=TR/O=Acme/CN=api.example.com",
+			Required:    false,
+		},
+		&cli.StringSliceFlag{
+			Name:        flagSAN,
+			Usage:       "Subject Alternative Name with dns, ip, email or uri prefix, can be repeated (optional)",
+			DefaultText: "eg, dns:api.example.com",
+			Required:    false,
+		},
+		&cli.IntFlag{
+			Name:        flagPathLen,
+			Usage:       "Maximum number of intermediate CAs below the CA (CA only, optional)",
+			DefaultText: "unlimited",
+			Required:    false,
+		},
+		&cli.StringSliceFlag{
+			Name:        flagPermitted,
+			Usage:       "Permitted name constraint with dns, ip (CIDR), email or uri prefix, can be repeated (CA only, optional)",
+			DefaultText: "eg, dns:example.com",
+			Required:    false,
+		},
+		&cli.StringSliceFlag{
+			Name:        flagExcluded,
+			Usage:       "Excluded name constraint with dns, ip (CIDR), email or uri prefix, can be repeated (CA only, optional)",
+			DefaultText: "eg, ip:10.0.0.0/8",
+			Required:    false,
+		},
+		&cli.StringFlag{
+			Name:        flagProfile,
+			Usage:       "YAML or JSON profile file describing subject, SANs and extensions (optional)",
+			DefaultText: "eg, ./server.yaml",
+			Required:    false,
+		},
+		&cli.StringFlag{
+			Name:     flagPassword,
+			Usage:    "Pass phrase of encrypted private key or CA key, asked if not set (optional)",
 			Required: false,
 		},
 	}
@@ -79,11 +162,9 @@ func Flags() []cli.Flag {
 
 func Action(reader io.Reader) func(c *cli.Context) error {
 	return func(c *cli.Context) error {
-		// Get privatekey from file
-		privateKey, err := utils.PrivateKeyFromFile(c.String(flagKey))
-		if err != nil {
-			log.Printf("Failed to get key from key file %s error: %v", c.String(flagKey), err)
-			return err
+		// At least one of the flag is required
+		if !c.IsSet(flagKey) && !c.IsSet(flagCSR) {
+			return errors.New("Please provide key or csr flag")
 		}
 
 		// Set output
@@ -93,18 +174,59 @@ func Action(reader io.Reader) func(c *cli.Context) error {
 			outputFilePath = c.String(flagOut)
 		}
 
-		// Generate subject (pkix.Name) from answers
-		subj, dns, email, err := subject(reader)
+		// Read profile describing the certificate if provided
+		var p *Profile
+		if c.IsSet(flagProfile) {
+			var err error
+			p, err = readProfile(c.String(flagProfile))
+			if err != nil {
+				log.Printf("Failed to read profile error: %v", err)
+				return err
+			}
+		}
+
+		// Sign CSR with CA instead of generating a new one
+		if c.IsSet(flagCSR) {
+			outPEM, err := sign(c, p)
+			if err != nil {
+				log.Printf("Failed to sign CSR error: %v", err)
+				return err
+			}
+
+			// Write x509 certificate to file
+			if err = os.WriteFile(outputFilePath, outPEM, 0o600); err != nil {
+				log.Printf("Failed to write PEM to file %s error: %v", outputFilePath, err)
+				return err
+			}
+
+			log.Printf("Certificate signed")
+			return nil
+		}
+
+		// Get privatekey from file
+		privateKey, err := utils.PrivateKeyFromFileWithPassword(c.String(flagKey), utils.KeyPasswordReader(c, flagPassword, c.String(flagKey)))
+		if err != nil {
+			log.Printf("Failed to get key from key file %s error: %v", c.String(flagKey), err)
+			return err
+		}
+
+		// Generate subject (pkix.Name) and SANs from flags and answers
+		subj, sans, err := subject(c, reader, p)
 		if err != nil {
 			log.Printf("Failed to generate subject error: %v", err)
 			return err
 		}
 
 		var outPEM []byte
-		if c.Bool(flagIsCA) {
-			outPEM, err = generateCA(subj, dns, c.Uint(flagDays), c.Uint64(flagSerial), privateKey)
+		if c.Bool(flagIsCA) || p.isCA() {
+			var serial *big.Int
+			if serial, err = serialNumber(c); err != nil {
+				log.Printf("Failed to generate serial number error: %v", err)
+				return err
+			}
+			outPEM, err = generateCA(c, subj, sans, days(c, p), serial, privateKey, p)
 		} else {
-			outPEM, err = generateCSR(subj, dns, email, privateKey)
+			outPEM, err = generateCSR(subj, sans, privateKey, p)
 		}
 		if err != nil {
 			log.Printf("Failed to create cert error: %v", err)
@@ -122,55 +244,34 @@ func Action(reader io.Reader) func(c *cli.Context) error {
 	}
 }
 
-func subject(reader io.Reader) (pkix.Name, []string, string, error) {
-	// Prepare questions which are needed for subject
-	questions := []string{
-		"Common Name - SAN (eg, FQDN or IP)* []",
-		"E-mail address* []",
-		"Country Name (2 letter code) [AU]",
-		"State or Province Name []",
-		"Locality Name (eg, city) []",
-		"Organization Name [eg, company]",
-		"Organizational Unit Name (eg, section) []",
-		"Street Addr []",
-		"Postal Code []",
+// days returns validity in days from flag, or from profile if flag is not set
+func days(c *cli.Context, p *Profile) uint {
+	if p != nil && p.Days > 0 && !c.IsSet(flagDays) {
+		return p.Days
 	}
 
-	// Ask questions to user and get inputs as answers
-	answers, err := utils.ReadInputs(questions, reader)
-	if err != nil {
-		log.Printf("failed to read inputs %v", err)
-		return pkix.Name{}, []string{}, "", err
-	}
-
-	email := answers[1]
+	return c.Uint(flagDays)
+}
 
-	if len(answers[0]) == 0 {
-		err = errors.New("Common Name - SAN cannot be empty")
-		log.Printf("%v", err)
-		return pkix.Name{}, []string{}, "", err
+func generateCA(c *cli.Context, subj pkix.Name, sans altNames, days uint, serial *big.Int, privateKey crypto.Signer, p *Profile) ([]byte, error) {
+	// Generate template (x509 certificate) from profile if provided
+	t := templateCA(subj, sans, days, serial)
+	if p != nil {
+		var err error
+		if t, err = p.certTemplate(subj, sans, days, serial); err != nil {
+			log.Printf("Failed to generate template from profile error: %v", err)
+			return nil, err
+		}
+		t.IsCA = true
 	}
 
-	sans := strings.Split(answers[0], ",")
-
-	return pkix.Name{
-		CommonName:         sans[0],
-		Country:            strings.Split(answers[2], ","),
-		Province:           strings.Split(answers[3], ","),
-		Locality:           strings.Split(answers[4], ","),
-		Organization:       strings.Split(answers[5], ","),
-		OrganizationalUnit: strings.Split(answers[6], ","),
-		StreetAddress:      strings.Split(answers[7], ","),
-		PostalCode:         strings.Split(answers[8], ","),
-	}, sans, email, nil
-}
-
-func generateCA(subj pkix.Name, dns []string, days uint, serial uint64, privateKey *rsa.PrivateKey) ([]byte, error) {
-	// Generate template (x509 certificate)
-	t := templateCA(subj, dns, days, serial)
+	if err := constrainCA(c, t); err != nil {
+		log.Printf("Failed to set CA constraints error: %v", err)
+		return nil, err
+	}
 
 	// Create x509 certificate
-	certx509, err := x509.CreateCertificate(rand.Reader, t, t, &privateKey.PublicKey, privateKey)
+	certx509, err := x509.CreateCertificate(rand.Reader, t, t, privateKey.Public(), privateKey)
 	if err != nil {
 		log.Printf("Failed to create certificate error: %v", err)
 		return nil, err
@@ -180,40 +281,37 @@ func generateCA(subj pkix.Name, dns []string, days uint, serial uint64, privateK
 	return utils.CertToPEM(certx509), nil
 }
 
-func templateCA(subj pkix.Name, dns []string, days uint, serial uint64) *x509.Certificate {
+func templateCA(subj pkix.Name, sans altNames, days uint, serial *big.Int) *x509.Certificate {
 	t := &x509.Certificate{
-		SerialNumber:          big.NewInt(int64(serial)),
+		SerialNumber:          serial,
 		Subject:               subj,
 		NotBefore:             time.Now(),
 		NotAfter:              time.Now().AddDate(0, 0, int(days)),
 		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
-		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
 		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
 		IsCA:                  true,
 		BasicConstraintsValid: true,
 	}
 
-	for i := range dns {
-		addr := net.ParseIP(dns[i])
-		if addr != nil {
-			t.IPAddresses = append(t.IPAddresses, addr)
-		} else {
-			t.DNSNames = append(t.DNSNames, dns[i])
-		}
-	}
+	t.DNSNames = sans.DNSNames
+	t.IPAddresses = append(t.IPAddresses, sans.IPAddresses...)
+	t.EmailAddresses = sans.EmailAddresses
+	t.URIs = sans.URIs
 
 	return t
 }
 
-func generateCSR(subj pkix.Name, dns []string, email string, privateKey *rsa.PrivateKey) ([]byte, error) {
-	if len(email) == 0 {
-		err := errors.New("E-mail address cannot be empty")
-		log.Printf("%v", err)
-		return nil, err
-	}
-
+func generateCSR(subj pkix.Name, sans altNames, privateKey crypto.Signer, p *Profile) ([]byte, error) {
 	// Generate template (x509.CertificateRequest)
-	t := templateCSR(subj, dns, email)
+	t := templateCSR(subj, sans)
+	if p != nil {
+		var err error
+		if t.SignatureAlgorithm, err = parseSignatureAlgorithm(p.SignatureAlgorithm); err != nil {
+			log.Printf("Failed to parse signature algorithm error: %v", err)
+			return nil, err
+		}
+	}
 
 	// Create x509 certificate request (CSR)
 	csrBytes, err := x509.CreateCertificateRequest(rand.Reader, t, privateKey)
@@ -226,32 +324,14 @@ func generateCSR(subj pkix.Name, dns []string, email string, privateKey *rsa.Pri
 	return utils.CSRToPEM(csrBytes), nil
 }
 
-func templateCSR(subj pkix.Name, dns []string, email string) *x509.CertificateRequest {
-	oidEmailAddress := asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 1}
-
-	subj.ExtraNames = []pkix.AttributeTypeAndValue{
-		{
-			Type: oidEmailAddress,
-			Value: asn1.RawValue{
-				Tag:   asn1.TagIA5String,
-				Bytes: []byte(email),
-			},
-		},
-	}
-
-	t := &x509.CertificateRequest{
-		Subject:            subj,
-		SignatureAlgorithm: x509.SHA256WithRSA,
+func templateCSR(subj pkix.Name, sans altNames) *x509.CertificateRequest {
+	// Signature algorithm is left empty to be chosen by the private key type
+	// unless it is set by profile
+	return &x509.CertificateRequest{
+		Subject:        subj,
+		DNSNames:       sans.DNSNames,
+		IPAddresses:    sans.IPAddresses,
+		EmailAddresses: sans.EmailAddresses,
+		URIs:           sans.URIs,
 	}
-
-	for i := range dns {
-		addr := net.ParseIP(dns[i])
-		if addr != nil {
-			t.IPAddresses = append(t.IPAddresses, addr)
-		} else {
-			t.DNSNames = append(t.DNSNames, dns[i])
-		}
-	}
-
-	return t
 }
@@ -9,6 +9,7 @@ import (
 
 	"github.com/yakuter/gossl/commands/key"
 	"github.com/yakuter/gossl/commands/req"
+	"github.com/yakuter/gossl/pkg/utils"
 
 	"github.com/stretchr/testify/require"
 	"github.com/urfave/cli/v2"
@@ -21,10 +22,16 @@ func TestReq(t *testing.T) {
 	tempDir := t.TempDir()
 	outFile := filepath.Join(tempDir, "test.cert")
 	testKey := filepath.Join(tempDir, "test.key")
+	testECKey := filepath.Join(tempDir, "test-ec.key")
+	testEdKey := filepath.Join(tempDir, "test-ed.key")
 
 	keyApp := &cli.App{Commands: []*cli.Command{key.Command()}}
 	err = keyApp.Run([]string{execName, key.CmdKey, "-out", testKey, "-bits", "2048"})
 	require.NoError(t, err)
+	err = keyApp.Run([]string{execName, key.CmdKey, "-out", testECKey, "-type", "ecdsa"})
+	require.NoError(t, err)
+	err = keyApp.Run([]string{execName, key.CmdKey, "-out", testEdKey, "-type", "ed25519"})
+	require.NoError(t, err)
 
 	testCases := []struct {
 		name      string
@@ -59,7 +66,28 @@ func TestReq(t *testing.T) {
 			shouldErr: false,
 		},
 		{
-			name:      "empty email CSR error",
+			name:      "valid ECDSA CA",
+			fqdn:      "localhost",
+			key:       testECKey,
+			out:       outFile,
+			days:      365,
+			serial:    123456,
+			isCA:      true,
+			shouldErr: false,
+		},
+		{
+			name:      "valid Ed25519 CSR",
+			fqdn:      "localhost",
+			email:     "john@doe.com",
+			key:       testEdKey,
+			out:       outFile,
+			days:      365,
+			serial:    123456,
+			isCA:      false,
+			shouldErr: false,
+		},
+		{
+			name:      "valid CSR without email",
 			fqdn:      "localhost",
 			email:     "",
 			key:       testKey,
@@ -67,7 +95,7 @@ func TestReq(t *testing.T) {
 			days:      365,
 			serial:    123456,
 			isCA:      false,
-			shouldErr: true,
+			shouldErr: false,
 		},
 		{
 			name:      "empty FQDN error",
@@ -130,3 +158,127 @@ func TestReq(t *testing.T) {
 		})
 	}
 }
+
+func TestReqSubjectFlags(t *testing.T) {
+	execName, err := os.Executable()
+	require.NoError(t, err)
+
+	tempDir := t.TempDir()
+	outFile := filepath.Join(tempDir, "test.csr")
+	testKey := filepath.Join(tempDir, "test.key")
+
+	keyApp := &cli.App{Commands: []*cli.Command{key.Command()}}
+	err = keyApp.Run([]string{execName, key.CmdKey, "-out", testKey, "-type", "ecdsa"})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name       string
+		subj       string
+		sans       []string
+		stdin      string
+		commonName string
+		dnsNames   []string
+		ips        []string
+		emails     []string
+		uris       []string
+		shouldErr  bool
+	}{
+		{
+			name:       "subj and sans without prompts",
+			subj:       "/C=TR/O=Acme/CN=api.example.com",
+			sans:       []string{"dns:api.example.com", "ip:10.0.0.1", "email:ops@example.com", "uri:spiffe://example.com/api"},
+			commonName: "api.example.com",
+			dnsNames:   []string{"api.example.com"},
+			ips:        []string{"10.0.0.1"},
+			emails:     []string{"ops@example.com"},
+			uris:       []string{"spiffe://example.com/api"},
+		},
+		{
+			name:       "subj only uses common name as SAN",
+			subj:       "/O=Acme\\/Inc/CN=www.example.com",
+			commonName: "www.example.com",
+			dnsNames:   []string{"www.example.com"},
+		},
+		{
+			name:       "subj without common name uses first SAN",
+			subj:       "/O=Acme",
+			sans:       []string{"::1", "dns:first.example.com"},
+			commonName: "first.example.com",
+			dnsNames:   []string{"first.example.com"},
+			ips:        []string{"::1"},
+		},
+		{
+			name:       "subj without common name and SAN asks common name",
+			subj:       "/O=Acme",
+			stdin:      "asked.example.com\n",
+			commonName: "asked.example.com",
+			dnsNames:   []string{"asked.example.com"},
+		},
+		{
+			name:       "sans without subj asks other fields",
+			sans:       []string{"dns:prompt.example.com"},
+			stdin:      "\nTR\n\n\n\n\n\n\n",
+			commonName: "prompt.example.com",
+			dnsNames:   []string{"prompt.example.com"},
+		},
+		{
+			name:      "unknown subj field error",
+			subj:      "/XX=Acme/CN=api.example.com",
+			shouldErr: true,
+		},
+		{
+			name:      "subj without leading slash error",
+			subj:      "CN=api.example.com",
+			shouldErr: true,
+		},
+		{
+			name:      "invalid ip SAN error",
+			subj:      "/CN=api.example.com",
+			sans:      []string{"ip:not-an-ip"},
+			shouldErr: true,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.name, func(t *testing.T) {
+			testArgs := []string{execName, req.CmdCert, "--key", testKey, "--out", outFile}
+			if tC.subj != "" {
+				testArgs = append(testArgs, "--subj", tC.subj)
+			}
+			for _, san := range tC.sans {
+				testArgs = append(testArgs, "--san", san)
+			}
+
+			stdin := bytes.NewBufferString(tC.stdin)
+			app := &cli.App{
+				Commands: []*cli.Command{
+					req.Command(stdin),
+				},
+			}
+
+			if tC.shouldErr {
+				require.Error(t, app.Run(testArgs))
+				return
+			}
+			require.NoError(t, app.Run(testArgs))
+
+			csr, err := utils.CSRFromFile(outFile)
+			require.NoError(t, err)
+			require.Equal(t, tC.commonName, csr.Subject.CommonName)
+			require.Equal(t, tC.dnsNames, csr.DNSNames)
+			require.Equal(t, tC.emails, csr.EmailAddresses)
+
+			var ips []string
+			for _, ip := range csr.IPAddresses {
+				ips = append(ips, ip.String())
+			}
+			require.Equal(t, tC.ips, ips)
+
+			var uris []string
+			for _, uri := range csr.URIs {
+				uris = append(uris, uri.String())
+			}
+			require.Equal(t, tC.uris, uris)
+		})
+	}
+}
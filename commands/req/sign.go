@@ -0,0 +1,211 @@
+package req
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/yakuter/gossl/pkg/utils"
+
+	"github.com/urfave/cli/v2"
+)
+
+// keyUsages maps OpenSSL style key usage names to x509 key usages
+var keyUsages = map[string]x509.KeyUsage{
+	"digitalSignature":  x509.KeyUsageDigitalSignature,
+	"nonRepudiation":    x509.KeyUsageContentCommitment,
+	"contentCommitment": x509.KeyUsageContentCommitment,
+	"keyEncipherment":   x509.KeyUsageKeyEncipherment,
+	"dataEncipherment":  x509.KeyUsageDataEncipherment,
+	"keyAgreement":      x509.KeyUsageKeyAgreement,
+	"keyCertSign":       x509.KeyUsageCertSign,
+	"cRLSign":           x509.KeyUsageCRLSign,
+	"encipherOnly":      x509.KeyUsageEncipherOnly,
+	"decipherOnly":      x509.KeyUsageDecipherOnly,
+}
+
+// extKeyUsages maps OpenSSL style extended key usage names to x509 extended key usages
+var extKeyUsages = map[string]x509.ExtKeyUsage{
+	"any":             x509.ExtKeyUsageAny,
+	"serverAuth":      x509.ExtKeyUsageServerAuth,
+	"clientAuth":      x509.ExtKeyUsageClientAuth,
+	"codeSigning":     x509.ExtKeyUsageCodeSigning,
+	"emailProtection": x509.ExtKeyUsageEmailProtection,
+	"timeStamping":    x509.ExtKeyUsageTimeStamping,
+	"OCSPSigning":     x509.ExtKeyUsageOCSPSigning,
+}
+
+// sign reads CSR, CA cert and CA key provided with flags and
+// returns a CA signed certificate in PEM format. An intermediate CA
+// certificate is returned if isCA flag is set or profile describes a CA.
+func sign(c *cli.Context, p *Profile) ([]byte, error) {
+	if !c.IsSet(flagCACert) || !c.IsSet(flagCAKey) {
+		return nil, errors.New("Please provide cacert and cakey flags to sign CSR")
+	}
+
+	csr, err := utils.CSRFromFile(c.String(flagCSR))
+	if err != nil {
+		log.Printf("Failed to get CSR from file %s error: %v", c.String(flagCSR), err)
+		return nil, err
+	}
+
+	caCert, err := utils.CertFromFile(c.String(flagCACert))
+	if err != nil {
+		log.Printf("Failed to get CA cert from file %s error: %v", c.String(flagCACert), err)
+		return nil, err
+	}
+
+	caKey, err := utils.PrivateKeyFromFileWithPassword(c.String(flagCAKey), utils.KeyPasswordReader(c, flagPassword, c.String(flagCAKey)))
+	if err != nil {
+		log.Printf("Failed to get CA key from file %s error: %v", c.String(flagCAKey), err)
+		return nil, err
+	}
+
+	keyUsage, err := parseKeyUsage(c.StringSlice(flagKeyUsage))
+	if err != nil {
+		log.Printf("Failed to parse key usage error: %v", err)
+		return nil, err
+	}
+
+	extKeyUsage, err := parseExtKeyUsage(c.StringSlice(flagExtKeyUsage))
+	if err != nil {
+		log.Printf("Failed to parse extended key usage error: %v", err)
+		return nil, err
+	}
+
+	// Key encipherment is only meaningful for RSA keys
+	if !c.IsSet(flagKeyUsage) && csr.PublicKeyAlgorithm != x509.RSA {
+		keyUsage &^= x509.KeyUsageKeyEncipherment
+	}
+
+	isCA := c.Bool(flagIsCA) || p.isCA()
+
+	serial, err := serialNumber(c)
+	if err != nil {
+		log.Printf("Failed to generate serial number error: %v", err)
+		return nil, err
+	}
+
+	t := templateLeaf(csr, days(c, p), serial)
+	if isCA {
+		t = templateIntermediate(csr, days(c, p), serial)
+	}
+	if p != nil {
+		sans := altNames{
+			DNSNames:       csr.DNSNames,
+			IPAddresses:    csr.IPAddresses,
+			EmailAddresses: csr.EmailAddresses,
+			URIs:           csr.URIs,
+		}
+		if t, err = p.certTemplate(csr.Subject, sans, days(c, p), serial); err != nil {
+			log.Printf("Failed to generate template from profile error: %v", err)
+			return nil, err
+		}
+		t.IsCA = isCA
+	}
+
+	// Usage flags take precedence over profile and intermediate CA defaults
+	if c.IsSet(flagKeyUsage) || (p == nil && !isCA) {
+		t.KeyUsage = keyUsage
+	}
+	if c.IsSet(flagExtKeyUsage) || (p == nil && !isCA) {
+		t.ExtKeyUsage = extKeyUsage
+	}
+
+	if err = constrainCA(c, t); err != nil {
+		log.Printf("Failed to set CA constraints error: %v", err)
+		return nil, err
+	}
+
+	return signCSR(csr, t, caCert, caKey)
+}
+
+func signCSR(csr *x509.CertificateRequest, t, caCert *x509.Certificate, caKey crypto.Signer) ([]byte, error) {
+	// Make sure CSR is signed by the owner of its public key
+	if err := csr.CheckSignature(); err != nil {
+		log.Printf("Failed to check CSR signature error: %v", err)
+		return nil, err
+	}
+
+	if !caCert.IsCA {
+		err := errors.New("CA cert is not a Certificate Authority")
+		log.Printf("%v", err)
+		return nil, err
+	}
+
+	// Create x509 certificate signed by CA
+	certx509, err := x509.CreateCertificate(rand.Reader, t, caCert, csr.PublicKey, caKey)
+	if err != nil {
+		log.Printf("Failed to create certificate error: %v", err)
+		return nil, err
+	}
+
+	// Return cert in PEM format
+	return utils.CertToPEM(certx509), nil
+}
+
+func templateLeaf(csr *x509.CertificateRequest, days uint, serial *big.Int) *x509.Certificate {
+	return &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               csr.Subject,
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().AddDate(0, 0, int(days)),
+		DNSNames:              csr.DNSNames,
+		IPAddresses:           csr.IPAddresses,
+		EmailAddresses:        csr.EmailAddresses,
+		URIs:                  csr.URIs,
+		IsCA:                  false,
+		BasicConstraintsValid: true,
+	}
+}
+
+// templateIntermediate returns template of an intermediate CA which can sign
+// certificates and CRLs. Extended key usages are not restricted by default.
+func templateIntermediate(csr *x509.CertificateRequest, days uint, serial *big.Int) *x509.Certificate {
+	t := templateLeaf(csr, days, serial)
+	t.IsCA = true
+	t.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
+
+	return t
+}
+
+// serialNumber returns serial flag, or a random serial so that
+// certificates issued by the same CA never share a serial number
+func serialNumber(c *cli.Context) (*big.Int, error) {
+	if c.IsSet(flagSerial) {
+		return new(big.Int).SetUint64(c.Uint64(flagSerial)), nil
+	}
+
+	return utils.RandomSerial()
+}
+
+func parseKeyUsage(names []string) (x509.KeyUsage, error) {
+	var usage x509.KeyUsage
+	for _, name := range names {
+		ku, ok := keyUsages[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown key usage %q", name)
+		}
+		usage |= ku
+	}
+
+	return usage, nil
+}
+
+func parseExtKeyUsage(names []string) ([]x509.ExtKeyUsage, error) {
+	usages := make([]x509.ExtKeyUsage, 0, len(names))
+	for _, name := range names {
+		eku, ok := extKeyUsages[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown extended key usage %q", name)
+		}
+		usages = append(usages, eku)
+	}
+
+	return usages, nil
+}
@@ -0,0 +1,345 @@
+package req_test
+
+import (
+	"bytes"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/yakuter/gossl/commands/key"
+	"github.com/yakuter/gossl/commands/req"
+	"github.com/yakuter/gossl/pkg/utils"
+
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func TestSign(t *testing.T) {
+	const (
+		csrFile  = "../../testdata/server-req.pem"
+		caCert   = "../../testdata/ca-cert.pem"
+		caKey    = "../../testdata/ca-key.pem"
+		caKeyEnc = "../../testdata/ca-key-encrypted.pem"
+	)
+
+	app := &cli.App{
+		Commands: []*cli.Command{
+			req.Command(os.Stdin),
+		},
+	}
+
+	execName, err := os.Executable()
+	require.NoError(t, err)
+
+	outFile := filepath.Join(t.TempDir(), "signed.pem")
+
+	testCases := []struct {
+		name        string
+		csr         string
+		cacert      string
+		cakey       string
+		extKeyUsage string
+		password    string
+		isCA        bool
+		shouldErr   bool
+	}{
+		{
+			name:   "valid CSR signing",
+			csr:    csrFile,
+			cacert: caCert,
+			cakey:  caKey,
+		},
+		{
+			name:        "valid CSR signing with ext key usage",
+			csr:         csrFile,
+			cacert:      caCert,
+			cakey:       caKey,
+			extKeyUsage: "serverAuth",
+		},
+		{
+			name:     "valid CSR signing with encrypted CA key",
+			csr:      csrFile,
+			cacert:   caCert,
+			cakey:    caKeyEnc,
+			password: "gossl",
+		},
+		{
+			name:      "wrong password of encrypted CA key error",
+			csr:       csrFile,
+			cacert:    caCert,
+			cakey:     caKeyEnc,
+			password:  "wrong",
+			shouldErr: true,
+		},
+		{
+			name:      "missing CA key error",
+			csr:       csrFile,
+			cacert:    caCert,
+			shouldErr: true,
+		},
+		{
+			name:      "wrong CSR file error",
+			csr:       caCert,
+			cacert:    caCert,
+			cakey:     caKey,
+			shouldErr: true,
+		},
+		{
+			name:      "wrong CA key error",
+			csr:       csrFile,
+			cacert:    caCert,
+			cakey:     csrFile,
+			shouldErr: true,
+		},
+		{
+			name:        "unknown ext key usage error",
+			csr:         csrFile,
+			cacert:      caCert,
+			cakey:       caKey,
+			extKeyUsage: "wrongUsage",
+			shouldErr:   true,
+		},
+		{
+			name:   "valid intermediate CA signing",
+			csr:    csrFile,
+			cacert: caCert,
+			cakey:  caKey,
+			isCA:   true,
+		},
+		{
+			name:      "leaf signing with CA key error",
+			csr:       csrFile,
+			cacert:    csrFile,
+			cakey:     caKey,
+			shouldErr: true,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.name, func(t *testing.T) {
+			testArgs := []string{execName, req.CmdCert,
+				"--csr", tC.csr,
+				"--out", outFile,
+				"--serial", "2",
+			}
+			if tC.cacert != "" {
+				testArgs = append(testArgs, "--cacert", tC.cacert)
+			}
+			if tC.cakey != "" {
+				testArgs = append(testArgs, "--cakey", tC.cakey)
+			}
+			if tC.extKeyUsage != "" {
+				testArgs = append(testArgs, "--extKeyUsage", tC.extKeyUsage)
+			}
+			if tC.password != "" {
+				testArgs = append(testArgs, "--password", tC.password)
+			}
+			if tC.isCA {
+				testArgs = append(testArgs, "--isCA")
+			}
+
+			if tC.shouldErr {
+				require.Error(t, app.Run(testArgs))
+				return
+			}
+			require.NoError(t, app.Run(testArgs))
+
+			// Signed certificate must chain up to CA
+			cert, err := utils.CertFromFile(outFile)
+			require.NoError(t, err)
+
+			ca, err := utils.CertFromFile(caCert)
+			require.NoError(t, err)
+
+			roots := x509.NewCertPool()
+			roots.AddCert(ca)
+
+			_, err = cert.Verify(x509.VerifyOptions{Roots: roots, DNSName: "127.0.0.1"})
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestSignIntermediate(t *testing.T) {
+	const (
+		rootCert = "../../testdata/ca-cert.pem"
+		rootKey  = "../../testdata/ca-key.pem"
+	)
+
+	execName, err := os.Executable()
+	require.NoError(t, err)
+
+	tempDir := t.TempDir()
+	app := &cli.App{
+		Commands: []*cli.Command{
+			key.Command(),
+			req.Command(&bytes.Buffer{}),
+		},
+	}
+
+	// newCSR generates a key and a CSR with SAN and returns their paths
+	newCSR := func(name, san string) (string, string) {
+		keyFile := filepath.Join(tempDir, name+".key")
+		csrFile := filepath.Join(tempDir, name+".csr")
+		require.NoError(t, app.Run([]string{execName, key.CmdKey, "--out", keyFile, "--type", "ecdsa"}))
+		require.NoError(t, app.Run([]string{execName, req.CmdCert,
+			"--key", keyFile, "--out", csrFile, "--subj", "/O=GoSSL", "--san", san}))
+		return keyFile, csrFile
+	}
+
+	// Intermediate CA signed by root CA with constraints
+	intermediateKey, intermediateCSR := newCSR("intermediate", "dns:intermediate.example.com")
+	intermediateFile := filepath.Join(tempDir, "intermediate.pem")
+	require.NoError(t, app.Run([]string{execName, req.CmdCert,
+		"--csr", intermediateCSR,
+		"--cacert", rootCert,
+		"--cakey", rootKey,
+		"--out", intermediateFile,
+		"--isCA",
+		"--pathLen", "0",
+		"--permitted", "dns:example.com",
+		"--permitted", "ip:10.0.0.0/8",
+		"--excluded", "dns:secret.example.com",
+	}))
+
+	intermediate, err := utils.CertFromFile(intermediateFile)
+	require.NoError(t, err)
+	require.True(t, intermediate.IsCA)
+	require.True(t, intermediate.MaxPathLenZero)
+	require.Equal(t, x509.KeyUsageCertSign|x509.KeyUsageCRLSign|x509.KeyUsageDigitalSignature, intermediate.KeyUsage)
+	require.Equal(t, []string{"example.com"}, intermediate.PermittedDNSDomains)
+	require.Equal(t, []string{"secret.example.com"}, intermediate.ExcludedDNSDomains)
+	require.Equal(t, "10.0.0.0/8", intermediate.PermittedIPRanges[0].String())
+	require.True(t, intermediate.PermittedDNSDomainsCritical)
+
+	root, err := utils.CertFromFile(rootCert)
+	require.NoError(t, err)
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	intermediates := x509.NewCertPool()
+	intermediates.AddCert(intermediate)
+
+	testCases := []struct {
+		name      string
+		san       string
+		verifyErr bool
+	}{
+		{
+			name: "permitted DNS name",
+			san:  "dns:api.example.com",
+		},
+		{
+			name: "permitted IP address",
+			san:  "ip:10.1.2.3",
+		},
+		{
+			name:      "not permitted DNS name",
+			san:       "dns:api.example.org",
+			verifyErr: true,
+		},
+		{
+			name:      "excluded DNS name",
+			san:       "dns:db.secret.example.com",
+			verifyErr: true,
+		},
+		{
+			name:      "not permitted IP address",
+			san:       "ip:192.168.1.1",
+			verifyErr: true,
+		},
+	}
+
+	for i, tC := range testCases {
+		t.Run(tC.name, func(t *testing.T) {
+			_, csrFile := newCSR("leaf"+strconv.Itoa(i), tC.san)
+			outFile := filepath.Join(tempDir, "leaf.pem")
+			testArgs := []string{execName, req.CmdCert,
+				"--csr", csrFile,
+				"--cacert", intermediateFile,
+				"--cakey", intermediateKey,
+				"--out", outFile,
+			}
+			require.NoError(t, app.Run(testArgs))
+
+			cert, err := utils.CertFromFile(outFile)
+			require.NoError(t, err)
+			_, err = cert.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+			if tC.verifyErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+
+	t.Run("path length exceeded", func(t *testing.T) {
+		// CA below intermediate is not allowed with pathLen 0
+		subKey, subCSR := newCSR("sub", "dns:sub.example.com")
+		subFile := filepath.Join(tempDir, "sub.pem")
+		require.NoError(t, app.Run([]string{execName, req.CmdCert,
+			"--csr", subCSR, "--cacert", intermediateFile, "--cakey", intermediateKey,
+			"--out", subFile, "--isCA"}))
+
+		sub, err := utils.CertFromFile(subFile)
+		require.NoError(t, err)
+		intermediates.AddCert(sub)
+
+		_, csrFile := newCSR("sub-leaf", "dns:www.example.com")
+		outFile := filepath.Join(tempDir, "sub-leaf.pem")
+		require.NoError(t, app.Run([]string{execName, req.CmdCert,
+			"--csr", csrFile, "--cacert", subFile, "--cakey", subKey, "--out", outFile}))
+
+		cert, err := utils.CertFromFile(outFile)
+		require.NoError(t, err)
+		_, err = cert.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+		require.Error(t, err)
+	})
+
+	t.Run("constraints without isCA error", func(t *testing.T) {
+		_, csrFile := newCSR("no-ca", "dns:api.example.com")
+		require.Error(t, app.Run([]string{execName, req.CmdCert,
+			"--csr", csrFile, "--cacert", rootCert, "--cakey", rootKey,
+			"--out", filepath.Join(tempDir, "no-ca.pem"), "--pathLen", "1"}))
+	})
+
+	t.Run("invalid name constraint error", func(t *testing.T) {
+		require.Error(t, app.Run([]string{execName, req.CmdCert,
+			"--csr", intermediateCSR, "--cacert", rootCert, "--cakey", rootKey,
+			"--out", filepath.Join(tempDir, "invalid.pem"), "--isCA", "--permitted", "ip:not-an-ip"}))
+	})
+}
+
+func TestSignSerial(t *testing.T) {
+	app := &cli.App{
+		Commands: []*cli.Command{
+			req.Command(os.Stdin),
+		},
+	}
+
+	execName, err := os.Executable()
+	require.NoError(t, err)
+
+	// sign signs the test CSR with the test CA and returns the certificate
+	sign := func(t *testing.T, args ...string) *x509.Certificate {
+		outFile := filepath.Join(t.TempDir(), "signed.pem")
+		require.NoError(t, app.Run(append([]string{execName, req.CmdCert,
+			"--csr", "../../testdata/server-req.pem",
+			"--cacert", "../../testdata/ca-cert.pem",
+			"--cakey", "../../testdata/ca-key.pem",
+			"--out", outFile,
+		}, args...)))
+
+		cert, err := utils.CertFromFile(outFile)
+		require.NoError(t, err)
+		return cert
+	}
+
+	first, second := sign(t), sign(t)
+	require.Equal(t, 1, first.SerialNumber.Sign())
+	require.Equal(t, 1, second.SerialNumber.Sign())
+	require.NotEqual(t, first.SerialNumber, second.SerialNumber)
+
+	require.Equal(t, int64(2), sign(t, "--serial", "2").SerialNumber.Int64())
+}
@@ -0,0 +1,295 @@
+package req
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/yakuter/gossl/pkg/utils"
+
+	"github.com/urfave/cli/v2"
+)
+
+var oidEmailAddress = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 1}
+
+// altNames holds Subject Alternative Names of a certificate
+type altNames struct {
+	DNSNames       []string
+	IPAddresses    []net.IP
+	EmailAddresses []string
+	URIs           []*url.URL
+}
+
+// Questions asked for subject fields which are not provided with flags
+const (
+	questionCN           = "Common Name - SAN (eg, FQDN or IP)* []"
+	questionEmail        = "E-mail address []"
+	questionCountry      = "Country Name (2 letter code) [AU]"
+	questionProvince     = "State or Province Name []"
+	questionLocality     = "Locality Name (eg, city) []"
+	questionOrganization = "Organization Name [eg, company]"
+	questionOrgUnit      = "Organizational Unit Name (eg, section) []"
+	questionStreet       = "Street Addr []"
+	questionPostalCode   = "Postal Code []"
+)
+
+// subject generates subject and SANs from subj and san flags or from profile.
+// Subject fields which are not provided with flags or profile are asked to user.
+func subject(c *cli.Context, reader io.Reader, p *Profile) (pkix.Name, altNames, error) {
+	// Flags take precedence over profile
+	sanValues := c.StringSlice(flagSAN)
+	if !c.IsSet(flagSAN) && p != nil {
+		sanValues = p.SANs
+	}
+
+	sans, err := parseSANs(sanValues)
+	if err != nil {
+		log.Printf("Failed to parse SANs error: %v", err)
+		return pkix.Name{}, altNames{}, err
+	}
+
+	subj, email := p.name()
+	hasSubj := p != nil && p.Subject != nil
+	if c.IsSet(flagSubj) {
+		hasSubj = true
+		subj, email, err = parseSubj(c.String(flagSubj))
+		if err != nil {
+			log.Printf("Failed to parse subject %q error: %v", c.String(flagSubj), err)
+			return pkix.Name{}, altNames{}, err
+		}
+	}
+
+	// Prepare questions which are needed for subject. When subject is
+	// provided with flag or profile, only missing Common Name - SAN is asked.
+	var questions []string
+	askCN := subj.CommonName == "" && sans.empty()
+	if askCN {
+		questions = append(questions, questionCN)
+	}
+	if !hasSubj {
+		questions = append(questions,
+			questionEmail,
+			questionCountry,
+			questionProvince,
+			questionLocality,
+			questionOrganization,
+			questionOrgUnit,
+			questionStreet,
+			questionPostalCode,
+		)
+	}
+
+	// Ask questions to user and get inputs as answers
+	answers := map[string]string{}
+	if len(questions) > 0 {
+		inputs, err := utils.ReadInputs(questions, reader)
+		if err != nil {
+			log.Printf("failed to read inputs %v", err)
+			return pkix.Name{}, altNames{}, err
+		}
+		for i := range questions {
+			answers[questions[i]] = inputs[i]
+		}
+	}
+
+	if askCN {
+		if len(answers[questionCN]) == 0 {
+			err = errors.New("Common Name - SAN cannot be empty")
+			log.Printf("%v", err)
+			return pkix.Name{}, altNames{}, err
+		}
+
+		// Common Name answer may contain comma separated SANs
+		cnSANs := strings.Split(answers[questionCN], ",")
+		subj.CommonName = cnSANs[0]
+		sans.add(cnSANs...)
+	}
+
+	if !hasSubj {
+		email = answers[questionEmail]
+		subj.Country = splitAnswer(answers[questionCountry])
+		subj.Province = splitAnswer(answers[questionProvince])
+		subj.Locality = splitAnswer(answers[questionLocality])
+		subj.Organization = splitAnswer(answers[questionOrganization])
+		subj.OrganizationalUnit = splitAnswer(answers[questionOrgUnit])
+		subj.StreetAddress = splitAnswer(answers[questionStreet])
+		subj.PostalCode = splitAnswer(answers[questionPostalCode])
+	}
+
+	// Use first SAN as Common Name or Common Name as SAN if one is missing
+	if subj.CommonName == "" {
+		subj.CommonName = sans.first()
+	} else if sans.empty() {
+		sans.add(subj.CommonName)
+	}
+
+	// E-mail address is optional
+	if email != "" {
+		subj.ExtraNames = append(subj.ExtraNames, pkix.AttributeTypeAndValue{
+			Type: oidEmailAddress,
+			Value: asn1.RawValue{
+				Tag:   asn1.TagIA5String,
+				Bytes: []byte(email),
+			},
+		})
+	}
+
+	return subj, sans, nil
+}
+
+// parseSubj parses OpenSSL style subject like "/C=TR/O=Acme/CN=api.example.com"
+// and returns subject with e-mail address separately
+func parseSubj(s string) (pkix.Name, string, error) {
+	var (
+		subj  pkix.Name
+		email string
+	)
+
+	if !strings.HasPrefix(s, "/") {
+		return subj, "", errors.New("subject must start with /")
+	}
+
+	for _, field := range splitSubj(s[1:]) {
+		if field == "" {
+			continue
+		}
+
+		key, value, found := strings.Cut(field, "=")
+		if !found {
+			return subj, "", fmt.Errorf("missing = in subject field %q", field)
+		}
+
+		switch strings.ToLower(key) {
+		case "cn":
+			subj.CommonName = value
+		case "c":
+			subj.Country = append(subj.Country, value)
+		case "st":
+			subj.Province = append(subj.Province, value)
+		case "l":
+			subj.Locality = append(subj.Locality, value)
+		case "o":
+			subj.Organization = append(subj.Organization, value)
+		case "ou":
+			subj.OrganizationalUnit = append(subj.OrganizationalUnit, value)
+		case "street":
+			subj.StreetAddress = append(subj.StreetAddress, value)
+		case "postalcode":
+			subj.PostalCode = append(subj.PostalCode, value)
+		case "serialnumber":
+			subj.SerialNumber = value
+		case "emailaddress":
+			email = value
+		default:
+			return subj, "", fmt.Errorf("unknown subject field %q", key)
+		}
+	}
+
+	return subj, email, nil
+}
+
+// splitSubj splits subject fields by "/" except escaped "\/"
+func splitSubj(s string) []string {
+	var (
+		fields  []string
+		current strings.Builder
+	)
+
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s):
+			i++
+			current.WriteByte(s[i])
+		case s[i] == '/':
+			fields = append(fields, current.String())
+			current.Reset()
+		default:
+			current.WriteByte(s[i])
+		}
+	}
+
+	return append(fields, current.String())
+}
+
+// parseSANs parses SANs in "dns:", "ip:", "email:" or "uri:" prefixed format.
+// SANs without prefix are treated as IP if possible, otherwise as DNS.
+func parseSANs(values []string) (altNames, error) {
+	var sans altNames
+	for _, value := range values {
+		prefix, name, found := strings.Cut(value, ":")
+		if !found {
+			sans.add(value)
+			continue
+		}
+
+		switch strings.ToLower(prefix) {
+		case "dns":
+			sans.DNSNames = append(sans.DNSNames, name)
+		case "ip":
+			addr := net.ParseIP(name)
+			if addr == nil {
+				return altNames{}, fmt.Errorf("invalid IP address %q", name)
+			}
+			sans.IPAddresses = append(sans.IPAddresses, addr)
+		case "email":
+			sans.EmailAddresses = append(sans.EmailAddresses, name)
+		case "uri":
+			uri, err := url.Parse(name)
+			if err != nil {
+				return altNames{}, err
+			}
+			sans.URIs = append(sans.URIs, uri)
+		default:
+			// IPv6 addresses contain colons too
+			sans.add(value)
+		}
+	}
+
+	return sans, nil
+}
+
+// add appends names to SANs as IP if possible, otherwise as DNS
+func (a *altNames) add(names ...string) {
+	for i := range names {
+		addr := net.ParseIP(names[i])
+		if addr != nil {
+			a.IPAddresses = append(a.IPAddresses, addr)
+		} else {
+			a.DNSNames = append(a.DNSNames, names[i])
+		}
+	}
+}
+
+func (a altNames) empty() bool {
+	return len(a.DNSNames) == 0 && len(a.IPAddresses) == 0 &&
+		len(a.EmailAddresses) == 0 && len(a.URIs) == 0
+}
+
+// first returns first DNS name or IP address to be used as Common Name
+func (a altNames) first() string {
+	switch {
+	case len(a.DNSNames) > 0:
+		return a.DNSNames[0]
+	case len(a.IPAddresses) > 0:
+		return a.IPAddresses[0].String()
+	case len(a.EmailAddresses) > 0:
+		return a.EmailAddresses[0]
+	default:
+		return ""
+	}
+}
+
+// splitAnswer splits comma separated answer, empty answer means no value
+func splitAnswer(answer string) []string {
+	if answer == "" {
+		return nil
+	}
+
+	return strings.Split(answer, ",")
+}
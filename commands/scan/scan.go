@@ -0,0 +1,379 @@
+package scan
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yakuter/gossl/pkg/probe"
+	"github.com/yakuter/gossl/pkg/utils"
+
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	CmdScan = "scan"
+
+	flagTargets = "targets"
+	flagCAFile  = "cafile"
+	flagWorkers = "workers"
+	flagRate    = "rate"
+	flagFormat  = "format"
+	flagOut     = "out"
+
+	formatCSV  = "csv"
+	formatJSON = "json"
+
+	defaultWorkers = 10
+)
+
+func Command(reader io.Reader) *cli.Command {
+	return &cli.Command{
+		Name:      CmdScan,
+		HelpName:  CmdScan,
+		Action:    Action(reader),
+		ArgsUsage: `[host:port...]`,
+		Usage:     `scans TLS servers concurrently.`,
+		Description: `Scans TLS servers concurrently and reports certificate, TLS version, cipher
+suite, expiry and verification result of every server in CSV or JSON format.
+Targets are read from arguments, from targets file or from stdin.`,
+		Flags: Flags(),
+	}
+}
+
+func Flags() []cli.Flag {
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Name:        flagTargets,
+			Usage:       "File with a target per line, - for stdin, empty lines and # comments are skipped (optional)",
+			DefaultText: "eg, targets.txt",
+			Required:    false,
+		},
+		&cli.StringFlag{
+			Name:        flagCAFile,
+			Usage:       "CA file to verify servers, system roots are used by default (optional)",
+			DefaultText: "eg, ca-cert.pem",
+			Required:    false,
+		},
+		&cli.IntFlag{
+			Name:     flagWorkers,
+			Usage:    "Number of servers scanned concurrently",
+			Value:    defaultWorkers,
+			Required: false,
+		},
+		&cli.Float64Flag{
+			Name:     flagRate,
+			Usage:    "Maximum connections per second for all workers, 0 is unlimited (optional)",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:        flagFormat,
+			Usage:       "Report format (csv or json)",
+			DefaultText: formatCSV,
+			Value:       formatCSV,
+			Required:    false,
+		},
+		&cli.StringFlag{
+			Name:        flagOut,
+			Usage:       "Report file name (optional)",
+			DefaultText: "eg, ./report.csv",
+			Required:    false,
+		},
+	}
+
+	return append(flags, probe.Flags()...)
+}
+
+// result is the scan result of a target
+type result struct {
+	Target        string     `json:"target"`
+	Address       string     `json:"address"`
+	Error         string     `json:"error,omitempty"`
+	TLSVersion    string     `json:"tlsVersion,omitempty"`
+	CipherSuite   string     `json:"cipherSuite,omitempty"`
+	Subject       string     `json:"subject,omitempty"`
+	Issuer        string     `json:"issuer,omitempty"`
+	DNSNames      []string   `json:"dnsNames,omitempty"`
+	NotAfter      *time.Time `json:"notAfter,omitempty"`
+	DaysRemaining *int       `json:"daysRemaining,omitempty"`
+	Chain         []string   `json:"chain,omitempty"`
+	Verified      bool       `json:"verified"`
+	VerifyError   string     `json:"verifyError,omitempty"`
+	DurationMS    int64      `json:"durationMs"`
+}
+
+var csvHeader = []string{
+	"target", "address", "error", "tlsVersion", "cipherSuite", "subject", "issuer", "dnsNames",
+	"notAfter", "daysRemaining", "chain", "verified", "verifyError", "durationMs",
+}
+
+func Action(reader io.Reader) func(*cli.Context) error {
+	return func(c *cli.Context) error {
+		format := c.String(flagFormat)
+		if format != formatCSV && format != formatJSON {
+			err := fmt.Errorf("unsupported format %q", format)
+			log.Printf("%v", err)
+			return err
+		}
+
+		if c.Int(flagWorkers) < 1 {
+			err := errors.New("workers must be at least 1")
+			log.Printf("%v", err)
+			return err
+		}
+
+		if c.Float64(flagRate) < 0 {
+			err := errors.New("rate must not be negative")
+			log.Printf("%v", err)
+			return err
+		}
+
+		targets, err := readTargets(c, reader)
+		if err != nil {
+			log.Printf("Failed to read targets error: %v", err)
+			return err
+		}
+
+		// Connect address and server name would be the same for every target
+		if len(targets) > 1 && (c.IsSet(probe.FlagConnect) || c.IsSet(probe.FlagServerName)) {
+			err := fmt.Errorf("%s and %s flags are only allowed with a single target", probe.FlagConnect, probe.FlagServerName)
+			log.Printf("%v", err)
+			return err
+		}
+
+		opts, err := probe.OptionsFromContext(c, utils.StdinPasswordReader{Prompt: "Enter pass phrase: "})
+		if err != nil {
+			log.Printf("Failed to get connection options error: %v", err)
+			return err
+		}
+
+		var roots *x509.CertPool
+		if c.IsSet(flagCAFile) {
+			certs, err := utils.CertsFromFile(c.String(flagCAFile))
+			if err != nil {
+				log.Printf("Failed to read CA file %q error: %v", c.String(flagCAFile), err)
+				return err
+			}
+
+			roots = x509.NewCertPool()
+			for _, cert := range certs {
+				roots.AddCert(cert)
+			}
+		}
+
+		results := scanTargets(targets, opts, roots, c.Int(flagWorkers), c.Float64(flagRate))
+
+		w := c.App.Writer
+		if c.IsSet(flagOut) {
+			f, err := os.OpenFile(c.String(flagOut), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+			if err != nil {
+				log.Printf("Failed to create report file %s error: %v", c.String(flagOut), err)
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+
+		if format == formatJSON {
+			err = writeJSON(w, results)
+		} else {
+			err = writeCSV(w, results)
+		}
+		if err != nil {
+			log.Printf("Failed to write report error: %v", err)
+			return err
+		}
+
+		return nil
+	}
+}
+
+// readTargets returns targets in arguments and targets file. Targets are read
+// from reader if targets file is - or if there are no arguments.
+func readTargets(c *cli.Context, reader io.Reader) ([]string, error) {
+	targets := c.Args().Slice()
+
+	path := c.String(flagTargets)
+	switch {
+	case path != "" && path != "-":
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		reader = f
+	case path == "" && len(targets) > 0:
+		reader = nil
+	}
+
+	if reader != nil {
+		lines, err := parseTargets(reader)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, lines...)
+	}
+
+	if len(targets) == 0 {
+		return nil, errors.New("no target provided")
+	}
+
+	return targets, nil
+}
+
+// parseTargets returns a target for each line, empty lines and comments are skipped
+func parseTargets(r io.Reader) ([]string, error) {
+	var targets []string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if line != "" {
+			targets = append(targets, line)
+		}
+	}
+
+	return targets, scanner.Err()
+}
+
+// scanTargets scans targets with a pool of workers and returns results in
+// order of targets. Connections are started at most rate per second if rate is positive.
+func scanTargets(targets []string, opts *probe.Options, roots *x509.CertPool, workers int, rate float64) []result {
+	var limit <-chan time.Time
+	if rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+		defer ticker.Stop()
+		limit = ticker.C
+	}
+
+	results := make([]result, len(targets))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers && i < len(targets); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				if limit != nil {
+					<-limit
+				}
+				results[j] = scanTarget(targets[j], opts, roots)
+			}
+		}()
+	}
+
+	for i := range targets {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// scanTarget connects to target and verifies certificates sent by server
+func scanTarget(target string, opts *probe.Options, roots *x509.CertPool) result {
+	r := result{Target: target}
+	start := time.Now()
+
+	addr, err := opts.HostPort(target)
+	if err != nil {
+		r.Error = err.Error()
+		return finish(r, start)
+	}
+	r.Address = opts.Address(addr)
+
+	// Verification is done after handshake to scan servers with invalid certificates
+	config := opts.TLSConfig(&tls.Config{InsecureSkipVerify: true}, addr)
+	conn, err := opts.Dial(addr, config)
+	if err != nil {
+		r.Error = err.Error()
+		return finish(r, start)
+	}
+	defer conn.Close()
+
+	state := conn.ConnectionState()
+	r.TLSVersion = probe.VersionName(state.Version)
+	r.CipherSuite = tls.CipherSuiteName(state.CipherSuite)
+
+	if len(state.PeerCertificates) > 0 {
+		leaf := state.PeerCertificates[0]
+		r.Subject = leaf.Subject.String()
+		r.Issuer = leaf.Issuer.String()
+		r.DNSNames = leaf.DNSNames
+		notAfter := leaf.NotAfter.UTC()
+		days := int(math.Floor(time.Until(leaf.NotAfter).Hours() / 24))
+		r.NotAfter, r.DaysRemaining = &notAfter, &days
+		for _, cert := range state.PeerCertificates {
+			r.Chain = append(r.Chain, cert.Subject.String())
+		}
+	}
+
+	if _, err = probe.VerifyPeer(state.PeerCertificates, roots, config.ServerName); err != nil {
+		r.VerifyError = err.Error()
+	} else {
+		r.Verified = true
+	}
+
+	return finish(r, start)
+}
+
+// finish sets duration of scan started at start
+func finish(r result, start time.Time) result {
+	r.DurationMS = time.Since(start).Milliseconds()
+	return r
+}
+
+func writeJSON(w io.Writer, results []result) error {
+	out, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "%s\n", out)
+	return err
+}
+
+func writeCSV(w io.Writer, results []result) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		notAfter, days := "", ""
+		if r.NotAfter != nil {
+			notAfter = r.NotAfter.Format(time.RFC3339)
+			days = strconv.Itoa(*r.DaysRemaining)
+		}
+
+		err := cw.Write([]string{
+			r.Target, r.Address, r.Error, r.TLSVersion, r.CipherSuite, r.Subject, r.Issuer,
+			strings.Join(r.DNSNames, " "), notAfter, days,
+			strings.Join(r.Chain, " | "), strconv.FormatBool(r.Verified), r.VerifyError,
+			strconv.FormatInt(r.DurationMS, 10),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,245 @@
+package scan_test
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/csv"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yakuter/gossl/commands/generate"
+	"github.com/yakuter/gossl/commands/scan"
+
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+// newServer starts a TLS server which waits delay before handshake
+func newServer(t *testing.T, cert tls.Certificate, delay time.Duration) string {
+	t.Helper()
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				time.Sleep(delay)
+				_ = conn.(*tls.Conn).Handshake()
+			}()
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+type scanResult struct {
+	Target        string     `json:"target"`
+	Address       string     `json:"address"`
+	Error         string     `json:"error"`
+	TLSVersion    string     `json:"tlsVersion"`
+	CipherSuite   string     `json:"cipherSuite"`
+	Subject       string     `json:"subject"`
+	Issuer        string     `json:"issuer"`
+	DNSNames      []string   `json:"dnsNames"`
+	NotAfter      *time.Time `json:"notAfter"`
+	DaysRemaining *int       `json:"daysRemaining"`
+	Chain         []string   `json:"chain"`
+	Verified      bool       `json:"verified"`
+	VerifyError   string     `json:"verifyError"`
+}
+
+func TestScan(t *testing.T) {
+	execName, err := os.Executable()
+	require.NoError(t, err)
+
+	pkiDir := t.TempDir()
+	var out bytes.Buffer
+	genApp := &cli.App{
+		Writer:   &out,
+		Commands: []*cli.Command{generate.Command()},
+	}
+	require.NoError(t, genApp.Run([]string{execName, generate.CmdGenerate,
+		"--out", pkiDir, "--type", "ecdsa", "--intermediate", "--leaf", "localhost+127.0.0.1"}))
+
+	caFile := filepath.Join(pkiDir, "ca-cert.pem")
+	chainCert, err := tls.LoadX509KeyPair(filepath.Join(pkiDir, "localhost-chain.pem"), filepath.Join(pkiDir, "localhost-key.pem"))
+	require.NoError(t, err)
+	otherCert, err := tls.LoadX509KeyPair("../../testdata/server-cert.pem", "../../testdata/server-key.pem")
+	require.NoError(t, err)
+
+	var (
+		validAddr     = newServer(t, chainCert, 0)
+		untrustedAddr = newServer(t, otherCert, 0)
+		closedAddr    = "127.0.0.1:1"
+	)
+
+	targetsFile := filepath.Join(t.TempDir(), "targets.txt")
+	require.NoError(t, os.WriteFile(targetsFile, []byte("# internal endpoints\n"+
+		validAddr+"\n\n"+untrustedAddr+" # legacy\n"+closedAddr+"\n"), 0o600))
+
+	newApp := func(stdin string) *cli.App {
+		return &cli.App{
+			Writer:   &out,
+			Commands: []*cli.Command{scan.Command(strings.NewReader(stdin))},
+		}
+	}
+
+	t.Run("json report from targets file", func(t *testing.T) {
+		out.Reset()
+		require.NoError(t, newApp("").Run([]string{execName, scan.CmdScan,
+			"--targets", targetsFile, "--cafile", caFile, "--format", "json", "--timeout", "2s"}))
+
+		var results []scanResult
+		require.NoError(t, json.Unmarshal(out.Bytes(), &results))
+		require.Len(t, results, 3)
+
+		// Results are in order of targets
+		valid := results[0]
+		require.Equal(t, validAddr, valid.Target)
+		require.Equal(t, validAddr, valid.Address)
+		require.Empty(t, valid.Error)
+		require.Equal(t, "TLS 1.3", valid.TLSVersion)
+		require.NotEmpty(t, valid.CipherSuite)
+		require.Equal(t, "CN=localhost", valid.Subject)
+		require.Equal(t, "CN=GoSSL Root CA Intermediate", valid.Issuer)
+		require.Equal(t, []string{"localhost"}, valid.DNSNames)
+		require.NotNil(t, valid.NotAfter)
+		require.Greater(t, *valid.DaysRemaining, 0)
+		require.Equal(t, []string{"CN=localhost", "CN=GoSSL Root CA Intermediate"}, valid.Chain)
+		require.True(t, valid.Verified)
+		require.Empty(t, valid.VerifyError)
+
+		untrusted := results[1]
+		require.Equal(t, untrustedAddr, untrusted.Target)
+		require.Empty(t, untrusted.Error)
+		require.False(t, untrusted.Verified)
+		require.True(t, strings.HasPrefix(untrusted.VerifyError, "unknown authority: "), untrusted.VerifyError)
+
+		closed := results[2]
+		require.Equal(t, closedAddr, closed.Target)
+		require.NotEmpty(t, closed.Error)
+		require.Nil(t, closed.NotAfter)
+		require.Nil(t, closed.DaysRemaining)
+		require.False(t, closed.Verified)
+	})
+
+	t.Run("csv report from stdin", func(t *testing.T) {
+		outFile := filepath.Join(t.TempDir(), "report.csv")
+		require.NoError(t, newApp(validAddr+"\n"+closedAddr+"\n").Run([]string{execName, scan.CmdScan,
+			"--cafile", caFile, "--out", outFile}))
+
+		f, err := os.Open(outFile)
+		require.NoError(t, err)
+		defer f.Close()
+
+		records, err := csv.NewReader(f).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 3)
+		require.Equal(t, []string{"target", "address", "error", "tlsVersion", "cipherSuite", "subject", "issuer",
+			"dnsNames", "notAfter", "daysRemaining", "chain", "verified", "verifyError", "durationMs"}, records[0])
+
+		require.Equal(t, validAddr, records[1][0])
+		require.Equal(t, "CN=localhost", records[1][5])
+		require.Equal(t, "CN=localhost | CN=GoSSL Root CA Intermediate", records[1][10])
+		require.Equal(t, "true", records[1][11])
+
+		require.Equal(t, closedAddr, records[2][0])
+		require.NotEmpty(t, records[2][2])
+		require.Empty(t, records[2][8])
+		require.Equal(t, "false", records[2][11])
+	})
+
+	t.Run("arguments and stdin targets file", func(t *testing.T) {
+		out.Reset()
+		require.NoError(t, newApp(untrustedAddr+"\n").Run([]string{execName, scan.CmdScan,
+			"--targets", "-", "--format", "json", validAddr}))
+
+		var results []scanResult
+		require.NoError(t, json.Unmarshal(out.Bytes(), &results))
+		require.Len(t, results, 2)
+		require.Equal(t, validAddr, results[0].Target)
+		require.Equal(t, untrustedAddr, results[1].Target)
+	})
+
+	t.Run("workers scan concurrently", func(t *testing.T) {
+		slowAddr := newServer(t, chainCert, 300*time.Millisecond)
+		args := []string{execName, scan.CmdScan, "--workers", "5", "--format", "json"}
+		for i := 0; i < 5; i++ {
+			args = append(args, slowAddr)
+		}
+
+		out.Reset()
+		start := time.Now()
+		require.NoError(t, newApp("").Run(args))
+		require.Less(t, time.Since(start), 1200*time.Millisecond)
+	})
+
+	t.Run("rate limits connections", func(t *testing.T) {
+		args := []string{execName, scan.CmdScan, "--workers", "5", "--rate", "10", "--format", "json"}
+		for i := 0; i < 5; i++ {
+			args = append(args, validAddr)
+		}
+
+		out.Reset()
+		start := time.Now()
+		require.NoError(t, newApp("").Run(args))
+		require.GreaterOrEqual(t, time.Since(start), 450*time.Millisecond)
+	})
+
+	t.Run("per host timeout", func(t *testing.T) {
+		// Listener never completes TLS handshake
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer ln.Close()
+
+		out.Reset()
+		start := time.Now()
+		require.NoError(t, newApp("").Run([]string{execName, scan.CmdScan, "--timeout", "200ms", "--format", "json", ln.Addr().String()}))
+		require.Less(t, time.Since(start), 2*time.Second)
+
+		var results []scanResult
+		require.NoError(t, json.Unmarshal(out.Bytes(), &results))
+		require.NotEmpty(t, results[0].Error)
+	})
+
+	t.Run("connect address with server name", func(t *testing.T) {
+		out.Reset()
+		require.NoError(t, newApp("").Run([]string{execName, scan.CmdScan, "--cafile", caFile, "--format", "json",
+			"--connect", validAddr, "--servername", "localhost", "api.example.com:443"}))
+
+		var results []scanResult
+		require.NoError(t, json.Unmarshal(out.Bytes(), &results))
+		require.Len(t, results, 1)
+		require.Equal(t, "api.example.com:443", results[0].Target)
+		require.Equal(t, validAddr, results[0].Address)
+		require.True(t, results[0].Verified)
+	})
+
+	errorCases := map[string][]string{
+		"no target error":              {},
+		"missing targets file":         {"--targets", "wrong-file"},
+		"unsupported format error":     {"--format", "xml", validAddr},
+		"workers error":                {"--workers", "0", validAddr},
+		"rate error":                   {"--rate", "-1", validAddr},
+		"cafile error":                 {"--cafile", "wrong-file", validAddr},
+		"connect with many targets":    {"--connect", validAddr, validAddr, untrustedAddr},
+		"servername with many targets": {"--servername", "localhost", validAddr, untrustedAddr},
+	}
+	for name, args := range errorCases {
+		t.Run(name, func(t *testing.T) {
+			require.Error(t, newApp("").Run(append([]string{execName, scan.CmdScan}, args...)))
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package ssh
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yakuter/gossl/pkg/utils"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/crypto/ssh"
+)
+
+// keyEntry is a public key read from a key, authorized_keys or known_hosts file
+type keyEntry struct {
+	key     ssh.PublicKey
+	comment string
+}
+
+func FingerprintAction(c *cli.Context) error {
+	if c.Args().Len() == 0 {
+		return errors.New("Please provide a key, authorized_keys or known_hosts file")
+	}
+
+	for _, path := range c.Args().Slice() {
+		entries, err := readKeys(path)
+		if err != nil {
+			log.Printf("Failed to read keys from %s error: %v", path, err)
+			return err
+		}
+
+		for _, entry := range entries {
+			if _, err = io.WriteString(c.App.Writer, fingerprintText(entry)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// readKeys reads public key of a private key file or public keys in
+// each line of a public key, authorized_keys or known_hosts file
+func readKeys(path string) ([]keyEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.Contains(data, []byte("PRIVATE KEY-----")) {
+		return readPrivateKeyFile(path, data)
+	}
+
+	var entries []keyEntry
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// Skip @cert-authority and @revoked markers of known_hosts
+		if strings.HasPrefix(line, "@") {
+			_, line, _ = strings.Cut(line, " ")
+		}
+
+		// Leading field is either authorized_keys options or known_hosts host names
+		key, comment, options, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+		if comment == "" {
+			comment = strings.Join(options, ",")
+		}
+		entries = append(entries, keyEntry{key: key, comment: comment})
+	}
+
+	if len(entries) == 0 {
+		return nil, errors.New("no key found")
+	}
+
+	return entries, nil
+}
+
+// readPrivateKeyFile returns public key of private key. Public key of encrypted
+// OpenSSH keys is read without passphrase, comment is read from the .pub file.
+func readPrivateKeyFile(path string, data []byte) ([]keyEntry, error) {
+	var pub ssh.PublicKey
+
+	privateKey, err := utils.SSHPrivateKeyFromPEM(data, nil)
+	var missing *ssh.PassphraseMissingError
+	switch {
+	case errors.As(err, &missing) && missing.PublicKey != nil:
+		pub = missing.PublicKey
+	case err != nil:
+		return nil, err
+	default:
+		if pub, err = ssh.NewPublicKey(privateKey.Public()); err != nil {
+			return nil, err
+		}
+	}
+
+	comment, err := pubKeyComment(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return []keyEntry{{key: pub, comment: comment}}, nil
+}
+
+// fingerprintText returns SHA256 and MD5 fingerprints and randomart of key like
+// ssh-keygen -l -v, followed by details of certificates like ssh-keygen -L
+func fingerprintText(entry keyEntry) string {
+	keyType, bits := keyTypeAndBits(entry.key)
+
+	comment := entry.comment
+	if comment == "" {
+		comment = "no comment"
+	}
+
+	// Fingerprint of a certificate is the fingerprint of its key
+	key := entry.key
+	cert, isCert := key.(*ssh.Certificate)
+	if isCert {
+		key = cert.Key
+	}
+	sum := sha256.Sum256(key.Marshal())
+
+	// Size is left out of the title if it does not fit, eg, [ED25519-CERT]
+	title := fmt.Sprintf("[%s %d]", keyType, bits)
+	if len(title) > fieldSizeX {
+		title = "[" + keyType + "]"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d %s %s (%s)\n", bits, ssh.FingerprintSHA256(key), comment, keyType)
+	fmt.Fprintf(&b, "%d MD5:%s %s (%s)\n", bits, ssh.FingerprintLegacyMD5(key), comment, keyType)
+	b.WriteString(randomArt(sum[:], title, "SHA256"))
+	if isCert {
+		b.WriteString(certText(cert))
+	}
+
+	return b.String()
+}
+
+// certText returns details of certificate like ssh-keygen -L
+func certText(cert *ssh.Certificate) string {
+	keyType, _ := keyTypeAndBits(cert)
+	caType, _ := keyTypeAndBits(cert.SignatureKey)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "        Type: %s %s certificate\n", cert.Type(), certTypeName(cert.CertType))
+	fmt.Fprintf(&b, "        Public key: %s %s\n", keyType, ssh.FingerprintSHA256(cert.Key))
+	fmt.Fprintf(&b, "        Signing CA: %s %s (using %s)\n", caType, ssh.FingerprintSHA256(cert.SignatureKey), cert.Signature.Format)
+	fmt.Fprintf(&b, "        Key ID: %q\n", cert.KeyId)
+	fmt.Fprintf(&b, "        Serial: %d\n", cert.Serial)
+	fmt.Fprintf(&b, "        Valid: %s\n", certValidity(cert))
+
+	b.WriteString("        Principals: ")
+	writeCertList(&b, cert.ValidPrincipals, nil)
+	b.WriteString("        Critical Options: ")
+	writeCertList(&b, sortedKeys(cert.CriticalOptions), cert.CriticalOptions)
+	b.WriteString("        Extensions: ")
+	writeCertList(&b, sortedKeys(cert.Extensions), cert.Extensions)
+
+	return b.String()
+}
+
+// certValidity returns validity window of certificate in local time
+func certValidity(cert *ssh.Certificate) string {
+	const layout = "2006-01-02T15:04:05"
+
+	after := time.Unix(int64(cert.ValidAfter), 0).Format(layout)
+	before := time.Unix(int64(cert.ValidBefore), 0).Format(layout)
+
+	switch {
+	case cert.ValidAfter == 0 && cert.ValidBefore == ssh.CertTimeInfinity:
+		return "forever"
+	case cert.ValidAfter == 0:
+		return "before " + before
+	case cert.ValidBefore == ssh.CertTimeInfinity:
+		return "after " + after
+	default:
+		return "from " + after + " to " + before
+	}
+}
+
+// writeCertList writes names with their values on separate lines, or (none)
+func writeCertList(b *strings.Builder, names []string, values map[string]string) {
+	if len(names) == 0 {
+		b.WriteString("(none)\n")
+		return
+	}
+
+	b.WriteString("\n")
+	for _, name := range names {
+		b.WriteString("                " + name)
+		if values[name] != "" {
+			b.WriteString(" " + values[name])
+		}
+		b.WriteString("\n")
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// keyTypeAndBits returns OpenSSH short type name and size of key, eg, ED25519 and 256
+func keyTypeAndBits(key ssh.PublicKey) (string, int) {
+	suffix := ""
+	if cert, ok := key.(*ssh.Certificate); ok {
+		key, suffix = cert.Key, "-CERT"
+	}
+
+	keyType, bits := strings.ToUpper(key.Type()), 0
+	switch key.Type() {
+	case ssh.KeyAlgoED25519:
+		keyType, bits = "ED25519", 256
+	case ssh.KeyAlgoSKED25519:
+		keyType, bits = "ED25519-SK", 256
+	case ssh.KeyAlgoSKECDSA256:
+		keyType, bits = "ECDSA-SK", 256
+	case ssh.KeyAlgoDSA:
+		keyType, bits = "DSA", 1024
+	case ssh.KeyAlgoRSA:
+		keyType = "RSA"
+	case ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521:
+		keyType = "ECDSA"
+	}
+
+	if cryptoKey, ok := key.(ssh.CryptoPublicKey); ok {
+		switch pub := cryptoKey.CryptoPublicKey().(type) {
+		case *rsa.PublicKey:
+			bits = pub.N.BitLen()
+		case *ecdsa.PublicKey:
+			bits = pub.Curve.Params().BitSize
+		}
+	}
+
+	return keyType + suffix, bits
+}
+
+// pubKeyComment returns comment of the public key next to private key in path.
+// Comment is empty if there is no public key.
+func pubKeyComment(path string) (string, error) {
+	data, err := os.ReadFile(path + ".pub")
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	_, comment, _, _, err := ssh.ParseAuthorizedKey(data)
+	return comment, err
+}
@@ -0,0 +1,120 @@
+package ssh
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/yakuter/gossl/pkg/utils"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/crypto/ssh"
+)
+
+func PasswdFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        flagIn,
+			Usage:       "SSH private key file, rewritten in place (required)",
+			DefaultText: "eg, ./id_ed25519",
+			Required:    true,
+		},
+		&cli.StringFlag{
+			Name:     flagOldPassphrase,
+			Usage:    "Current passphrase of private key, asked if key is encrypted and it is not set (optional)",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     flagPassphrase,
+			Usage:    "New passphrase, asked if not set, empty removes passphrase (optional)",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     flagComment,
+			Usage:    "Comment of private key, read from .pub file next to key if not set (optional)",
+			Required: false,
+		},
+	}
+}
+
+func PasswdAction(reader utils.PasswordReader) func(*cli.Context) error {
+	return func(c *cli.Context) error {
+		path := c.String(flagIn)
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("Failed to read private key file %s error: %v", path, err)
+			return err
+		}
+
+		privateKey, err := readPrivateKey(c, reader, data, flagOldPassphrase, "Enter old passphrase: ")
+		if err != nil {
+			log.Printf("Failed to parse private key %s error: %v", path, err)
+			return err
+		}
+
+		comment, err := keyComment(c, path)
+		if err != nil {
+			log.Printf("Failed to read comment of private key %s error: %v", path, err)
+			return err
+		}
+
+		passphrase, err := newPassphrase(c, reader)
+		if err != nil {
+			log.Printf("Failed to read passphrase error: %v", err)
+			return err
+		}
+
+		privateKeyBytes, err := utils.SSHPrivateKeyToPEM(privateKey, comment, []byte(passphrase))
+		if err != nil {
+			log.Printf("Failed to encode private key error: %v", err)
+			return err
+		}
+
+		if err = os.WriteFile(path, privateKeyBytes, 0o600); err != nil {
+			log.Printf("Failed to write private key to file %s error: %v", path, err)
+			return err
+		}
+
+		if passphrase == "" {
+			log.Printf("Passphrase of %s removed", path)
+		} else {
+			log.Printf("Passphrase of %s changed", path)
+		}
+		return nil
+	}
+}
+
+// readPrivateKey parses private key and decrypts it if it is encrypted with
+// passphrase in passphraseFlag, or asks it with prompt if the flag is not set
+func readPrivateKey(c *cli.Context, reader utils.PasswordReader, data []byte, passphraseFlag, prompt string) (crypto.Signer, error) {
+	privateKey, err := utils.SSHPrivateKeyFromPEM(data, nil)
+
+	var missing *ssh.PassphraseMissingError
+	if !errors.As(err, &missing) {
+		return privateKey, err
+	}
+
+	passphrase := c.String(passphraseFlag)
+	if !c.IsSet(passphraseFlag) {
+		fmt.Print(prompt)
+		passphrase, err = reader.ReadPassword()
+		fmt.Println()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return utils.SSHPrivateKeyFromPEM(data, []byte(passphrase))
+}
+
+// keyComment returns comment flag or comment of the public key next to private key
+func keyComment(c *cli.Context, path string) (string, error) {
+	if c.IsSet(flagComment) {
+		return c.String(flagComment), nil
+	}
+
+	return pubKeyComment(path)
+}
@@ -0,0 +1,82 @@
+package ssh
+
+import "strings"
+
+// Size of randomart field, same as OpenSSH
+const (
+	fieldBase  = 8
+	fieldSizeY = fieldBase + 1
+	fieldSizeX = fieldBase*2 + 1
+)
+
+// augmentation maps visit counts to characters, S is start and E is end
+const augmentation = " .o+=*BOX@%&#/^SE"
+
+// randomArt draws digest as OpenSSH visual host key with the "drunken bishop"
+// algorithm. Title, eg, [ED25519 256], is written into the upper border and hash
+// name into the lower one.
+func randomArt(digest []byte, title, hash string) string {
+	var field [fieldSizeX][fieldSizeY]int
+	last := len(augmentation) - 1
+
+	// Bishop starts in the center and moves diagonally 2 bits at a time
+	x, y := fieldSizeX/2, fieldSizeY/2
+	for _, input := range digest {
+		for b := 0; b < 4; b++ {
+			if input&0x1 != 0 {
+				x++
+			} else {
+				x--
+			}
+			if input&0x2 != 0 {
+				y++
+			} else {
+				y--
+			}
+
+			x = clamp(x, fieldSizeX-1)
+			y = clamp(y, fieldSizeY-1)
+
+			if field[x][y] < last-2 {
+				field[x][y]++
+			}
+			input >>= 2
+		}
+	}
+
+	field[fieldSizeX/2][fieldSizeY/2] = last - 1
+	field[x][y] = last
+
+	if len(title) > fieldSizeX {
+		title = title[:fieldSizeX]
+	}
+
+	var b strings.Builder
+	b.WriteString(border(title))
+	for y := 0; y < fieldSizeY; y++ {
+		b.WriteByte('|')
+		for x := 0; x < fieldSizeX; x++ {
+			b.WriteByte(augmentation[field[x][y]])
+		}
+		b.WriteString("|\n")
+	}
+	b.WriteString(border("[" + hash + "]"))
+
+	return b.String()
+}
+
+// border returns a horizontal border with centered label
+func border(label string) string {
+	left := (fieldSizeX - len(label)) / 2
+	return "+" + strings.Repeat("-", left) + label + strings.Repeat("-", fieldSizeX-left-len(label)) + "+\n"
+}
+
+func clamp(v, max int) int {
+	if v < 0 {
+		return 0
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
@@ -0,0 +1,275 @@
+package ssh
+
+import (
+	"crypto"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/yakuter/gossl/pkg/utils"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/crypto/ssh"
+)
+
+// Critical options of user certificates
+const (
+	optionForceCommand  = "force-command"
+	optionSourceAddress = "source-address"
+)
+
+// defaultExtensions are extensions of user certificates issued by ssh-keygen
+var defaultExtensions = []string{
+	"permit-X11-forwarding",
+	"permit-agent-forwarding",
+	"permit-port-forwarding",
+	"permit-pty",
+	"permit-user-rc",
+}
+
+func SignFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        flagCAKey,
+			Usage:       "CA private key file to sign certificate with (required)",
+			DefaultText: "eg, ./ca",
+			Required:    true,
+		},
+		&cli.StringFlag{
+			Name:     flagPassphrase,
+			Usage:    "Passphrase of encrypted CA key, asked if not set (optional)",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:        flagID,
+			Usage:       "Key ID of certificate, logged by SSH server on authentication (required)",
+			DefaultText: "eg, alice@example.com",
+			Required:    true,
+		},
+		&cli.StringSliceFlag{
+			Name:     flagPrincipals,
+			Usage:    "User names, or host names for host certificates, the certificate is valid for (required)",
+			Required: true,
+		},
+		&cli.BoolFlag{
+			Name:     flagHost,
+			Usage:    "Issue a host certificate instead of a user certificate",
+			Required: false,
+		},
+		&cli.Uint64Flag{
+			Name:     flagSerial,
+			Usage:    "Serial number of certificate",
+			Required: false,
+		},
+		&cli.TimestampFlag{
+			Name:        flagValidFrom,
+			Usage:       "Start of validity in RFC 3339 format, eg, 2024-01-31T00:00:00Z",
+			Layout:      time.RFC3339,
+			DefaultText: "now",
+			Required:    false,
+		},
+		&cli.DurationFlag{
+			Name:     flagValidity,
+			Usage:    "Validity of certificate after start, 0 means forever",
+			Value:    24 * time.Hour,
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     flagForceCommand,
+			Usage:    "Command executed instead of the one requested by user (user certificates only)",
+			Required: false,
+		},
+		&cli.StringSliceFlag{
+			Name:     flagSourceAddress,
+			Usage:    "Addresses or CIDRs the certificate can be used from (user certificates only)",
+			Required: false,
+		},
+		&cli.StringSliceFlag{
+			Name:        flagExtensions,
+			Usage:       "Extensions of user certificates, empty for none",
+			DefaultText: strings.Join(defaultExtensions, ","),
+			Required:    false,
+		},
+		&cli.StringFlag{
+			Name:        flagOut,
+			Usage:       "Output certificate file path",
+			DefaultText: "public key path with -cert.pub suffix",
+			Required:    false,
+		},
+	}
+}
+
+func SignAction(reader utils.PasswordReader) func(*cli.Context) error {
+	return func(c *cli.Context) error {
+		if c.Args().Len() != 1 {
+			return errors.New("Please provide a public key file to sign")
+		}
+		pubKeyPath := c.Args().First()
+
+		data, err := os.ReadFile(pubKeyPath)
+		if err != nil {
+			log.Printf("Failed to read public key file %s error: %v", pubKeyPath, err)
+			return err
+		}
+
+		pubKey, comment, _, _, err := ssh.ParseAuthorizedKey(data)
+		if err != nil {
+			log.Printf("Failed to parse public key %s error: %v", pubKeyPath, err)
+			return err
+		}
+		if _, ok := pubKey.(*ssh.Certificate); ok {
+			return fmt.Errorf("%s is already a certificate", pubKeyPath)
+		}
+
+		cert, err := certTemplate(c, pubKey)
+		if err != nil {
+			log.Printf("Failed to prepare certificate error: %v", err)
+			return err
+		}
+
+		caKeyData, err := os.ReadFile(c.String(flagCAKey))
+		if err != nil {
+			log.Printf("Failed to read CA key file %s error: %v", c.String(flagCAKey), err)
+			return err
+		}
+
+		caKey, err := readPrivateKey(c, reader, caKeyData, flagPassphrase, "Enter passphrase for CA key: ")
+		if err != nil {
+			log.Printf("Failed to parse CA key %s error: %v", c.String(flagCAKey), err)
+			return err
+		}
+
+		signer, err := caSigner(caKey)
+		if err != nil {
+			log.Printf("Failed to create signer from CA key error: %v", err)
+			return err
+		}
+
+		if err = cert.SignCert(rand.Reader, signer); err != nil {
+			log.Printf("Failed to sign certificate error: %v", err)
+			return err
+		}
+
+		certBytes := ssh.MarshalAuthorizedKey(cert)
+		if comment != "" {
+			certBytes = append(certBytes[:len(certBytes)-1], []byte(" "+comment+"\n")...)
+		}
+
+		out := strings.TrimSuffix(pubKeyPath, ".pub") + "-cert.pub"
+		if c.IsSet(flagOut) {
+			out = c.String(flagOut)
+		}
+		if err = os.WriteFile(out, certBytes, 0o600); err != nil {
+			log.Printf("Failed to write certificate to file %s error: %v", out, err)
+			return err
+		}
+
+		log.Printf("Signed %s certificate %q serial %d written to %s", certTypeName(cert.CertType), cert.KeyId, cert.Serial, out)
+		return nil
+	}
+}
+
+// caSigner returns signer of CA key, RSA keys sign with rsa-sha2-512 like ssh-keygen
+func caSigner(caKey crypto.Signer) (ssh.Signer, error) {
+	signer, err := ssh.NewSignerFromKey(caKey)
+	if err != nil {
+		return nil, err
+	}
+
+	algorithmSigner, ok := signer.(ssh.AlgorithmSigner)
+	if !ok || signer.PublicKey().Type() != ssh.KeyAlgoRSA {
+		return signer, nil
+	}
+
+	return ssh.NewSignerWithAlgorithms(algorithmSigner, []string{ssh.KeyAlgoRSASHA512})
+}
+
+// certTemplate returns an unsigned certificate of pubKey described by flags
+func certTemplate(c *cli.Context, pubKey ssh.PublicKey) (*ssh.Certificate, error) {
+	cert := &ssh.Certificate{
+		Key:             pubKey,
+		Serial:          c.Uint64(flagSerial),
+		CertType:        ssh.UserCert,
+		KeyId:           c.String(flagID),
+		ValidPrincipals: splitList(c.StringSlice(flagPrincipals)),
+		ValidBefore:     ssh.CertTimeInfinity,
+	}
+
+	if len(cert.ValidPrincipals) == 0 {
+		return nil, errors.New("at least one principal is required")
+	}
+
+	// Small clock skew is tolerated like OCSP responses
+	validFrom := time.Now().Add(-time.Minute)
+	if c.IsSet(flagValidFrom) {
+		validFrom = *c.Timestamp(flagValidFrom)
+	}
+	cert.ValidAfter = uint64(validFrom.Unix())
+	if validity := c.Duration(flagValidity); validity > 0 {
+		cert.ValidBefore = uint64(validFrom.Add(validity).Unix())
+	} else if validity < 0 {
+		return nil, errors.New("validity must not be negative")
+	}
+
+	if c.Bool(flagHost) {
+		if c.IsSet(flagForceCommand) || c.IsSet(flagSourceAddress) || c.IsSet(flagExtensions) {
+			return nil, errors.New("host certificates can not have critical options or extensions")
+		}
+		cert.CertType = ssh.HostCert
+		return cert, nil
+	}
+
+	cert.CriticalOptions = map[string]string{}
+	if c.IsSet(flagForceCommand) {
+		cert.CriticalOptions[optionForceCommand] = c.String(flagForceCommand)
+	}
+	if c.IsSet(flagSourceAddress) {
+		addresses := splitList(c.StringSlice(flagSourceAddress))
+		for _, address := range addresses {
+			if net.ParseIP(address) == nil {
+				if _, _, err := net.ParseCIDR(address); err != nil {
+					return nil, fmt.Errorf("invalid source address %q", address)
+				}
+			}
+		}
+		cert.CriticalOptions[optionSourceAddress] = strings.Join(addresses, ",")
+	}
+
+	extensions := defaultExtensions
+	if c.IsSet(flagExtensions) {
+		extensions = splitList(c.StringSlice(flagExtensions))
+	}
+	cert.Extensions = map[string]string{}
+	for _, extension := range extensions {
+		cert.Extensions[extension] = ""
+	}
+
+	return cert, nil
+}
+
+// splitList returns comma separated values of repeated flags without empty strings
+func splitList(values []string) []string {
+	var result []string
+	for _, value := range values {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				result = append(result, v)
+			}
+		}
+	}
+
+	return result
+}
+
+func certTypeName(certType uint32) string {
+	if certType == ssh.HostCert {
+		return "host"
+	}
+
+	return "user"
+}
@@ -1,6 +1,8 @@
 package ssh
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"os"
 
@@ -10,21 +12,72 @@ import (
 )
 
 const (
-	CmdSSH = "ssh"
+	CmdSSH         = "ssh"
+	CmdPasswd      = "passwd"
+	CmdFingerprint = "fingerprint"
+	CmdSign        = "sign"
 
-	flagOut  = "out"
-	flagBits = "bits"
+	flagOut           = "out"
+	flagBits          = "bits"
+	flagType          = "type"
+	flagCurve         = "curve"
+	flagComment       = "comment"
+	flagPassphrase    = "passphrase"
+	flagIn            = "in"
+	flagOldPassphrase = "oldpassphrase"
+	flagCAKey         = "cakey"
+	flagID            = "id"
+	flagPrincipals    = "principals"
+	flagHost          = "host"
+	flagSerial        = "serial"
+	flagValidFrom     = "validfrom"
+	flagValidity      = "validity"
+	flagForceCommand  = "forceCommand"
+	flagSourceAddress = "sourceAddress"
+	flagExtensions    = "extensions"
 )
 
-func Command() *cli.Command {
+func Command(reader utils.PasswordReader) *cli.Command {
 	return &cli.Command{
-		Name:        CmdSSH,
-		HelpName:    CmdSSH,
-		Action:      Action,
-		ArgsUsage:   ` `,
-		Usage:       `generates RSA SSH key pair.`,
-		Description: `Generates RSA SSH key pair private and public key with provided number of bits.`,
-		Flags:       Flags(),
+		Name:      CmdSSH,
+		HelpName:  CmdSSH,
+		Action:    Action(reader),
+		ArgsUsage: ` `,
+		Usage:     `generates SSH key pair.`,
+		Description: `Generates RSA, Ed25519 or ECDSA SSH key pair, private key in OpenSSH format and public key in authorized_keys format.
+Private key is encrypted with bcrypt-pbkdf and AES-256-CTR like OpenSSH if a passphrase is provided.`,
+		Flags: Flags(),
+		Subcommands: []*cli.Command{
+			{
+				Name:        CmdPasswd,
+				HelpName:    CmdSSH + " " + CmdPasswd,
+				Action:      PasswdAction(reader),
+				ArgsUsage:   ` `,
+				Usage:       `changes or removes passphrase of SSH private key.`,
+				Description: `Changes or removes passphrase of SSH private key and rewrites it in OpenSSH format.`,
+				Flags:       PasswdFlags(),
+			},
+			{
+				Name:      CmdFingerprint,
+				HelpName:  CmdSSH + " " + CmdFingerprint,
+				Action:    FingerprintAction,
+				ArgsUsage: `[file]`,
+				Usage:     `shows fingerprints and randomart of SSH keys.`,
+				Description: `Shows SHA256 and MD5 fingerprints, type, size, comment and randomart of keys in
+a public key, private key, authorized_keys or known_hosts file like ssh-keygen -l -v.
+Details of OpenSSH certificates are shown like ssh-keygen -L.`,
+			},
+			{
+				Name:      CmdSign,
+				HelpName:  CmdSSH + " " + CmdSign,
+				Action:    SignAction(reader),
+				ArgsUsage: `[public key file]`,
+				Usage:     `signs SSH public key with a CA key and issues an OpenSSH certificate.`,
+				Description: `Signs user or host SSH public key with a CA key and issues an OpenSSH certificate
+with key ID, principals, validity window, critical options and extensions like ssh-keygen -s.`,
+				Flags: SignFlags(),
+			},
+		},
 	}
 }
 
@@ -32,51 +85,122 @@ func Flags() []cli.Flag {
 	return []cli.Flag{
 		&cli.StringFlag{
 			Name:        flagOut,
-			Usage:       "Output file path",
+			Usage:       "Output file path, public key is written to the same path with .pub suffix",
+			Required:    false,
+			DefaultText: "./id_rsa, ./id_ed25519 or ./id_ecdsa",
+		},
+		&cli.StringFlag{
+			Name:        flagType,
+			Usage:       "Key type (rsa, ed25519 or ecdsa)",
+			DefaultText: utils.KeyTypeRSA,
+			Value:       utils.KeyTypeRSA,
 			Required:    false,
-			Value:       "./id_rsa",
-			DefaultText: "./id_rsa",
 		},
 		&cli.UintFlag{
 			Name:     flagBits,
-			Usage:    "Number of bits",
-			Required: true,
+			Usage:    "Number of bits (required for rsa)",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:        flagCurve,
+			Usage:       "Elliptic curve (P-256, P-384 or P-521) (ecdsa only)",
+			DefaultText: "P-256",
+			Value:       "P-256",
+			Required:    false,
+		},
+		&cli.StringFlag{
+			Name:     flagComment,
+			Usage:    "Comment written to private and public key, eg, user@host (optional)",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     flagPassphrase,
+			Usage:    "Passphrase to encrypt private key with, asked if not set, empty for no passphrase (optional)",
+			Required: false,
 		},
 	}
 }
 
-func Action(c *cli.Context) error {
-	// Generate Private Key
-	privateKey, err := utils.GeneratePrivateKey(int(c.Uint(flagBits)))
-	if err != nil {
-		log.Printf("Failed to generate RSA Private Key with bit size %d error: %v", c.Uint(flagBits), err)
-		return err
+func Action(reader utils.PasswordReader) func(*cli.Context) error {
+	return func(c *cli.Context) error {
+		keyType := c.String(flagType)
+		if keyType == utils.KeyTypeRSA && !c.IsSet(flagBits) {
+			log.Printf("Number of bits is required for %s keys", keyType)
+			return fmt.Errorf("required flag %q not set", flagBits)
+		}
+
+		// Generate Private Key
+		privateKey, err := utils.GenerateKey(keyType, int(c.Uint(flagBits)), c.String(flagCurve))
+		if err != nil {
+			log.Printf("Failed to generate %s Private Key error: %v", keyType, err)
+			return err
+		}
+
+		// Generate Public Key from Private Key
+		publicKeyBytes, err := utils.GenerateSSHPublicKey(privateKey.Public(), c.String(flagComment))
+		if err != nil {
+			log.Printf("Failed to generate Public Key error: %v", err)
+			return err
+		}
+
+		passphrase, err := newPassphrase(c, reader)
+		if err != nil {
+			log.Printf("Failed to read passphrase error: %v", err)
+			return err
+		}
+
+		// Encode Private Key in OpenSSH format
+		privateKeyBytes, err := utils.SSHPrivateKeyToPEM(privateKey, c.String(flagComment), []byte(passphrase))
+		if err != nil {
+			log.Printf("Failed to encode Private Key error: %v", err)
+			return err
+		}
+
+		// Write private key to file
+		privateKeyFilePath := "./id_" + keyType
+		if c.IsSet(flagOut) {
+			privateKeyFilePath = c.String(flagOut)
+		}
+		if err = os.WriteFile(privateKeyFilePath, privateKeyBytes, 0o600); err != nil {
+			log.Printf("Failed to write Private Key to file %s error: %v", privateKeyFilePath, err)
+			return err
+		}
+
+		// Write public key to file
+		publicKeyFilePath := privateKeyFilePath + ".pub"
+		if err = os.WriteFile(publicKeyFilePath, publicKeyBytes, 0o600); err != nil {
+			log.Printf("Failed to write Public Key to file %s error: %v", publicKeyFilePath, err)
+			return err
+		}
+
+		log.Printf("SSH key pair generated")
+		return nil
 	}
+}
 
-	// Generate Public Key from Private Key
-	publicKeyBytes, err := utils.GenerateSSHPublicKey(&privateKey.PublicKey)
-	if err != nil {
-		log.Printf("Failed to generate Public Key error: %v", err)
-		return err
+// newPassphrase returns passphrase flag or asks new passphrase twice
+func newPassphrase(c *cli.Context, reader utils.PasswordReader) (string, error) {
+	if c.IsSet(flagPassphrase) {
+		return c.String(flagPassphrase), nil
 	}
 
-	// Encode Private Key from RSA to PEM format
-	privateKeyBytes := utils.PrivateKeyToPEM(privateKey)
+	fmt.Print("Enter passphrase (empty for no passphrase): ")
+	passphrase, err := reader.ReadPassword()
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
 
-	// Write private key to file
-	privateKeyFilePath := c.String(flagOut)
-	if err = os.WriteFile(privateKeyFilePath, privateKeyBytes, 0o600); err != nil {
-		log.Printf("Failed to write Private Key to file %s error: %v", privateKeyFilePath, err)
-		return err
+	fmt.Print("Enter same passphrase again: ")
+	verify, err := reader.ReadPassword()
+	fmt.Println()
+	if err != nil {
+		return "", err
 	}
 
-	// Write public key to file
-	publicKeyFilePath := privateKeyFilePath + ".pub"
-	if err = os.WriteFile(publicKeyFilePath, publicKeyBytes, 0o600); err != nil {
-		log.Printf("Failed to write Public Key to file %s error: %v", publicKeyFilePath, err)
-		return err
+	if passphrase != verify {
+		return "", errors.New("passphrases do not match")
 	}
 
-	log.Printf("SSH key pair generated")
-	return nil
+	return passphrase, nil
 }
@@ -0,0 +1,294 @@
+package ssh_copy
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Values of strict-host-key-checking flag, same as OpenSSH StrictHostKeyChecking
+const (
+	strictAsk       = "ask"
+	strictYes       = "yes"
+	strictNo        = "no"
+	strictAcceptNew = "accept-new"
+)
+
+// Host key algorithms offered to server in preference order
+var (
+	certHostKeyAlgorithms = []string{
+		ssh.CertAlgoED25519v01,
+		ssh.CertAlgoECDSA256v01, ssh.CertAlgoECDSA384v01, ssh.CertAlgoECDSA521v01,
+		ssh.CertAlgoRSASHA512v01, ssh.CertAlgoRSASHA256v01,
+	}
+	keyHostKeyAlgorithms = []string{
+		ssh.KeyAlgoED25519,
+		ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521,
+		ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA,
+	}
+	hostKeyAlgorithms = append(append([]string{}, certHostKeyAlgorithms...), keyHostKeyAlgorithms...)
+)
+
+// errHostKeyVerification is returned when host key is not trusted
+var errHostKeyVerification = errors.New("host key verification failed")
+
+// hostKeyChecker verifies host keys against a known_hosts file and
+// adds unknown host keys to it according to strict mode
+type hostKeyChecker struct {
+	path   string
+	strict string
+	in     *bufio.Scanner
+	known  ssh.HostKeyCallback
+	// authorities are host patterns of @cert-authority lines
+	authorities [][]string
+}
+
+// newHostKeyChecker reads known_hosts file in path, a missing file has no hosts
+func newHostKeyChecker(path, strict string, in io.Reader) (*hostKeyChecker, error) {
+	switch strict {
+	case strictAsk, strictYes, strictNo, strictAcceptNew:
+	default:
+		return nil, fmt.Errorf("unsupported strict host key checking %q, it must be ask, yes, no or accept-new", strict)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	var files []string
+	if err == nil {
+		files = append(files, path)
+	}
+
+	known, err := knownhosts.New(files...)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &hostKeyChecker{path: path, strict: strict, in: bufio.NewScanner(in), known: known}
+	for rest := data; len(rest) > 0; {
+		var (
+			marker string
+			hosts  []string
+		)
+		marker, hosts, _, _, rest, err = ssh.ParseKnownHosts(rest)
+		if err != nil {
+			break
+		}
+		if marker == "cert-authority" {
+			h.authorities = append(h.authorities, hosts)
+		}
+	}
+
+	return h, nil
+}
+
+// HostKeyAlgorithms returns host key algorithms with algorithms of known keys
+// of host first so that server sends the known key instead of a key of another
+// type. Certificates are only asked from an unknown host if a @cert-authority
+// line matches it, otherwise a plain key is asked to be trusted on first use.
+func (h *hostKeyChecker) HostKeyAlgorithms(hostname string) []string {
+	// Known keys are listed in the error of a key which is never known
+	probe, err := ssh.NewPublicKey(ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)).Public())
+	if err != nil {
+		return nil
+	}
+
+	var keyErr *knownhosts.KeyError
+	if !errors.As(h.known(hostname, &net.TCPAddr{IP: net.IPv4zero}, probe), &keyErr) || len(keyErr.Want) == 0 {
+		if h.hasAuthority(hostname) {
+			return hostKeyAlgorithms
+		}
+		return keyHostKeyAlgorithms
+	}
+
+	var known []string
+	for _, k := range keyErr.Want {
+		if k.Key.Type() == ssh.KeyAlgoRSA {
+			known = append(known, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256)
+		}
+		known = append(known, k.Key.Type())
+	}
+
+	algorithms := known
+	for _, algorithm := range hostKeyAlgorithms {
+		if !contains(known, algorithm) {
+			algorithms = append(algorithms, algorithm)
+		}
+	}
+
+	return algorithms
+}
+
+// hasAuthority reports whether a @cert-authority line matches hostname in host:port form
+func (h *hostKeyChecker) hasAuthority(hostname string) bool {
+	host, port, err := net.SplitHostPort(hostname)
+	if err != nil {
+		return false
+	}
+
+	for _, patterns := range h.authorities {
+		if matchHostPatterns(patterns, host, port) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchHostPatterns matches host and port with known_hosts patterns such as
+// *.example.com, [10.0.0.?]:2222 or !bastion.example.com
+func matchHostPatterns(patterns []string, host, port string) bool {
+	matched := false
+	for _, pattern := range patterns {
+		negated := strings.HasPrefix(pattern, "!")
+		pattern = strings.TrimPrefix(pattern, "!")
+
+		patternHost, patternPort := pattern, "22"
+		if strings.HasPrefix(pattern, "[") {
+			var err error
+			if patternHost, patternPort, err = net.SplitHostPort(pattern); err != nil {
+				continue
+			}
+		}
+
+		if ok, _ := path.Match(patternHost, host); !ok || patternPort != port {
+			continue
+		}
+		if negated {
+			return false
+		}
+		matched = true
+	}
+
+	return matched
+}
+
+// Check is an ssh.HostKeyCallback
+func (h *hostKeyChecker) Check(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	err := h.known(hostname, remote, key)
+
+	var (
+		keyErr     *knownhosts.KeyError
+		revokedErr *knownhosts.RevokedError
+	)
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &revokedErr):
+		log.Printf("Host key of %s is marked as revoked in %s:%d", hostname, revokedErr.Revoked.Filename, revokedErr.Revoked.Line)
+		return errHostKeyVerification
+	case !errors.As(err, &keyErr):
+		return err
+	case len(keyErr.Want) > 0:
+		fmt.Fprint(os.Stderr, mismatchWarning(hostname, key, keyErr.Want))
+		return errHostKeyVerification
+	}
+
+	// Host is unknown
+	switch h.strict {
+	case strictYes:
+		log.Printf("No %s host key is known for %s and strict host key checking is %s", key.Type(), hostname, h.strict)
+		return errHostKeyVerification
+	case strictAsk:
+		if !h.confirm(hostname, key) {
+			return errHostKeyVerification
+		}
+	}
+
+	if err = h.add(hostname, key); err != nil {
+		log.Printf("Failed to add host key to %s error: %v", h.path, err)
+		return err
+	}
+
+	log.Printf("Permanently added '%s' (%s) to the list of known hosts.", knownhosts.Normalize(hostname), key.Type())
+	return nil
+}
+
+// confirm asks user to trust host key on first use, fingerprint itself is also accepted
+func (h *hostKeyChecker) confirm(hostname string, key ssh.PublicKey) bool {
+	fingerprint := ssh.FingerprintSHA256(key)
+
+	fmt.Printf("The authenticity of host '%s' can't be established.\n", knownhosts.Normalize(hostname))
+	fmt.Printf("%s key fingerprint is %s.\n", key.Type(), fingerprint)
+	fmt.Print("Are you sure you want to continue connecting (yes/no/[fingerprint])? ")
+
+	for h.in.Scan() {
+		switch answer := strings.TrimSpace(h.in.Text()); answer {
+		case "yes", fingerprint:
+			return true
+		case "no":
+			return false
+		default:
+			fmt.Print("Please type 'yes', 'no' or the fingerprint: ")
+		}
+	}
+
+	return false
+}
+
+// add appends host key to known_hosts file, creating it if it does not exist
+func (h *hostKeyChecker) add(hostname string, key ssh.PublicKey) error {
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(h.path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Previous line may not end with a new line
+	line := knownhosts.Line([]string{hostname}, key) + "\n"
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err = f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			line = "\n" + line
+		}
+	}
+
+	_, err = f.WriteString(line)
+	return err
+}
+
+// mismatchWarning returns OpenSSH style warning of a changed host key
+func mismatchWarning(hostname string, key ssh.PublicKey, known []knownhosts.KnownKey) string {
+	var b strings.Builder
+	b.WriteString("@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@\n")
+	b.WriteString("@    WARNING: REMOTE HOST IDENTIFICATION HAS CHANGED!     @\n")
+	b.WriteString("@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@\n")
+	b.WriteString("IT IS POSSIBLE THAT SOMEONE IS DOING SOMETHING NASTY!\n")
+	b.WriteString("Someone could be eavesdropping on you right now (man-in-the-middle attack)!\n")
+	b.WriteString("It is also possible that a host key has just been changed.\n")
+	fmt.Fprintf(&b, "The fingerprint for the %s key sent by the remote host %s is\n%s.\n",
+		key.Type(), knownhosts.Normalize(hostname), ssh.FingerprintSHA256(key))
+	for _, k := range known {
+		fmt.Fprintf(&b, "Offending %s key in %s:%d\n", k.Key.Type(), k.Filename, k.Line)
+	}
+	b.WriteString("Password is not sent to the host, remove the offending key if the change is expected.\n")
+
+	return b.String()
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
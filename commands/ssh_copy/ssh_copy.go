@@ -2,36 +2,42 @@ package ssh_copy
 
 import (
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
-	"syscall"
 	"time"
 
+	"github.com/yakuter/gossl/pkg/utils"
+
 	"github.com/pkg/sftp"
 	"github.com/urfave/cli/v2"
 	"golang.org/x/crypto/ssh"
-	"golang.org/x/term"
 )
 
 const (
 	CmdSSHCopy = "ssh-copy"
 
-	flagPubkey   = "pubkey"
-	flagPort     = "port"
-	flagPassword = "password"
+	flagPubkey                = "pubkey"
+	flagPort                  = "port"
+	flagPassword              = "password"
+	flagKnownHosts            = "known-hosts"
+	flagStrictHostKeyChecking = "strict-host-key-checking"
 )
 
-func Command(reader passwordReader) *cli.Command {
+func Command(reader utils.PasswordReader, in io.Reader) *cli.Command {
 	return &cli.Command{
-		Name:        CmdSSHCopy,
-		HelpName:    CmdSSHCopy,
-		Action:      Action(reader),
-		ArgsUsage:   `[remote-user@remote-ip]`,
-		Usage:       `copy SSH public key to remote server.`,
-		Description: `Copy SSH public key to authorized_keys in remote SSH server.`,
-		Flags:       Flags(),
+		Name:      CmdSSHCopy,
+		HelpName:  CmdSSHCopy,
+		Action:    Action(reader, in),
+		ArgsUsage: `[remote-user@remote-ip]`,
+		Usage:     `copy SSH public key to remote server.`,
+		Description: `Copy SSH public key to authorized_keys in remote SSH server.
+Host key of server is verified with known_hosts file before password is sent.`,
+		Flags: Flags(),
 	}
 }
 
@@ -55,12 +61,26 @@ func Flags() []cli.Flag {
 			Usage:    "SSH server password",
 			Required: false,
 		},
+		&cli.StringFlag{
+			Name:        flagKnownHosts,
+			Usage:       "Known hosts file to verify host key with",
+			Required:    false,
+			DefaultText: "eg, /home/user/.ssh/known_hosts",
+		},
+		&cli.StringFlag{
+			Name: flagStrictHostKeyChecking,
+			Usage: "Unknown host keys are asked to trust (ask), refused (yes) or added without asking (accept-new, no), " +
+				"changed host keys are always refused",
+			Required:    false,
+			Value:       strictAsk,
+			DefaultText: strictAsk,
+		},
 	}
 }
 
 var homeDir = os.UserHomeDir
 
-func Action(reader passwordReader) func(*cli.Context) error {
+func Action(reader utils.PasswordReader, in io.Reader) func(*cli.Context) error {
 	return func(c *cli.Context) error {
 		var pubKeyPath string
 		if !c.IsSet(flagPubkey) {
@@ -90,20 +110,41 @@ func Action(reader passwordReader) func(*cli.Context) error {
 			return err
 		}
 
-		var pwd string
-		if c.IsSet(flagPassword) {
-			pwd = c.String(flagPassword)
+		var knownHostsPath string
+		if !c.IsSet(flagKnownHosts) {
+			homeDir, err := homeDir()
+			if err != nil {
+				log.Printf("Failed to get user home dir error: %v", err)
+				return err
+			}
+			knownHostsPath = filepath.Join(homeDir, ".ssh", "known_hosts")
 		} else {
+			knownHostsPath = c.String(flagKnownHosts)
+		}
+
+		hostKeys, err := newHostKeyChecker(knownHostsPath, c.String(flagStrictHostKeyChecking), in)
+		if err != nil {
+			log.Printf("Failed to read known hosts %s error: %v", knownHostsPath, err)
+			return err
+		}
+
+		// Password is asked after host key is verified
+		password := func() (string, error) {
+			if c.IsSet(flagPassword) {
+				return c.String(flagPassword), nil
+			}
+
 			fmt.Printf("Password: ")
-			pwd, err = reader.ReadPassword()
+			pwd, err := reader.ReadPassword()
 			if err != nil {
 				log.Printf("failed to read inputs %v", err)
-				return err
+				return "", err
 			}
+			return pwd, nil
 		}
 
 		// Connect to remote SSH server with SFTP
-		client, err := connectSFTP(host, user, pwd, int(c.Uint(flagPort)))
+		client, err := connectSFTP(host, user, password, hostKeys, int(c.Uint(flagPort)))
 		if err != nil {
 			log.Printf("Failed to connect SSH server error: %v", err)
 			return err
@@ -153,17 +194,18 @@ func Action(reader passwordReader) func(*cli.Context) error {
 }
 
 // connectSFTP creates ssh config, tries to connect (dial) SSH server and
-// creates new client with connection
-func connectSFTP(host, username, password string, port int) (*sftp.Client, error) {
-	addr := fmt.Sprintf("%s:%d", host, port)
+// creates new client with connection. Host key is verified with hostKeys.
+func connectSFTP(host, username string, password func() (string, error), hostKeys *hostKeyChecker, port int) (*sftp.Client, error) {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
 
 	config := &ssh.ClientConfig{
 		User: username,
 		Auth: []ssh.AuthMethod{
-			ssh.Password(password),
+			ssh.PasswordCallback(password),
 		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:         5 * time.Second,
+		HostKeyCallback:   hostKeys.Check,
+		HostKeyAlgorithms: hostKeys.HostKeyAlgorithms(addr),
+		Timeout:           5 * time.Second,
 	}
 
 	conn, err := ssh.Dial("tcp", addr, config)
@@ -180,18 +222,3 @@ func connectSFTP(host, username, password string, port int) (*sftp.Client, error
 
 	return client, nil
 }
-
-type passwordReader interface {
-	ReadPassword() (string, error)
-}
-
-type StdinPasswordReader struct{}
-
-func (pr StdinPasswordReader) ReadPassword() (string, error) {
-	pwd, err := term.ReadPassword(int(syscall.Stdin))
-	if err != nil {
-		return "", err
-	}
-
-	return string(pwd), nil
-}
@@ -1,12 +1,14 @@
 package ssh_copy
 
 import (
+	"crypto/rand"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/yakuter/gossl/pkg/utils"
@@ -15,6 +17,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/urfave/cli/v2"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
 )
 
 const (
@@ -51,6 +54,24 @@ func TestSSHCopy(t *testing.T) {
 
 	config.AddHostKey(private)
 
+	// Server also has a host certificate, it is only used if its CA is trusted
+	caKey, err := utils.GenerateEd25519PrivateKey()
+	require.NoError(t, err)
+	caSigner, err := ssh.NewSignerFromKey(caKey)
+	require.NoError(t, err)
+
+	hostCert := &ssh.Certificate{
+		Key:             private.PublicKey(),
+		CertType:        ssh.HostCert,
+		ValidPrincipals: []string{"localhost"},
+		ValidBefore:     ssh.CertTimeInfinity,
+	}
+	require.NoError(t, hostCert.SignCert(rand.Reader, caSigner))
+	certSigner, err := ssh.NewCertSigner(hostCert, private)
+	require.NoError(t, err)
+	config.AddHostKey(certSigner)
+	caLine := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(caSigner.PublicKey())))
+
 	go func() {
 		for {
 			nConn, err := listener.Accept()
@@ -141,7 +162,7 @@ func TestSSHCopy(t *testing.T) {
 
 			app := &cli.App{
 				Commands: []*cli.Command{
-					Command(stubPasswordReader{Password: testPass}),
+					Command(stubPasswordReader{Password: testPass}, strings.NewReader("yes\n")),
 				},
 			}
 
@@ -172,6 +193,154 @@ func TestSSHCopy(t *testing.T) {
 			}
 		})
 	}
+
+	// Host key was trusted on first use and added to default known_hosts
+	knownHostsBytes, err := ioutil.ReadFile(filepath.Join(sshDir, "known_hosts"))
+	require.NoError(t, err)
+	knownHostsLine := knownhosts.Line([]string{"localhost:" + port}, private.PublicKey()) + "\n"
+	require.Equal(t, knownHostsLine, string(knownHostsBytes))
+
+	otherKey, err := utils.GeneratePrivateKey(1024)
+	require.NoError(t, err)
+	otherPub, err := ssh.NewPublicKey(&otherKey.PublicKey)
+	require.NoError(t, err)
+
+	otherTypeKey, err := utils.GenerateEd25519PrivateKey()
+	require.NoError(t, err)
+	otherTypePub, err := ssh.NewPublicKey(otherTypeKey.Public())
+	require.NoError(t, err)
+
+	hostKeyCases := []struct {
+		name       string
+		knownHosts string
+		strict     string
+		input      string
+		expected   string
+		shouldErr  bool
+	}{
+		{
+			name:      "unknown host with strict checking",
+			strict:    "yes",
+			shouldErr: true,
+		},
+		{
+			name:       "known host with strict checking",
+			knownHosts: knownHostsLine,
+			strict:     "yes",
+			expected:   knownHostsLine,
+		},
+		{
+			name:     "unknown host is accepted with accept-new",
+			strict:   "accept-new",
+			expected: knownHostsLine,
+		},
+		{
+			name:       "unknown host is added without new line at end of file",
+			knownHosts: "# comment",
+			strict:     "no",
+			expected:   "# comment\n" + knownHostsLine,
+		},
+		{
+			name:      "unknown host is rejected by user",
+			input:     "no\n",
+			shouldErr: true,
+		},
+		{
+			name:      "unknown host without answer",
+			shouldErr: true,
+		},
+		{
+			name:     "unknown host is accepted with fingerprint",
+			input:    "maybe\n" + ssh.FingerprintSHA256(private.PublicKey()) + "\n",
+			expected: knownHostsLine,
+		},
+		{
+			name:       "changed host key",
+			knownHosts: knownhosts.Line([]string{"localhost:" + port}, otherPub) + "\n",
+			strict:     "no",
+			shouldErr:  true,
+		},
+		{
+			name:       "changed host key type",
+			knownHosts: knownhosts.Line([]string{"localhost:" + port}, otherTypePub) + "\n",
+			strict:     "no",
+			shouldErr:  true,
+		},
+		{
+			name:       "revoked host key",
+			knownHosts: "@revoked * " + strings.TrimPrefix(knownHostsLine, "[localhost]:"+port+" "),
+			strict:     "no",
+			shouldErr:  true,
+		},
+		{
+			name:       "host certificate signed by trusted CA",
+			knownHosts: "@cert-authority [localhost]:" + port + " " + caLine + "\n",
+			strict:     "yes",
+			expected:   "@cert-authority [localhost]:" + port + " " + caLine + "\n",
+		},
+		{
+			name:       "unknown host key with CA of other hosts",
+			knownHosts: "@cert-authority *.example.com " + caLine + "\n",
+			strict:     "accept-new",
+			expected:   "@cert-authority *.example.com " + caLine + "\n" + knownHostsLine,
+		},
+		{
+			name:       "CA of host excluded by negated pattern",
+			knownHosts: "@cert-authority [*]:" + port + ",![localhost]:" + port + " " + caLine + "\n",
+			strict:     "accept-new",
+			expected:   "@cert-authority [*]:" + port + ",![localhost]:" + port + " " + caLine + "\n" + knownHostsLine,
+		},
+		{
+			name:      "unsupported strict host key checking",
+			strict:    "sometimes",
+			shouldErr: true,
+		},
+	}
+
+	for _, tC := range hostKeyCases {
+		t.Run(tC.name, func(t *testing.T) {
+			knownHostsPath := filepath.Join(t.TempDir(), "known_hosts")
+			if tC.knownHosts != "" {
+				require.NoError(t, os.WriteFile(knownHostsPath, []byte(tC.knownHosts), 0o600))
+			}
+
+			testArgs := []string{execName, CmdSSHCopy, "--port", port, "--pubkey", pubFile.Name(), "--known-hosts", knownHostsPath}
+			if tC.strict != "" {
+				testArgs = append(testArgs, "--strict-host-key-checking", tC.strict)
+			}
+			testArgs = append(testArgs, fmt.Sprintf("%s@localhost", testUser))
+
+			// Password must not be asked if host key is not trusted
+			reader := &countingPasswordReader{Password: testPass}
+			app := &cli.App{
+				Commands: []*cli.Command{
+					Command(reader, strings.NewReader(tC.input)),
+				},
+			}
+
+			if tC.shouldErr {
+				require.Error(t, app.Run(testArgs))
+				require.Zero(t, reader.calls)
+
+				data, err := os.ReadFile(knownHostsPath)
+				if tC.knownHosts == "" {
+					require.ErrorIs(t, err, os.ErrNotExist)
+				} else {
+					require.NoError(t, err)
+					require.Equal(t, tC.knownHosts, string(data))
+				}
+				return
+			}
+
+			require.NoError(t, app.Run(testArgs))
+			require.Equal(t, 1, reader.calls)
+			defer os.RemoveAll(".ssh")
+
+			data, err := os.ReadFile(knownHostsPath)
+			require.NoError(t, err)
+			require.Equal(t, tC.expected, string(data))
+		})
+	}
 }
 
 // handleClient handles an SFTP connection
@@ -239,3 +408,14 @@ type stubPasswordReader struct {
 func (pr stubPasswordReader) ReadPassword() (string, error) {
 	return pr.Password, nil
 }
+
+// countingPasswordReader counts how many times password is asked
+type countingPasswordReader struct {
+	Password string
+	calls    int
+}
+
+func (pr *countingPasswordReader) ReadPassword() (string, error) {
+	pr.calls++
+	return pr.Password, nil
+}
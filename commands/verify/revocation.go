@@ -0,0 +1,295 @@
+package verify
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/yakuter/gossl/pkg/probe"
+	"github.com/yakuter/gossl/pkg/utils"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/crypto/ocsp"
+)
+
+// maxResponseSize limits size of fetched CRLs and OCSP responses
+const maxResponseSize = 10 << 20
+
+// revocationChecker checks revocation status of certificates in verified
+// chains with CRLs and OCSP responders
+type revocationChecker struct {
+	client  *http.Client
+	crls    []*pkix.CertificateList
+	crlDP   bool
+	ocsp    bool
+	ocspURL string
+	now     time.Time
+	fetched map[string]*pkix.CertificateList
+	checked map[string]bool
+}
+
+// newRevocationChecker returns a checker configured with flags,
+// nil if no revocation check is requested
+func newRevocationChecker(c *cli.Context) (*revocationChecker, error) {
+	if !c.IsSet(flagCRL) && !c.Bool(flagCRLDP) && !c.Bool(flagOCSP) && !c.IsSet(flagOCSPURL) {
+		return nil, nil
+	}
+
+	rc := &revocationChecker{
+		client:  &http.Client{Timeout: c.Duration(probe.FlagTimeout)},
+		crlDP:   c.Bool(flagCRLDP),
+		ocsp:    c.Bool(flagOCSP) || c.IsSet(flagOCSPURL),
+		ocspURL: c.String(flagOCSPURL),
+		now:     time.Now(),
+		fetched: map[string]*pkix.CertificateList{},
+		checked: map[string]bool{},
+	}
+
+	for _, src := range c.StringSlice(flagCRL) {
+		crl, err := rc.loadCRL(src)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load CRL %s: %w", src, err)
+		}
+		rc.crls = append(rc.crls, crl)
+	}
+
+	return rc, nil
+}
+
+// check checks every certificate in chains except trusted roots.
+// Status of the leaf must be known, intermediates are checked if their
+// issuer publishes a CRL or an OCSP responder.
+func (rc *revocationChecker) check(chains [][]*x509.Certificate) error {
+	if rc == nil {
+		return nil
+	}
+
+	for _, chain := range chains {
+		for i := 0; i < len(chain)-1; i++ {
+			cert, issuer := chain[i], chain[i+1]
+
+			key := string(cert.Raw) + string(issuer.Raw)
+			if rc.checked[key] {
+				continue
+			}
+
+			if len(rc.crls) > 0 || rc.crlDP {
+				if err := rc.checkCRL(cert, issuer, i == 0); err != nil {
+					return err
+				}
+			}
+
+			if rc.ocsp {
+				if err := rc.checkOCSP(cert, issuer, i == 0); err != nil {
+					return err
+				}
+			}
+
+			rc.checked[key] = true
+		}
+	}
+
+	return nil
+}
+
+// checkCRL checks cert with supplied CRLs signed by issuer and
+// CRLs in its distribution points if crldp flag is set
+func (rc *revocationChecker) checkCRL(cert, issuer *x509.Certificate, leaf bool) error {
+	var crls []*pkix.CertificateList
+	for _, crl := range rc.crls {
+		if issuer.CheckCRLSignature(crl) == nil {
+			crls = append(crls, crl)
+		}
+	}
+
+	if rc.crlDP {
+		for _, dp := range cert.CRLDistributionPoints {
+			crl, err := rc.loadCRL(dp)
+			if err != nil {
+				return unknownStatus(cert, fmt.Errorf("failed to fetch CRL %s: %w", dp, err))
+			}
+			if err = issuer.CheckCRLSignature(crl); err != nil {
+				return unknownStatus(cert, fmt.Errorf("CRL %s is not signed by issuer: %w", dp, err))
+			}
+			crls = append(crls, crl)
+		}
+	}
+
+	if len(crls) == 0 {
+		if leaf {
+			return unknownStatus(cert, fmt.Errorf("no CRL of issuer %q", issuer.Subject))
+		}
+		return nil
+	}
+
+	for _, crl := range crls {
+		if crl.HasExpired(rc.now) {
+			return unknownStatus(cert, fmt.Errorf("CRL of issuer %q expired at %s",
+				issuer.Subject, crl.TBSCertList.NextUpdate.UTC().Format(time.RFC3339)))
+		}
+
+		for _, entry := range crl.TBSCertList.RevokedCertificates {
+			if entry.SerialNumber.Cmp(cert.SerialNumber) != 0 {
+				continue
+			}
+
+			reason := ""
+			if code, ok := utils.CRLReason(entry); ok {
+				reason = " (" + utils.CRLReasonName(code) + ")"
+			}
+			return &probe.VerificationError{
+				Check: probe.CheckRevoked,
+				Err: fmt.Errorf("certificate %q is revoked at %s%s in CRL",
+					cert.Subject, entry.RevocationTime.UTC().Format(time.RFC3339), reason),
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkOCSP asks revocation status of cert to OCSP responder in its AIA extension,
+// or to the responder in ocspurl flag for the leaf
+func (rc *revocationChecker) checkOCSP(cert, issuer *x509.Certificate, leaf bool) error {
+	server := ""
+	if len(cert.OCSPServer) > 0 {
+		server = cert.OCSPServer[0]
+	}
+	if leaf && rc.ocspURL != "" {
+		server = rc.ocspURL
+	}
+
+	if server == "" {
+		if leaf {
+			return unknownStatus(cert, errors.New("no OCSP responder"))
+		}
+		return nil
+	}
+
+	req, err := ocsp.CreateRequest(cert, issuer, nil)
+	if err != nil {
+		return unknownStatus(cert, err)
+	}
+
+	body, err := rc.fetch(http.MethodPost, server, req)
+	if err != nil {
+		return unknownStatus(cert, fmt.Errorf("OCSP request to %s failed: %w", server, err))
+	}
+
+	// Response must be signed by issuer or by a responder certificate issued by it
+	resp, err := ocsp.ParseResponseForCert(body, cert, issuer)
+	if err != nil {
+		return unknownStatus(cert, fmt.Errorf("invalid OCSP response from %s: %w", server, err))
+	}
+
+	// Delegated responder must be authorized for OCSP signing (RFC 6960 4.2.2.2)
+	if err = checkResponder(resp.Certificate, rc.now); err != nil {
+		return unknownStatus(cert, fmt.Errorf("invalid OCSP response from %s: %w", server, err))
+	}
+
+	if !resp.NextUpdate.IsZero() && rc.now.After(resp.NextUpdate) {
+		return unknownStatus(cert, fmt.Errorf("OCSP response from %s expired at %s",
+			server, resp.NextUpdate.UTC().Format(time.RFC3339)))
+	}
+
+	switch resp.Status {
+	case ocsp.Good:
+		return nil
+	case ocsp.Revoked:
+		reason := ""
+		if resp.RevocationReason != ocsp.Unspecified {
+			reason = " (" + utils.CRLReasonName(resp.RevocationReason) + ")"
+		}
+		return &probe.VerificationError{
+			Check: probe.CheckRevoked,
+			Err: fmt.Errorf("certificate %q is revoked at %s%s by OCSP responder",
+				cert.Subject, resp.RevokedAt.UTC().Format(time.RFC3339), reason),
+		}
+	default:
+		return unknownStatus(cert, fmt.Errorf("OCSP responder %s does not know the certificate", server))
+	}
+}
+
+// checkResponder checks that delegated responder cert, if any, has OCSP signing
+// extended key usage and is valid at now
+func checkResponder(cert *x509.Certificate, now time.Time) error {
+	if cert == nil {
+		return nil
+	}
+
+	ocspSigning := false
+	for _, usage := range cert.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageOCSPSigning {
+			ocspSigning = true
+		}
+	}
+	if !ocspSigning {
+		return fmt.Errorf("responder certificate %q does not have OCSP signing extended key usage", cert.Subject)
+	}
+
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return fmt.Errorf("responder certificate %q is not valid at %s", cert.Subject, now.UTC().Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// loadCRL reads CRL from a file or fetches it from an HTTP URL
+func (rc *revocationChecker) loadCRL(src string) (*pkix.CertificateList, error) {
+	if !strings.HasPrefix(src, "http://") && !strings.HasPrefix(src, "https://") {
+		return utils.CRLFromFile(src)
+	}
+
+	if crl, ok := rc.fetched[src]; ok {
+		return crl, nil
+	}
+
+	data, err := rc.fetch(http.MethodGet, src, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	crl, err := utils.ParseCRL(data)
+	if err != nil {
+		return nil, err
+	}
+	rc.fetched[src] = crl
+
+	return crl, nil
+}
+
+// fetch sends a GET request, or a POST request with an OCSP request body
+func (rc *revocationChecker) fetch(method, url string, body []byte) ([]byte, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if method == http.MethodPost {
+		req.Header.Set("Content-Type", "application/ocsp-request")
+	}
+
+	resp, err := rc.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+}
+
+func unknownStatus(cert *x509.Certificate, err error) error {
+	return &probe.VerificationError{
+		Check: probe.CheckRevocation,
+		Err:   fmt.Errorf("certificate %q: %w", cert.Subject, err),
+	}
+}
@@ -5,10 +5,15 @@ import (
 	"crypto/x509"
 	"errors"
 	"fmt"
+	"io"
 	"log"
-	"net/http"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
+	"time"
 
+	"github.com/yakuter/gossl/pkg/probe"
 	"github.com/yakuter/gossl/pkg/utils"
 
 	"github.com/urfave/cli/v2"
@@ -17,10 +22,19 @@ import (
 const (
 	CmdVerify = "verify"
 
-	flagCAFile   = "cafile"
-	flagCertFile = "certfile"
-	flagDNS      = "dns"
-	flagURL      = "url"
+	flagCAFile    = "cafile"
+	flagCertFile  = "certfile"
+	flagUntrusted = "untrusted"
+	flagDNS       = "dns"
+	flagURL       = "url"
+	flagHTTP      = "http"
+	flagCRL       = "crl"
+	flagCRLDP     = "crldp"
+	flagOCSP      = "ocsp"
+	flagOCSPURL   = "ocspurl"
+
+	// clientAuthWait is the maximum wait for rejection of client certificate
+	clientAuthWait = time.Second
 )
 
 func Command() *cli.Command {
@@ -36,15 +50,21 @@ func Command() *cli.Command {
 }
 
 func Flags() []cli.Flag {
-	return []cli.Flag{
+	flags := []cli.Flag{
 		&cli.StringFlag{
 			Name:     flagCAFile,
-			Usage:    "CA file path (required)",
+			Usage:    "CA file or directory path (required)",
 			Required: true,
 		},
 		&cli.StringFlag{
 			Name:     flagCertFile,
-			Usage:    "Cert file path to verify with CA (optional)",
+			Usage:    "Cert file path to verify with CA, may be followed by intermediate CA certs (optional)",
+			Required: false,
+		},
+		&cli.StringSliceFlag{
+			Name:     flagUntrusted,
+			Aliases:  []string{"intermediates"},
+			Usage:    "Intermediate CA certs file or directory path, can be repeated (optional)",
 			Required: false,
 		},
 		&cli.StringFlag{
@@ -54,10 +74,37 @@ func Flags() []cli.Flag {
 		},
 		&cli.StringFlag{
 			Name:     flagURL,
-			Usage:    "URL to verify with CA (optional)",
+			Usage:    "URL or host:port of TLS server to verify with CA (optional)",
+			Required: false,
+		},
+		&cli.BoolFlag{
+			Name:     flagHTTP,
+			Usage:    "Send an HTTP GET request to https URL instead of only a TLS handshake (optional)",
+			Required: false,
+		},
+		&cli.StringSliceFlag{
+			Name:     flagCRL,
+			Usage:    "CRL file or HTTP URL to check revocation of verified chain with, can be repeated (optional)",
+			Required: false,
+		},
+		&cli.BoolFlag{
+			Name:     flagCRLDP,
+			Usage:    "Fetch CRLs from CRL distribution points of certificates to check revocation (optional)",
+			Required: false,
+		},
+		&cli.BoolFlag{
+			Name:     flagOCSP,
+			Usage:    "Check revocation with OCSP responders in AIA extension of certificates (optional)",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     flagOCSPURL,
+			Usage:    "OCSP responder URL to check the leaf certificate with instead of the one in certificate, implies ocsp (optional)",
 			Required: false,
 		},
 	}
+
+	return append(flags, probe.Flags()...)
 }
 
 func Action(c *cli.Context) error {
@@ -70,6 +117,10 @@ func Action(c *cli.Context) error {
 		return errors.New("DNS flag is only allowed to be used with certfile flag")
 	}
 
+	if c.Bool(flagHTTP) && c.IsSet(probe.FlagStartTLS) {
+		return errors.New("HTTP flag is not allowed to be used with starttls flag")
+	}
+
 	// Generate new cert pool with CA file
 	roots, err := rootCAs(c.String(flagCAFile))
 	if err != nil {
@@ -77,37 +128,79 @@ func Action(c *cli.Context) error {
 		return err
 	}
 
+	// Revocation is checked after chains are built
+	revocation, err := newRevocationChecker(c)
+	if err != nil {
+		log.Printf("Failed to set revocation check error: %v", err)
+		return err
+	}
+
 	// Verify cert file
 	if c.IsSet(flagCertFile) {
-		cert, err := utils.CertFromFile(c.String(flagCertFile))
+		certs, err := utils.CertsFromFile(c.String(flagCertFile))
 		if err != nil {
 			log.Printf("Failed to get cert from file %s CAs error: %v", c.String(flagCertFile), err)
 			return err
 		}
 
-		if err = verifyCertWithCA(c, cert, roots); err != nil {
+		// Certificates following the first one are intermediate CAs
+		intermediates, err := intermediateCAs(c.StringSlice(flagUntrusted))
+		if err != nil {
+			log.Printf("Failed to get intermediate CAs error: %v", err)
+			return err
+		}
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+
+		chains, err := verifyCertWithCA(certs[0], roots, intermediates, c.String(flagDNS))
+		if err != nil {
 			log.Printf("Failed to verify CA and cert error: %v", err)
 			return err
 		}
+
+		if err = revocation.check(chains); err != nil {
+			log.Printf("Failed to check revocation of cert error: %v", err)
+			return err
+		}
+		printChains(c.App.Writer, chains)
 	}
 
 	// Verify URL
 	if c.IsSet(flagURL) {
-		if err = verifyURLWithCA(c, c.String(flagURL), roots); err != nil {
+		opts, err := probe.OptionsFromContext(c, utils.StdinPasswordReader{Prompt: "Enter pass phrase: "})
+		if err != nil {
+			log.Printf("Failed to get connection options error: %v", err)
+			return err
+		}
+
+		verifyURL := verifyTLSWithCA
+		if c.Bool(flagHTTP) {
+			verifyURL = verifyHTTPWithCA
+		}
+
+		chains, err := verifyURL(c.String(flagURL), roots, opts)
+		if err != nil {
 			log.Printf("Failed to verify CA and URL error: %v", err)
 			return err
 		}
+
+		if err = revocation.check(chains); err != nil {
+			log.Printf("Failed to check revocation of URL certificate error: %v", err)
+			return err
+		}
+		printChains(c.App.Writer, chains)
 	}
 
 	log.Printf("Certificate verification succeeds")
 	return nil
 }
 
-func rootCAs(caFilePath string) (*x509.CertPool, error) {
-	// Read CA file
-	caFileBytes, err := os.ReadFile(caFilePath)
+func rootCAs(caPath string) (*x509.CertPool, error) {
+	// Read CA file or directory
+	certs, err := certsFromPath(caPath)
 	if err != nil {
-		log.Printf("Failed to read CA file %q error: %v", caFilePath, err)
+		log.Printf("Failed to read CA from %q error: %v", caPath, err)
 		return nil, err
 	}
 
@@ -115,51 +208,195 @@ func rootCAs(caFilePath string) (*x509.CertPool, error) {
 	roots := x509.NewCertPool()
 
 	// Append CA to cert pool
-	if ok := roots.AppendCertsFromPEM(caFileBytes); !ok {
-		err = fmt.Errorf("Failed to append CA from %q to cert pool", caFilePath)
-		log.Printf("%v", err)
-		return nil, err
+	for _, cert := range certs {
+		roots.AddCert(cert)
 	}
 
 	return roots, nil
 }
 
-func verifyCertWithCA(c *cli.Context, cert *x509.Certificate, roots *x509.CertPool) error {
+// intermediateCAs returns a cert pool of untrusted intermediate CAs in files or directories
+func intermediateCAs(paths []string) (*x509.CertPool, error) {
+	intermediates := x509.NewCertPool()
+	for _, path := range paths {
+		certs, err := certsFromPath(path)
+		if err != nil {
+			log.Printf("Failed to read intermediate CA from %q error: %v", path, err)
+			return nil, err
+		}
+
+		for _, cert := range certs {
+			intermediates.AddCert(cert)
+		}
+	}
+
+	return intermediates, nil
+}
+
+// certsFromPath reads certificates from a file, or from all files in a directory.
+// Files in directory which do not contain certificates are skipped.
+func certsFromPath(path string) ([]*x509.Certificate, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return utils.CertsFromFile(path)
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var certs []*x509.Certificate
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		fileCerts, err := utils.CertsFromFile(filepath.Join(path, entry.Name()))
+		if err != nil {
+			continue
+		}
+		certs = append(certs, fileCerts...)
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate found in directory %q", path)
+	}
+
+	return certs, nil
+}
+
+// printChains prints subjects of verified certificate chains from leaf to root
+func printChains(w io.Writer, chains [][]*x509.Certificate) {
+	for i, chain := range chains {
+		fmt.Fprintf(w, "Chain %d:\n", i+1)
+		for depth, cert := range chain {
+			fmt.Fprintf(w, "  %d: %s\n", depth, cert.Subject)
+		}
+	}
+}
+
+// verifyCertWithCA verifies cert and dnsName if not empty, error tells which check failed
+func verifyCertWithCA(cert *x509.Certificate, roots, intermediates *x509.CertPool, dnsName string) ([][]*x509.Certificate, error) {
 	// Set verification options
 	opts := x509.VerifyOptions{
 		Roots:         roots,
-		Intermediates: x509.NewCertPool(),
+		Intermediates: intermediates,
+		DNSName:       dnsName,
+	}
+
+	// Verify certificate with verification options
+	chains, err := cert.Verify(opts)
+	if err != nil {
+		err = probe.ClassifyError(err)
+		log.Printf("Failed to verify certificate error: %v", err)
+		return nil, err
 	}
 
-	// Add dns flag as DNSName if set
-	if c.IsSet(flagDNS) {
-		opts.DNSName = c.String(flagDNS)
+	return chains, nil
+}
+
+// verifyTLSWithCA verifies certificates sent by TLS server in handshake.
+// Nothing is sent to server after the handshake.
+func verifyTLSWithCA(uri string, roots *x509.CertPool, opts *probe.Options) ([][]*x509.Certificate, error) {
+	addr, err := opts.HostPort(uri)
+	if err != nil {
+		log.Printf("Failed to parse URL %s error: %v", uri, err)
+		return nil, err
 	}
 
-	// Verify certificate with verification options
-	if _, err := cert.Verify(opts); err != nil {
+	// Verification is done after handshake to report which check failed
+	config := opts.TLSConfig(&tls.Config{InsecureSkipVerify: true}, addr)
+
+	// Server may reject client certificate after handshake in TLS 1.3
+	clientAuthRequested := false
+	config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		clientAuthRequested = true
+		if len(config.Certificates) > 0 {
+			return &config.Certificates[0], nil
+		}
+		return &tls.Certificate{}, nil
+	}
+
+	conn, err := opts.Dial(addr, config)
+	if err != nil {
+		log.Printf("Failed to connect %s error: %v", addr, err)
+		return nil, err
+	}
+	defer conn.Close()
+
+	if clientAuthRequested && conn.ConnectionState().Version == tls.VersionTLS13 {
+		if err = checkClientAuth(conn, opts.Timeout); err != nil {
+			log.Printf("Client certificate rejected by %s error: %v", addr, err)
+			return nil, err
+		}
+	}
+
+	chains, err := probe.VerifyPeer(conn.ConnectionState().PeerCertificates, roots, config.ServerName)
+	if err != nil {
 		log.Printf("Failed to verify certificate error: %v", err)
+		return nil, err
+	}
+
+	return chains, nil
+}
+
+// checkClientAuth waits for an alert of server rejecting client certificate
+func checkClientAuth(conn *tls.Conn, timeout time.Duration) error {
+	if timeout <= 0 || timeout > clientAuthWait {
+		timeout = clientAuthWait
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
 		return err
 	}
 
-	return nil
+	// Server waiting for the client or sending data means handshake is accepted
+	_, err := conn.Read(make([]byte, 1))
+	var netErr net.Error
+	if err == nil || errors.Is(err, io.EOF) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return nil
+	}
+
+	return err
 }
 
-func verifyURLWithCA(c *cli.Context, url string, roots *x509.CertPool) error {
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			RootCAs:            roots,
-			InsecureSkipVerify: false,
-		},
+// verifyHTTPWithCA sends a GET request to https uri
+func verifyHTTPWithCA(uri string, roots *x509.CertPool, opts *probe.Options) ([][]*x509.Certificate, error) {
+	// Plain HTTP URLs have no certificate chain to verify
+	u, err := url.Parse(uri)
+	if err != nil {
+		log.Printf("Failed to parse URL %s error: %v", uri, err)
+		return nil, err
+	}
+	if u.Scheme != "https" {
+		err = fmt.Errorf("URL %s must use https scheme with http flag", uri)
+		log.Printf("%v", err)
+		return nil, err
 	}
 
-	client := &http.Client{Transport: tr}
+	client := opts.HTTPClient(&tls.Config{
+		RootCAs:            roots,
+		InsecureSkipVerify: false,
+	})
 
-	_, err := client.Get(url)
+	resp, err := client.Get(uri)
 	if err != nil {
-		log.Printf("Failed to send Get request to URL %s error: %v", url, err)
-		return err
+		err = probe.ClassifyError(err)
+		log.Printf("Failed to send Get request to URL %s error: %v", uri, err)
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	return nil
+	// Redirects may end at a plain HTTP URL
+	if resp.TLS == nil {
+		err = fmt.Errorf("response of URL %s is not received over TLS", uri)
+		log.Printf("%v", err)
+		return nil, err
+	}
+
+	return resp.TLS.VerifiedChains, nil
 }
@@ -1,17 +1,32 @@
 package verify_test
 
 import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/rand"
 	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"fmt"
+	"io"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/yakuter/gossl/commands/generate"
 	"github.com/yakuter/gossl/commands/verify"
+	"github.com/yakuter/gossl/pkg/utils"
 
 	"github.com/stretchr/testify/require"
 	"github.com/urfave/cli/v2"
+	"golang.org/x/crypto/ocsp"
 )
 
 func TestVerify(t *testing.T) {
@@ -115,8 +130,18 @@ func TestVerifyURL(t *testing.T) {
 		caCert2    = "../../testdata/ca-cert-2.pem"
 	)
 
+	// Plain HTTP server has no certificate to verify
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "hello!")
+	}))
+	defer plain.Close()
+
 	ts := httptest.NewUnstartedServer(
 		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/plain" {
+				http.Redirect(w, r, plain.URL, http.StatusFound)
+				return
+			}
 			fmt.Fprintln(w, "hello!")
 		}),
 	)
@@ -143,6 +168,8 @@ func TestVerifyURL(t *testing.T) {
 	testCases := []struct {
 		name      string
 		cafile    string
+		url       string
+		http      bool
 		shouldErr bool
 	}{
 		{
@@ -155,16 +182,274 @@ func TestVerifyURL(t *testing.T) {
 			cafile:    caCert2,
 			shouldErr: true,
 		},
+		{
+			name:      "valid cert and ca with http request",
+			cafile:    caCert,
+			http:      true,
+			shouldErr: false,
+		},
+		{
+			name:      "bad certificate with http request",
+			cafile:    caCert2,
+			http:      true,
+			shouldErr: true,
+		},
+		{
+			name:      "plain http URL with http request",
+			cafile:    caCert,
+			url:       plain.URL,
+			http:      true,
+			shouldErr: true,
+		},
+		{
+			name:      "redirect to plain http URL with http request",
+			cafile:    caCert,
+			url:       ts.URL + "/plain",
+			http:      true,
+			shouldErr: true,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.name, func(t *testing.T) {
+			url := ts.URL
+			if tC.url != "" {
+				url = tC.url
+			}
+
+			testArgs := []string{
+				execName, verify.CmdVerify,
+				"--cafile", tC.cafile,
+				"--url", url,
+			}
+			if tC.http {
+				testArgs = append(testArgs, "--http")
+			}
+
+			if tC.shouldErr {
+				require.Error(t, app.Run(testArgs))
+			} else {
+				require.NoError(t, app.Run(testArgs))
+			}
+		})
+	}
+}
+
+func TestVerifyIntermediate(t *testing.T) {
+	execName, err := os.Executable()
+	require.NoError(t, err)
+
+	// Root CA, intermediate CA and a leaf signed by intermediate CA
+	pkiDir := t.TempDir()
+	var out bytes.Buffer
+	app := &cli.App{
+		Writer: &out,
+		Commands: []*cli.Command{
+			generate.Command(),
+			verify.Command(),
+		},
+	}
+	require.NoError(t, app.Run([]string{execName, generate.CmdGenerate,
+		"--out", pkiDir, "--type", "ecdsa", "--intermediate", "--leaf", "api.example.com"}))
+
+	// Directories of root and intermediate CAs
+	caDir := filepath.Join(t.TempDir(), "roots")
+	intermediateDir := filepath.Join(t.TempDir(), "intermediates")
+	for dir, file := range map[string]string{caDir: "ca-cert.pem", intermediateDir: "intermediate-cert.pem"} {
+		require.NoError(t, os.Mkdir(dir, 0o700))
+		data, err := os.ReadFile(filepath.Join(pkiDir, file))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, file), data, 0o600))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "README"), []byte("not a cert"), 0o600))
+	}
+
+	var (
+		caFile           = filepath.Join(pkiDir, "ca-cert.pem")
+		leafFile         = filepath.Join(pkiDir, "api.example.com-cert.pem")
+		chainFile        = filepath.Join(pkiDir, "api.example.com-chain.pem")
+		intermediateFile = filepath.Join(pkiDir, "intermediate-cert.pem")
+	)
+
+	testCases := []struct {
+		name      string
+		cafile    string
+		certfile  string
+		untrusted []string
+		dns       string
+		shouldErr bool
+	}{
+		{
+			name:     "leaf with intermediate in cert file",
+			cafile:   caFile,
+			certfile: chainFile,
+			dns:      "api.example.com",
+		},
+		{
+			name:      "leaf with untrusted intermediate file",
+			cafile:    caFile,
+			certfile:  leafFile,
+			untrusted: []string{intermediateFile},
+			dns:       "api.example.com",
+		},
+		{
+			name:      "leaf with untrusted intermediate directory",
+			cafile:    caDir,
+			certfile:  leafFile,
+			untrusted: []string{intermediateDir},
+		},
+		{
+			name:      "leaf without intermediate error",
+			cafile:    caFile,
+			certfile:  leafFile,
+			dns:       "api.example.com",
+			shouldErr: true,
+		},
+		{
+			name:      "intermediate as root error",
+			cafile:    caFile,
+			certfile:  leafFile,
+			untrusted: []string{caFile},
+			shouldErr: true,
+		},
+		{
+			name:      "missing untrusted file error",
+			cafile:    caFile,
+			certfile:  leafFile,
+			untrusted: []string{"wrong-file"},
+			shouldErr: true,
+		},
+		{
+			name:      "dns error",
+			cafile:    caFile,
+			certfile:  chainFile,
+			dns:       "wrong.example.com",
+			shouldErr: true,
+		},
 	}
 
 	for _, tC := range testCases {
 		t.Run(tC.name, func(t *testing.T) {
+			out.Reset()
 			testArgs := []string{
 				execName, verify.CmdVerify,
 				"--cafile", tC.cafile,
-				"--url", ts.URL,
+				"--certfile", tC.certfile,
+			}
+			if tC.dns != "" {
+				testArgs = append(testArgs, "--dns", tC.dns)
+			}
+			for _, untrusted := range tC.untrusted {
+				testArgs = append(testArgs, "--untrusted", untrusted)
 			}
 
+			if tC.shouldErr {
+				require.Error(t, app.Run(testArgs))
+				return
+			}
+			require.NoError(t, app.Run(testArgs))
+
+			// Chain is printed from leaf to root
+			require.Equal(t, "Chain 1:\n"+
+				"  0: CN=api.example.com\n"+
+				"  1: CN=GoSSL Root CA Intermediate\n"+
+				"  2: CN=GoSSL Root CA\n", out.String())
+		})
+	}
+}
+
+func TestVerifyURLOptions(t *testing.T) {
+	execName, err := os.Executable()
+	require.NoError(t, err)
+
+	// Server and client certificates signed by the same CA
+	pkiDir := t.TempDir()
+	app := &cli.App{
+		Commands: []*cli.Command{
+			generate.Command(),
+			verify.Command(),
+		},
+	}
+	require.NoError(t, app.Run([]string{execName, generate.CmdGenerate,
+		"--out", pkiDir, "--type", "ecdsa", "--leaf", "api.example.com", "--leaf", "client"}))
+
+	var (
+		caFile     = filepath.Join(pkiDir, "ca-cert.pem")
+		clientCert = filepath.Join(pkiDir, "client-cert.pem")
+		clientKey  = filepath.Join(pkiDir, "client-key.pem")
+	)
+
+	serverCert, err := tls.LoadX509KeyPair(filepath.Join(pkiDir, "api.example.com-cert.pem"), filepath.Join(pkiDir, "api.example.com-key.pem"))
+	require.NoError(t, err)
+	ca, err := os.ReadFile(caFile)
+	require.NoError(t, err)
+	clientCAs := x509.NewCertPool()
+	require.True(t, clientCAs.AppendCertsFromPEM(ca))
+
+	// Server requires client certificate and TLS 1.3
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+		MinVersion:   tls.VersionTLS13,
+	}
+	ts.StartTLS()
+	defer ts.Close()
+
+	port := ts.Listener.Addr().(*net.TCPAddr).Port
+	mtls := []string{"--client-cert", clientCert, "--client-key", clientKey}
+
+	testCases := []struct {
+		name      string
+		url       string
+		args      []string
+		shouldErr bool
+	}{
+		{
+			name: "connect to IP with host name of URL",
+			url:  fmt.Sprintf("https://api.example.com:%d", port),
+			args: append([]string{"--connect", ts.Listener.Addr().String()}, mtls...),
+		},
+		{
+			name: "server name of IP URL",
+			url:  ts.URL,
+			args: append([]string{"--servername", "api.example.com", "--tls-min", "1.3", "--timeout", "5s"}, mtls...),
+		},
+		{
+			name:      "host name mismatch error",
+			url:       ts.URL,
+			args:      mtls,
+			shouldErr: true,
+		},
+		{
+			name:      "missing client certificate error",
+			url:       ts.URL,
+			args:      []string{"--servername", "api.example.com"},
+			shouldErr: true,
+		},
+		{
+			name:      "maximum TLS version error",
+			url:       ts.URL,
+			args:      append([]string{"--servername", "api.example.com", "--tls-max", "1.2"}, mtls...),
+			shouldErr: true,
+		},
+		{
+			name:      "unsupported TLS version error",
+			url:       ts.URL,
+			args:      append([]string{"--servername", "api.example.com", "--tls-min", "1.4"}, mtls...),
+			shouldErr: true,
+		},
+		{
+			name:      "client certificate without key error",
+			url:       ts.URL,
+			args:      []string{"--servername", "api.example.com", "--client-cert", clientCert},
+			shouldErr: true,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.name, func(t *testing.T) {
+			testArgs := append([]string{execName, verify.CmdVerify, "--cafile", caFile, "--url", tC.url}, tC.args...)
 			if tC.shouldErr {
 				require.Error(t, app.Run(testArgs))
 			} else {
@@ -173,3 +458,547 @@ func TestVerifyURL(t *testing.T) {
 		})
 	}
 }
+
+func TestVerifyStartTLS(t *testing.T) {
+	execName, err := os.Executable()
+	require.NoError(t, err)
+
+	cert, err := tls.LoadX509KeyPair("../../testdata/server-cert.pem", "../../testdata/server-key.pem")
+	require.NoError(t, err)
+
+	// Fake SMTP server upgrading to TLS after STARTTLS command
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				fmt.Fprintf(conn, "220 mail.example.com ESMTP\r\n")
+				_, _ = r.ReadString('\n')
+				fmt.Fprintf(conn, "250-mail.example.com\r\n250 STARTTLS\r\n")
+				_, _ = r.ReadString('\n')
+				fmt.Fprintf(conn, "220 Ready to start TLS\r\n")
+				_ = tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{cert}}).Handshake()
+			}()
+		}
+	}()
+
+	var out bytes.Buffer
+	app := &cli.App{
+		Writer: &out,
+		Commands: []*cli.Command{
+			verify.Command(),
+		},
+	}
+
+	testCases := []struct {
+		name      string
+		cafile    string
+		starttls  string
+		shouldErr bool
+	}{
+		{
+			name:     "valid cert and ca",
+			cafile:   "../../testdata/ca-cert.pem",
+			starttls: "smtp",
+		},
+		{
+			name:      "bad certificate",
+			cafile:    "../../testdata/ca-cert-2.pem",
+			starttls:  "smtp",
+			shouldErr: true,
+		},
+		{
+			name:      "unsupported protocol error",
+			cafile:    "../../testdata/ca-cert.pem",
+			starttls:  "telnet",
+			shouldErr: true,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.name, func(t *testing.T) {
+			out.Reset()
+			testArgs := []string{
+				execName, verify.CmdVerify,
+				"--cafile", tC.cafile,
+				"--url", ln.Addr().String(),
+				"--starttls", tC.starttls,
+				"--timeout", "2s",
+			}
+
+			if tC.shouldErr {
+				require.Error(t, app.Run(testArgs))
+				return
+			}
+			require.NoError(t, app.Run(testArgs))
+			require.Contains(t, out.String(), "Chain 1:\n  0: ")
+		})
+	}
+
+	t.Run("http flag error before client key is read", func(t *testing.T) {
+		missing := filepath.Join(t.TempDir(), "missing.pem")
+		err := app.Run([]string{
+			execName, verify.CmdVerify,
+			"--cafile", "../../testdata/ca-cert.pem",
+			"--url", ln.Addr().String(),
+			"--starttls", "smtp",
+			"--http",
+			"--client-cert", missing,
+			"--client-key", missing,
+		})
+		require.ErrorContains(t, err, "starttls")
+	})
+}
+
+func TestVerifyFailures(t *testing.T) {
+	execName, err := os.Executable()
+	require.NoError(t, err)
+
+	pkiDir := t.TempDir()
+	var out bytes.Buffer
+	app := &cli.App{
+		Writer: &out,
+		Commands: []*cli.Command{
+			generate.Command(),
+			verify.Command(),
+		},
+	}
+	require.NoError(t, app.Run([]string{execName, generate.CmdGenerate, "--out", pkiDir, "--type", "ecdsa"}))
+
+	caFile := filepath.Join(pkiDir, "ca-cert.pem")
+	caCert, err := utils.CertFromFile(caFile)
+	require.NoError(t, err)
+	caKey, err := utils.PrivateKeyFromFile(filepath.Join(pkiDir, "ca-key.pem"))
+	require.NoError(t, err)
+
+	// newServer serves a leaf certificate signed by CA and returns host:port of server
+	newServer := func(t *testing.T, template *x509.Certificate) string {
+		key, err := utils.GenerateKey(utils.KeyTypeECDSA, 0, "P-256")
+		require.NoError(t, err)
+
+		template.SerialNumber = big.NewInt(time.Now().UnixNano())
+		template.Subject = pkix.Name{CommonName: "leaf"}
+		if template.NotBefore.IsZero() {
+			template.NotBefore = time.Now().Add(-time.Hour)
+			template.NotAfter = time.Now().Add(time.Hour)
+		}
+		if template.ExtKeyUsage == nil {
+			template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, caCert, key.Public(), caKey)
+		require.NoError(t, err)
+
+		ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+			Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { ln.Close() })
+
+		go func() {
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				_ = conn.(*tls.Conn).Handshake()
+				conn.Close()
+			}
+		}()
+
+		return ln.Addr().String()
+	}
+
+	localhost := []net.IP{net.ParseIP("127.0.0.1")}
+
+	testCases := []struct {
+		name     string
+		template *x509.Certificate
+		cafile   string
+		check    string
+	}{
+		{
+			name:     "valid certificate",
+			template: &x509.Certificate{IPAddresses: localhost},
+			cafile:   caFile,
+		},
+		{
+			name:     "unknown authority",
+			template: &x509.Certificate{IPAddresses: localhost},
+			cafile:   "../../testdata/ca-cert.pem",
+			check:    "unknown authority",
+		},
+		{
+			name:     "hostname mismatch",
+			template: &x509.Certificate{DNSNames: []string{"api.example.com"}},
+			cafile:   caFile,
+			check:    "hostname mismatch",
+		},
+		{
+			name: "expired",
+			template: &x509.Certificate{
+				IPAddresses: localhost,
+				NotBefore:   time.Now().Add(-48 * time.Hour),
+				NotAfter:    time.Now().Add(-24 * time.Hour),
+			},
+			cafile: caFile,
+			check:  "expired",
+		},
+		{
+			name: "not yet valid",
+			template: &x509.Certificate{
+				IPAddresses: localhost,
+				NotBefore:   time.Now().Add(24 * time.Hour),
+				NotAfter:    time.Now().Add(48 * time.Hour),
+			},
+			cafile: caFile,
+			check:  "not yet valid",
+		},
+		{
+			name: "wrong extended key usage",
+			template: &x509.Certificate{
+				IPAddresses: localhost,
+				ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+			},
+			cafile: caFile,
+			check:  "wrong extended key usage",
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.name, func(t *testing.T) {
+			out.Reset()
+			addr := newServer(t, tC.template)
+
+			err := app.Run([]string{execName, verify.CmdVerify, "--cafile", tC.cafile, "--url", addr})
+			if tC.check == "" {
+				require.NoError(t, err)
+				require.Equal(t, "Chain 1:\n  0: CN=leaf\n  1: CN=GoSSL Root CA\n", out.String())
+				return
+			}
+			require.Error(t, err)
+			require.True(t, strings.HasPrefix(err.Error(), tC.check+": "), err.Error())
+		})
+	}
+}
+
+func TestVerifyRevocation(t *testing.T) {
+	execName, err := os.Executable()
+	require.NoError(t, err)
+
+	// State of CRL and OCSP endpoints, changed by test cases
+	var (
+		revoked     = map[string]bool{}
+		expiredCRL  bool
+		wrongSigner bool
+		responder   string
+	)
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	newKey := func() crypto.Signer {
+		key, err := utils.GenerateKey(utils.KeyTypeECDSA, 0, "P-256")
+		require.NoError(t, err)
+		return key
+	}
+	newCert := func(template, parent *x509.Certificate, pub crypto.PublicKey, parentKey crypto.Signer) *x509.Certificate {
+		template.NotBefore = time.Now().Add(-time.Hour)
+		template.NotAfter = time.Now().Add(time.Hour)
+		if parent == nil {
+			parent = template
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, parentKey)
+		require.NoError(t, err)
+		cert, err := x509.ParseCertificate(der)
+		require.NoError(t, err)
+		return cert
+	}
+
+	rootKey, intKey, leafKey, otherKey := newKey(), newKey(), newKey(), newKey()
+	root := newCert(&x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Root"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}, nil, rootKey.Public(), rootKey)
+	intermediate := newCert(&x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "Intermediate"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		CRLDistributionPoints: []string{srv.URL + "/root.crl"},
+		OCSPServer:            []string{srv.URL + "/ocsp"},
+	}, root, intKey.Public(), rootKey)
+	leaf := newCert(&x509.Certificate{
+		SerialNumber:          big.NewInt(3),
+		Subject:               pkix.Name{CommonName: "leaf"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		CRLDistributionPoints: []string{srv.URL + "/intermediate.crl"},
+		OCSPServer:            []string{srv.URL + "/ocsp"},
+	}, intermediate, leafKey.Public(), intKey)
+
+	// issuers of certificates by serial number
+	type signer struct {
+		cert *x509.Certificate
+		key  crypto.Signer
+	}
+	issuers := map[string]signer{
+		"2": {root, rootKey},
+		"3": {intermediate, intKey},
+	}
+
+	// Delegated responders of intermediate signing the response of leaf
+	responderKey := newKey()
+	expiredDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(5),
+		Subject:      pkix.Name{CommonName: "expired responder"},
+		NotBefore:    time.Now().Add(-2 * time.Hour),
+		NotAfter:     time.Now().Add(-time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning},
+	}, intermediate, responderKey.Public(), intKey)
+	require.NoError(t, err)
+	expiredResponder, err := x509.ParseCertificate(expiredDER)
+	require.NoError(t, err)
+	responders := map[string]signer{
+		"delegated": {newCert(&x509.Certificate{
+			SerialNumber: big.NewInt(4),
+			Subject:      pkix.Name{CommonName: "responder"},
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning},
+		}, intermediate, responderKey.Public(), intKey), responderKey},
+		"expired": {expiredResponder, responderKey},
+		"leaf":    {leaf, leafKey},
+	}
+
+	createCRL := func(issuer *x509.Certificate, key crypto.Signer, serial *big.Int) []byte {
+		var entries []pkix.RevokedCertificate
+		if revoked[serial.String()] {
+			entries = append(entries, pkix.RevokedCertificate{SerialNumber: serial, RevocationTime: time.Now()})
+		}
+
+		nextUpdate := time.Now().Add(time.Hour)
+		if expiredCRL {
+			nextUpdate = time.Now().Add(-time.Minute)
+		}
+
+		der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+			RevokedCertificates: entries,
+			Number:              big.NewInt(1),
+			ThisUpdate:          time.Now().Add(-time.Hour),
+			NextUpdate:          nextUpdate,
+		}, issuer, key)
+		require.NoError(t, err)
+		return der
+	}
+
+	mux.HandleFunc("/root.crl", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(createCRL(root, rootKey, intermediate.SerialNumber))
+	})
+	mux.HandleFunc("/intermediate.crl", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(createCRL(intermediate, intKey, leaf.SerialNumber))
+	})
+	mux.HandleFunc("/ocsp", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		req, err := ocsp.ParseRequest(body)
+		require.NoError(t, err)
+
+		issuer := issuers[req.SerialNumber.String()]
+		template := ocsp.Response{
+			Status:       ocsp.Good,
+			SerialNumber: req.SerialNumber,
+			ThisUpdate:   time.Now().Add(-time.Hour),
+			NextUpdate:   time.Now().Add(time.Hour),
+		}
+		if revoked[req.SerialNumber.String()] {
+			template.Status = ocsp.Revoked
+			template.RevokedAt = time.Now().Add(-time.Minute)
+			template.RevocationReason = ocsp.KeyCompromise
+		}
+
+		key := issuer.key
+		if wrongSigner {
+			key = otherKey
+		}
+		responderCert := issuer.cert
+		if r, ok := responders[responder]; ok && req.SerialNumber.Cmp(leaf.SerialNumber) == 0 {
+			template.Certificate = r.cert
+			responderCert, key = r.cert, r.key
+			// Leaf forges a good status of itself
+			if responder == "leaf" {
+				template.Status = ocsp.Good
+			}
+		}
+		resp, err := ocsp.CreateResponse(issuer.cert, responderCert, template, key)
+		require.NoError(t, err)
+		_, _ = w.Write(resp)
+	})
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "root.pem")
+	require.NoError(t, os.WriteFile(caFile, utils.CertToPEM(root.Raw), 0o600))
+	certFile := filepath.Join(dir, "leaf.pem")
+	require.NoError(t, os.WriteFile(certFile, append(utils.CertToPEM(leaf.Raw), utils.CertToPEM(intermediate.Raw)...), 0o600))
+
+	// CRL of intermediate revoking the leaf and CRL of another CA
+	revoked["3"] = true
+	leafCRLFile := filepath.Join(dir, "intermediate.crl")
+	require.NoError(t, os.WriteFile(leafCRLFile, utils.CRLToPEM(createCRL(intermediate, intKey, leaf.SerialNumber)), 0o600))
+	revoked["3"] = false
+	otherCRLFile := filepath.Join(dir, "other.crl")
+	require.NoError(t, os.WriteFile(otherCRLFile, utils.CRLToPEM(createCRL(root, rootKey, leaf.SerialNumber)), 0o600))
+
+	// TLS server sending leaf and intermediate
+	tlsSrv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	tlsSrv.TLS = &tls.Config{Certificates: []tls.Certificate{{
+		Certificate: [][]byte{leaf.Raw, intermediate.Raw},
+		PrivateKey:  leafKey,
+	}}}
+	tlsSrv.StartTLS()
+	defer tlsSrv.Close()
+
+	testCases := []struct {
+		name        string
+		args        []string
+		revoked     []string
+		expiredCRL  bool
+		wrongSigner bool
+		responder   string
+		check       string
+	}{
+		{
+			name: "CRL distribution points",
+			args: []string{"--crldp"},
+		},
+		{
+			name:    "leaf revoked in CRL distribution point",
+			args:    []string{"--crldp"},
+			revoked: []string{"3"},
+			check:   "revoked",
+		},
+		{
+			name:    "intermediate revoked in CRL distribution point",
+			args:    []string{"--crldp"},
+			revoked: []string{"2"},
+			check:   "revoked",
+		},
+		{
+			name:       "expired CRL",
+			args:       []string{"--crldp"},
+			expiredCRL: true,
+			check:      "revocation status unknown",
+		},
+		{
+			name:  "leaf revoked in CRL file",
+			args:  []string{"--crl", leafCRLFile},
+			check: "revoked",
+		},
+		{
+			name:  "CRL file of another issuer",
+			args:  []string{"--crl", otherCRLFile},
+			check: "revocation status unknown",
+		},
+		{
+			name: "CRL URL",
+			args: []string{"--crl", srv.URL + "/intermediate.crl"},
+		},
+		{
+			name: "OCSP",
+			args: []string{"--ocsp"},
+		},
+		{
+			name:    "leaf revoked by OCSP",
+			args:    []string{"--ocsp"},
+			revoked: []string{"3"},
+			check:   "revoked",
+		},
+		{
+			name:    "intermediate revoked by OCSP",
+			args:    []string{"--ocsp"},
+			revoked: []string{"2"},
+			check:   "revoked",
+		},
+		{
+			name:        "OCSP response signed by another key",
+			args:        []string{"--ocsp"},
+			wrongSigner: true,
+			check:       "revocation status unknown",
+		},
+		{
+			name:      "OCSP response signed by delegated responder",
+			args:      []string{"--ocsp"},
+			responder: "delegated",
+		},
+		{
+			name:      "OCSP response signed by expired delegated responder",
+			args:      []string{"--ocsp"},
+			responder: "expired",
+			check:     "revocation status unknown",
+		},
+		{
+			name:      "revoked leaf signing its own OCSP response",
+			args:      []string{"--ocsp"},
+			revoked:   []string{"3"},
+			responder: "leaf",
+			check:     "revocation status unknown",
+		},
+		{
+			name:  "OCSP responder URL",
+			args:  []string{"--ocspurl", srv.URL + "/missing"},
+			check: "revocation status unknown",
+		},
+	}
+
+	var out bytes.Buffer
+	app := &cli.App{
+		Writer: &out,
+		Commands: []*cli.Command{
+			verify.Command(),
+		},
+	}
+
+	for _, tC := range testCases {
+		for _, target := range []string{"certfile", "url"} {
+			t.Run(tC.name+" with "+target, func(t *testing.T) {
+				revoked = map[string]bool{}
+				for _, serial := range tC.revoked {
+					revoked[serial] = true
+				}
+				expiredCRL, wrongSigner, responder = tC.expiredCRL, tC.wrongSigner, tC.responder
+
+				testArgs := []string{execName, verify.CmdVerify, "--cafile", caFile}
+				if target == "certfile" {
+					testArgs = append(testArgs, "--certfile", certFile)
+				} else {
+					testArgs = append(testArgs, "--url", tlsSrv.Listener.Addr().String())
+				}
+				testArgs = append(testArgs, tC.args...)
+
+				err := app.Run(testArgs)
+				if tC.check == "" {
+					require.NoError(t, err)
+					return
+				}
+				require.Error(t, err)
+				require.True(t, strings.HasPrefix(err.Error(), tC.check+": "), err.Error())
+			})
+		}
+	}
+
+	t.Run("missing CRL file error", func(t *testing.T) {
+		require.Error(t, app.Run([]string{execName, verify.CmdVerify, "--cafile", caFile, "--certfile", certFile,
+			"--crl", filepath.Join(dir, "missing.crl")}))
+	})
+}
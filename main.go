@@ -5,13 +5,20 @@ import (
 	"log"
 	"os"
 
+	"github.com/yakuter/gossl/commands/check_expiry"
+	"github.com/yakuter/gossl/commands/convert"
+	"github.com/yakuter/gossl/commands/crl"
+	"github.com/yakuter/gossl/commands/generate"
 	"github.com/yakuter/gossl/commands/help"
 	"github.com/yakuter/gossl/commands/info"
 	"github.com/yakuter/gossl/commands/key"
+	"github.com/yakuter/gossl/commands/ocsp"
 	"github.com/yakuter/gossl/commands/req"
+	"github.com/yakuter/gossl/commands/scan"
 	"github.com/yakuter/gossl/commands/ssh"
 	"github.com/yakuter/gossl/commands/ssh_copy"
 	"github.com/yakuter/gossl/commands/verify"
+	"github.com/yakuter/gossl/pkg/utils"
 
 	"github.com/urfave/cli/v2"
 )
@@ -36,9 +43,15 @@ func Commands(reader io.Reader) []*cli.Command {
 		help.Command(),
 		key.Command(),
 		req.Command(reader),
+		generate.Command(),
+		crl.Command(),
+		ocsp.Command(),
+		convert.Command(utils.StdinPasswordReader{Prompt: "Enter pass phrase: "}),
 		info.Command(),
 		verify.Command(),
-		ssh.Command(),
-		ssh_copy.Command(ssh_copy.StdinPasswordReader{}),
+		check_expiry.Command(),
+		scan.Command(reader),
+		ssh.Command(utils.StdinPasswordReader{}),
+		ssh_copy.Command(utils.StdinPasswordReader{}, reader),
 	}
 }
@@ -0,0 +1,323 @@
+// Package probe contains connection options shared by commands connecting to TLS servers.
+package probe
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/yakuter/gossl/pkg/utils"
+
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	FlagServerName = "servername"
+	FlagConnect    = "connect"
+	FlagTimeout    = "timeout"
+	FlagClientCert = "client-cert"
+	FlagClientKey  = "client-key"
+	FlagTLSMin     = "tls-min"
+	FlagTLSMax     = "tls-max"
+	FlagStartTLS   = "starttls"
+
+	defaultTimeout = 10 * time.Second
+)
+
+// versions are TLS versions by name, tls.VersionName needs Go 1.21
+var versions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Flags returns flags of connection options
+func Flags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        FlagServerName,
+			Usage:       "Server name sent in SNI and verified in certificate (optional)",
+			DefaultText: "host of URL",
+			Required:    false,
+		},
+		&cli.StringFlag{
+			Name:        FlagConnect,
+			Usage:       "Address to connect instead of URL host, eg. a backend IP before DNS cutover (optional)",
+			DefaultText: "eg, 10.0.0.1:443",
+			Required:    false,
+		},
+		&cli.DurationFlag{
+			Name:     FlagTimeout,
+			Usage:    "Connection timeout (optional)",
+			Value:    defaultTimeout,
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:        FlagClientCert,
+			Usage:       "Client certificate file for mutual TLS, may be followed by intermediate CA certs (optional)",
+			DefaultText: "eg, client-cert.pem",
+			Required:    false,
+		},
+		&cli.StringFlag{
+			Name:        FlagClientKey,
+			Usage:       "Private key file of client certificate (optional)",
+			DefaultText: "eg, client-key.pem",
+			Required:    false,
+		},
+		&cli.StringFlag{
+			Name:        FlagTLSMin,
+			Usage:       "Minimum TLS version (1.0, 1.1, 1.2 or 1.3) (optional)",
+			DefaultText: "1.2",
+			Required:    false,
+		},
+		&cli.StringFlag{
+			Name:        FlagTLSMax,
+			Usage:       "Maximum TLS version (1.0, 1.1, 1.2 or 1.3) (optional)",
+			DefaultText: "1.3",
+			Required:    false,
+		},
+		&cli.StringFlag{
+			Name:     FlagStartTLS,
+			Usage:    "Upgrade plain connection before TLS handshake (" + strings.Join(StartTLSProtocols(), ", ") + ") (optional)",
+			Required: false,
+		},
+	}
+}
+
+// Options are connection options of a TLS server
+type Options struct {
+	ServerName   string
+	Connect      string
+	Timeout      time.Duration
+	Certificates []tls.Certificate
+	MinVersion   uint16
+	MaxVersion   uint16
+	StartTLS     string
+}
+
+// OptionsFromContext returns connection options from flags
+func OptionsFromContext(c *cli.Context, reader utils.PasswordReader) (*Options, error) {
+	opts := &Options{
+		ServerName: c.String(FlagServerName),
+		Connect:    c.String(FlagConnect),
+		Timeout:    c.Duration(FlagTimeout),
+		StartTLS:   c.String(FlagStartTLS),
+	}
+
+	if _, ok := startTLSProtocols[opts.StartTLS]; opts.StartTLS != "" && !ok {
+		return nil, fmt.Errorf("unsupported STARTTLS protocol %q", opts.StartTLS)
+	}
+
+	if c.IsSet(FlagClientCert) != c.IsSet(FlagClientKey) {
+		return nil, errors.New("client-cert and client-key flags must be used together")
+	}
+
+	if c.IsSet(FlagClientCert) {
+		cert, err := ClientCertificate(c.String(FlagClientCert), c.String(FlagClientKey), reader)
+		if err != nil {
+			log.Printf("Failed to load client certificate error: %v", err)
+			return nil, err
+		}
+		opts.Certificates = []tls.Certificate{cert}
+	}
+
+	var err error
+	if opts.MinVersion, err = ParseVersion(c.String(FlagTLSMin)); err != nil {
+		return nil, err
+	}
+	if opts.MaxVersion, err = ParseVersion(c.String(FlagTLSMax)); err != nil {
+		return nil, err
+	}
+	if opts.MinVersion != 0 && opts.MaxVersion != 0 && opts.MinVersion > opts.MaxVersion {
+		return nil, fmt.Errorf("minimum TLS version %s is greater than maximum TLS version %s",
+			c.String(FlagTLSMin), c.String(FlagTLSMax))
+	}
+
+	return opts, nil
+}
+
+// ClientCertificate returns certificate and private key used for mutual TLS.
+// Encrypted private keys are decrypted with the pass phrase from reader.
+func ClientCertificate(certPath, keyPath string, reader utils.PasswordReader) (tls.Certificate, error) {
+	certs, err := utils.CertsFromFile(certPath)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	key, err := utils.PrivateKeyFromFileWithPassword(keyPath, reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	cert := tls.Certificate{PrivateKey: key, Leaf: certs[0]}
+	for _, c := range certs {
+		cert.Certificate = append(cert.Certificate, c.Raw)
+	}
+
+	return cert, nil
+}
+
+// ParseVersion returns TLS version of name, eg. 1.2. Empty name is 0 which
+// means default version of crypto/tls.
+func ParseVersion(name string) (uint16, error) {
+	if name == "" {
+		return 0, nil
+	}
+
+	version, ok := versions[name]
+	if !ok {
+		return 0, fmt.Errorf("unsupported TLS version %q", name)
+	}
+
+	return version, nil
+}
+
+// VersionName returns name of TLS version, eg. TLS 1.2
+func VersionName(version uint16) string {
+	for name, v := range versions {
+		if v == version {
+			return "TLS " + name
+		}
+	}
+
+	return fmt.Sprintf("0x%04x", version)
+}
+
+// TLSConfig returns a copy of config with connection options applied.
+// Server name is host of addr if not set in config or options.
+func (o *Options) TLSConfig(config *tls.Config, addr string) *tls.Config {
+	config = o.apply(config)
+	if config.ServerName == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		config.ServerName = host
+	}
+
+	return config
+}
+
+// apply returns a copy of config with connection options applied
+func (o *Options) apply(config *tls.Config) *tls.Config {
+	config = config.Clone()
+	if config.ServerName == "" {
+		config.ServerName = o.ServerName
+	}
+	if len(o.Certificates) > 0 {
+		config.Certificates = o.Certificates
+	}
+	if o.MinVersion != 0 {
+		config.MinVersion = o.MinVersion
+	}
+	if o.MaxVersion != 0 {
+		config.MaxVersion = o.MaxVersion
+	}
+
+	return config
+}
+
+// HostPort returns host:port of uri. Default port is the port of STARTTLS
+// protocol if set, or 443.
+func (o *Options) HostPort(uri string) (string, error) {
+	// if no schema is used, parse with default scheme //
+	// to get the host name
+	if !strings.Contains(uri, "://") {
+		uri = "//" + uri
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("no host in %q", uri)
+	}
+
+	addr := u.Host
+	if u.Port() == "" {
+		port := "443"
+		if p, ok := startTLSProtocols[o.StartTLS]; ok {
+			port = p.port
+		}
+		addr = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	return addr, nil
+}
+
+// Address returns the address to connect, connect option overrides addr
+func (o *Options) Address(addr string) string {
+	if o.Connect != "" {
+		return o.Connect
+	}
+
+	return addr
+}
+
+// DialContext connects to addr, or to connect option if set, with timeout
+func (o *Options) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: o.Timeout}
+	return dialer.DialContext(ctx, network, o.Address(addr))
+}
+
+// Dial connects to TLS server at addr (host:port) and completes the handshake.
+// Plain connection is upgraded first if STARTTLS protocol is set.
+func (o *Options) Dial(addr string, config *tls.Config) (*tls.Conn, error) {
+	config = o.TLSConfig(config, addr)
+	if o.StartTLS == "" {
+		dialer := &net.Dialer{Timeout: o.Timeout}
+		return tls.DialWithDialer(dialer, "tcp", o.Address(addr), config)
+	}
+
+	conn, err := o.DialContext(context.Background(), "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Timeout applies to upgrade and handshake as a whole
+	if o.Timeout > 0 {
+		if err = conn.SetDeadline(time.Now().Add(o.Timeout)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	if err = StartTLS(conn, o.StartTLS, config.ServerName); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	tlsConn := tls.Client(conn, config)
+	if err = tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if err = conn.SetDeadline(time.Time{}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return tlsConn, nil
+}
+
+// HTTPClient returns an HTTP client using connection options and config.
+// Server name is host of URL if not set in config or options.
+func (o *Options) HTTPClient(config *tls.Config) *http.Client {
+	return &http.Client{
+		Timeout: o.Timeout,
+		Transport: &http.Transport{
+			DialContext:     o.DialContext,
+			TLSClientConfig: o.apply(config),
+		},
+	}
+}
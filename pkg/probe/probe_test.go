@@ -0,0 +1,59 @@
+package probe_test
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yakuter/gossl/pkg/probe"
+)
+
+func TestParseVersion(t *testing.T) {
+	testCases := []struct {
+		name      string
+		version   uint16
+		shouldErr bool
+	}{
+		{name: "", version: 0},
+		{name: "1.0", version: tls.VersionTLS10},
+		{name: "1.2", version: tls.VersionTLS12},
+		{name: "1.3", version: tls.VersionTLS13},
+		{name: "1.4", shouldErr: true},
+		{name: "TLS 1.2", shouldErr: true},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.name, func(t *testing.T) {
+			version, err := probe.ParseVersion(tC.name)
+			if tC.shouldErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tC.version, version)
+		})
+	}
+
+	require.Equal(t, "TLS 1.3", probe.VersionName(tls.VersionTLS13))
+	require.Equal(t, "0x0300", probe.VersionName(0x0300))
+}
+
+func TestTLSConfig(t *testing.T) {
+	opts := &probe.Options{
+		Connect:    "10.0.0.1:8443",
+		MinVersion: tls.VersionTLS12,
+	}
+
+	// Server name is host of address without connect option
+	config := opts.TLSConfig(&tls.Config{}, "api.example.com:443")
+	require.Equal(t, "api.example.com", config.ServerName)
+	require.Equal(t, uint16(tls.VersionTLS12), config.MinVersion)
+	require.Equal(t, "10.0.0.1:8443", opts.Address("api.example.com:443"))
+
+	// Server name option overrides host of address
+	opts.ServerName = "www.example.com"
+	require.Equal(t, "www.example.com", opts.TLSConfig(&tls.Config{}, "api.example.com:443").ServerName)
+
+	// Server name of config is kept
+	require.Equal(t, "example.com", opts.TLSConfig(&tls.Config{ServerName: "example.com"}, "api.example.com:443").ServerName)
+}
@@ -0,0 +1,388 @@
+package probe
+
+import (
+	"bufio"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+)
+
+const (
+	StartTLSSMTP     = "smtp"
+	StartTLSIMAP     = "imap"
+	StartTLSPOP3     = "pop3"
+	StartTLSFTP      = "ftp"
+	StartTLSLDAP     = "ldap"
+	StartTLSPostgres = "postgres"
+	StartTLSMySQL    = "mysql"
+	StartTLSXMPP     = "xmpp"
+)
+
+// startTLSProtocol upgrades a plain connection to be ready for TLS handshake
+type startTLSProtocol struct {
+	port    string
+	upgrade func(conn net.Conn, serverName string) error
+}
+
+var startTLSProtocols = map[string]startTLSProtocol{
+	StartTLSSMTP:     {port: "25", upgrade: startTLSSMTP},
+	StartTLSIMAP:     {port: "143", upgrade: startTLSIMAP},
+	StartTLSPOP3:     {port: "110", upgrade: startTLSPOP3},
+	StartTLSFTP:      {port: "21", upgrade: startTLSFTP},
+	StartTLSLDAP:     {port: "389", upgrade: startTLSLDAP},
+	StartTLSPostgres: {port: "5432", upgrade: startTLSPostgres},
+	StartTLSMySQL:    {port: "3306", upgrade: startTLSMySQL},
+	StartTLSXMPP:     {port: "5222", upgrade: startTLSXMPP},
+}
+
+// StartTLSProtocols returns names of supported STARTTLS protocols
+func StartTLSProtocols() []string {
+	names := make([]string, 0, len(startTLSProtocols))
+	for name := range startTLSProtocols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// StartTLS performs protocol specific upgrade on conn before TLS handshake,
+// like openssl s_client -starttls
+func StartTLS(conn net.Conn, protocol, serverName string) error {
+	p, ok := startTLSProtocols[protocol]
+	if !ok {
+		return fmt.Errorf("unsupported STARTTLS protocol %q", protocol)
+	}
+
+	if err := p.upgrade(conn, serverName); err != nil {
+		return fmt.Errorf("%s STARTTLS failed: %w", protocol, err)
+	}
+
+	return nil
+}
+
+// readReply reads a possibly multi-line reply of SMTP and FTP (eg, 250-... 250 ...)
+// and returns an error if its code is not the expected one
+func readReply(r *bufio.Reader, code string) error {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if len(line) < 4 || !strings.HasPrefix(line, code) {
+			return fmt.Errorf("unexpected reply %q, expected %s", line, code)
+		}
+		if line[3] == ' ' {
+			return nil
+		}
+	}
+}
+
+// readLine reads a line and returns an error if it does not start with prefix
+func readLine(r *bufio.Reader, prefix string) error {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+
+	line = strings.TrimRight(line, "\r\n")
+	if !strings.HasPrefix(line, prefix) {
+		return fmt.Errorf("unexpected reply %q, expected %s", line, prefix)
+	}
+
+	return nil
+}
+
+func startTLSSMTP(conn net.Conn, serverName string) error {
+	r := bufio.NewReader(conn)
+	if err := readReply(r, "220"); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(conn, "EHLO gossl\r\n"); err != nil {
+		return err
+	}
+	if err := readReply(r, "250"); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(conn, "STARTTLS\r\n"); err != nil {
+		return err
+	}
+
+	return readReply(r, "220")
+}
+
+func startTLSIMAP(conn net.Conn, serverName string) error {
+	r := bufio.NewReader(conn)
+	if err := readLine(r, "* OK"); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(conn, "a001 STARTTLS\r\n"); err != nil {
+		return err
+	}
+
+	// Untagged responses may precede the tagged one
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+
+		if strings.HasPrefix(line, "a001 ") {
+			if !strings.HasPrefix(line, "a001 OK") {
+				return fmt.Errorf("unexpected reply %q", strings.TrimRight(line, "\r\n"))
+			}
+			return nil
+		}
+	}
+}
+
+func startTLSPOP3(conn net.Conn, serverName string) error {
+	r := bufio.NewReader(conn)
+	if err := readLine(r, "+OK"); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(conn, "STLS\r\n"); err != nil {
+		return err
+	}
+
+	return readLine(r, "+OK")
+}
+
+func startTLSFTP(conn net.Conn, serverName string) error {
+	r := bufio.NewReader(conn)
+	if err := readReply(r, "220"); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(conn, "AUTH TLS\r\n"); err != nil {
+		return err
+	}
+
+	return readReply(r, "234")
+}
+
+// ldapStartTLSOID is the name of LDAP StartTLS extended operation (RFC 4511 4.14)
+const ldapStartTLSOID = "1.3.6.1.4.1.1466.20037"
+
+type ldapMessage struct {
+	MessageID  int
+	ProtocolOp asn1.RawValue
+}
+
+func startTLSLDAP(conn net.Conn, serverName string) error {
+	// ExtendedRequest ::= [APPLICATION 23] SEQUENCE { requestName [0] LDAPOID }
+	requestName, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, Bytes: []byte(ldapStartTLSOID)})
+	if err != nil {
+		return err
+	}
+	request, err := asn1.Marshal(ldapMessage{
+		MessageID:  1,
+		ProtocolOp: asn1.RawValue{Class: asn1.ClassApplication, Tag: 23, IsCompound: true, Bytes: requestName},
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err = conn.Write(request); err != nil {
+		return err
+	}
+
+	der, err := readBER(conn)
+	if err != nil {
+		return err
+	}
+
+	// ExtendedResponse ::= [APPLICATION 24] SEQUENCE { resultCode ENUMERATED, ... }
+	var resp ldapMessage
+	if _, err = asn1.Unmarshal(der, &resp); err != nil {
+		return err
+	}
+	if resp.ProtocolOp.Class != asn1.ClassApplication || resp.ProtocolOp.Tag != 24 {
+		return fmt.Errorf("unexpected LDAP response with tag %d", resp.ProtocolOp.Tag)
+	}
+
+	var resultCode asn1.Enumerated
+	if _, err = asn1.Unmarshal(resp.ProtocolOp.Bytes, &resultCode); err != nil {
+		return err
+	}
+	if resultCode != 0 {
+		return fmt.Errorf("LDAP result code %d", resultCode)
+	}
+
+	return nil
+}
+
+// maxBERLength limits the length of a BER element read from server
+const maxBERLength = 64 << 10
+
+// readBER reads a single BER encoded element with definite length
+func readBER(r io.Reader) ([]byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	length := int(header[1])
+	if length&0x80 != 0 {
+		n := length & 0x7f
+		if n == 0 || n > 4 {
+			return nil, errors.New("unsupported BER length")
+		}
+
+		lengthBytes := make([]byte, n)
+		if _, err := io.ReadFull(r, lengthBytes); err != nil {
+			return nil, err
+		}
+		header = append(header, lengthBytes...)
+
+		length = 0
+		for _, b := range lengthBytes {
+			length = length<<8 | int(b)
+		}
+	}
+	if length > maxBERLength {
+		return nil, fmt.Errorf("BER length %d is too large", length)
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+
+	return append(header, body...), nil
+}
+
+// postgresSSLRequest is the request code of SSLRequest message
+const postgresSSLRequest = 80877103
+
+func startTLSPostgres(conn net.Conn, serverName string) error {
+	request := make([]byte, 8)
+	binary.BigEndian.PutUint32(request[0:4], 8)
+	binary.BigEndian.PutUint32(request[4:8], postgresSSLRequest)
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+
+	resp := make([]byte, 1)
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return err
+	}
+	if resp[0] != 'S' {
+		return errors.New("server does not support SSL")
+	}
+
+	return nil
+}
+
+// MySQL capability flags
+const (
+	mysqlClientProtocol41       = 0x00000200
+	mysqlClientSSL              = 0x00000800
+	mysqlClientSecureConnection = 0x00008000
+)
+
+func startTLSMySQL(conn net.Conn, serverName string) error {
+	// Initial handshake packet
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	payload := make([]byte, int(header[0])|int(header[1])<<8|int(header[2])<<16)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return err
+	}
+
+	if len(payload) == 0 || payload[0] != 10 {
+		return errors.New("unsupported MySQL handshake protocol")
+	}
+
+	// Protocol version, null terminated server version, connection id (4),
+	// auth plugin data (8) and filler (1) are followed by capability flags (2)
+	end := 1
+	for end < len(payload) && payload[end] != 0 {
+		end++
+	}
+	offset := end + 1 + 4 + 8 + 1
+	if offset+2 > len(payload) {
+		return errors.New("short MySQL handshake packet")
+	}
+	if binary.LittleEndian.Uint16(payload[offset:])&mysqlClientSSL == 0 {
+		return errors.New("server does not support SSL")
+	}
+
+	// SSLRequest packet: capability flags (4), max packet size (4), character set (1) and reserved (23)
+	request := make([]byte, 4+32)
+	request[0] = 32
+	request[3] = 1
+	binary.LittleEndian.PutUint32(request[4:], mysqlClientProtocol41|mysqlClientSSL|mysqlClientSecureConnection)
+	binary.LittleEndian.PutUint32(request[8:], 1<<24)
+	request[12] = 0x21 // utf8_general_ci
+	_, err := conn.Write(request)
+
+	return err
+}
+
+const (
+	xmppStreamNS = "http://etherx.jabber.org/streams"
+	xmppTLSNS    = "urn:ietf:params:xml:ns:xmpp-tls"
+)
+
+func startTLSXMPP(conn net.Conn, serverName string) error {
+	_, err := fmt.Fprintf(conn, "<?xml version='1.0'?><stream:stream xmlns='jabber:client' "+
+		"xmlns:stream='%s' to='%s' version='1.0'>", xmppStreamNS, serverName)
+	if err != nil {
+		return err
+	}
+
+	// Stream features must contain starttls
+	dec := xml.NewDecoder(conn)
+	starttls := false
+	for done := false; !done; {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space == xmppTLSNS && t.Name.Local == "starttls" {
+				starttls = true
+			}
+		case xml.EndElement:
+			done = t.Name.Space == xmppStreamNS && t.Name.Local == "features"
+		}
+	}
+	if !starttls {
+		return errors.New("server does not offer STARTTLS")
+	}
+
+	if _, err = fmt.Fprintf(conn, "<starttls xmlns='%s'/>", xmppTLSNS); err != nil {
+		return err
+	}
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		if t, ok := tok.(xml.StartElement); ok && t.Name.Space == xmppTLSNS {
+			if t.Name.Local != "proceed" {
+				return fmt.Errorf("unexpected reply %q", t.Name.Local)
+			}
+			return nil
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package probe_test
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yakuter/gossl/pkg/probe"
+)
+
+// fakeServer accepts a single connection, runs upgrade and completes TLS handshake
+func fakeServer(t *testing.T, upgrade func(conn net.Conn, r *bufio.Reader) error) string {
+	t.Helper()
+
+	cert, err := tls.LoadX509KeyPair("../../testdata/server-cert.pem", "../../testdata/server-key.pem")
+	require.NoError(t, err)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+		if err := upgrade(conn, bufio.NewReader(conn)); err != nil {
+			return
+		}
+
+		tlsConn := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{cert}})
+		_ = tlsConn.Handshake()
+	}()
+
+	return ln.Addr().String()
+}
+
+// expect reads a line and returns an error if it is not the expected one
+func expect(r *bufio.Reader, want string) error {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if strings.TrimRight(line, "\r\n") != want {
+		return fmt.Errorf("unexpected line %q", line)
+	}
+
+	return nil
+}
+
+func TestStartTLS(t *testing.T) {
+	ca, err := os.ReadFile("../../testdata/ca-cert.pem")
+	require.NoError(t, err)
+	roots := x509.NewCertPool()
+	require.True(t, roots.AppendCertsFromPEM(ca))
+
+	testCases := []struct {
+		protocol  string
+		server    func(conn net.Conn, r *bufio.Reader) error
+		shouldErr bool
+	}{
+		{
+			protocol: probe.StartTLSSMTP,
+			server: func(conn net.Conn, r *bufio.Reader) error {
+				fmt.Fprintf(conn, "220-mail.example.com ESMTP\r\n220 ready\r\n")
+				if err := expect(r, "EHLO gossl"); err != nil {
+					return err
+				}
+				fmt.Fprintf(conn, "250-mail.example.com\r\n250-STARTTLS\r\n250 8BITMIME\r\n")
+				if err := expect(r, "STARTTLS"); err != nil {
+					return err
+				}
+				_, err := fmt.Fprintf(conn, "220 Go ahead\r\n")
+				return err
+			},
+		},
+		{
+			protocol: probe.StartTLSIMAP,
+			server: func(conn net.Conn, r *bufio.Reader) error {
+				fmt.Fprintf(conn, "* OK IMAP4rev1 ready\r\n")
+				if err := expect(r, "a001 STARTTLS"); err != nil {
+					return err
+				}
+				_, err := fmt.Fprintf(conn, "* CAPABILITY IMAP4rev1\r\na001 OK Begin TLS negotiation now\r\n")
+				return err
+			},
+		},
+		{
+			protocol: probe.StartTLSPOP3,
+			server: func(conn net.Conn, r *bufio.Reader) error {
+				fmt.Fprintf(conn, "+OK POP3 ready\r\n")
+				if err := expect(r, "STLS"); err != nil {
+					return err
+				}
+				_, err := fmt.Fprintf(conn, "+OK Begin TLS negotiation\r\n")
+				return err
+			},
+		},
+		{
+			protocol: probe.StartTLSFTP,
+			server: func(conn net.Conn, r *bufio.Reader) error {
+				fmt.Fprintf(conn, "220 FTP ready\r\n")
+				if err := expect(r, "AUTH TLS"); err != nil {
+					return err
+				}
+				_, err := fmt.Fprintf(conn, "234 AUTH TLS successful\r\n")
+				return err
+			},
+		},
+		{
+			protocol: probe.StartTLSLDAP,
+			server: func(conn net.Conn, r *bufio.Reader) error {
+				header := make([]byte, 2)
+				if _, err := io.ReadFull(r, header); err != nil {
+					return err
+				}
+				request := make([]byte, header[1])
+				if _, err := io.ReadFull(r, request); err != nil {
+					return err
+				}
+				if !bytes.Contains(request, []byte("1.3.6.1.4.1.1466.20037")) {
+					return fmt.Errorf("unexpected request %x", request)
+				}
+
+				// ExtendedResponse with success result code
+				_, err := conn.Write([]byte{0x30, 0x0c, 0x02, 0x01, 0x01, 0x78, 0x07, 0x0a, 0x01, 0x00, 0x04, 0x00, 0x04, 0x00})
+				return err
+			},
+		},
+		{
+			protocol: probe.StartTLSPostgres,
+			server: func(conn net.Conn, r *bufio.Reader) error {
+				request := make([]byte, 8)
+				if _, err := io.ReadFull(r, request); err != nil {
+					return err
+				}
+				if binary.BigEndian.Uint32(request[4:]) != 80877103 {
+					return fmt.Errorf("unexpected request %x", request)
+				}
+				_, err := conn.Write([]byte{'S'})
+				return err
+			},
+		},
+		{
+			protocol: probe.StartTLSMySQL,
+			server: func(conn net.Conn, r *bufio.Reader) error {
+				// Protocol version, server version, connection id, auth data, filler and capabilities
+				payload := append([]byte{10}, "8.0.34\x00"...)
+				payload = append(payload, 1, 0, 0, 0)
+				payload = append(payload, bytes.Repeat([]byte{'a'}, 8)...)
+				payload = append(payload, 0, 0x00, 0x8a, 0x21, 0x02, 0x00, 0xff, 0xff)
+				packet := append([]byte{byte(len(payload)), 0, 0, 0}, payload...)
+				if _, err := conn.Write(packet); err != nil {
+					return err
+				}
+
+				// Client hello follows SSLRequest without waiting, so read from conn unbuffered
+				request := make([]byte, 36)
+				if _, err := io.ReadFull(conn, request); err != nil {
+					return err
+				}
+				if binary.LittleEndian.Uint32(request[4:])&0x0800 == 0 {
+					return fmt.Errorf("unexpected request %x", request)
+				}
+				return nil
+			},
+		},
+		{
+			protocol: probe.StartTLSXMPP,
+			server: func(conn net.Conn, r *bufio.Reader) error {
+				dec := xml.NewDecoder(r)
+				tok, err := dec.Token()
+				for err == nil {
+					if el, ok := tok.(xml.StartElement); ok && el.Name.Local == "stream" {
+						break
+					}
+					tok, err = dec.Token()
+				}
+				if err != nil {
+					return err
+				}
+
+				fmt.Fprintf(conn, "<?xml version='1.0'?><stream:stream xmlns='jabber:client' "+
+					"xmlns:stream='http://etherx.jabber.org/streams' from='example.com' id='1' version='1.0'>"+
+					"<stream:features><starttls xmlns='urn:ietf:params:xml:ns:xmpp-tls'><required/></starttls>"+
+					"</stream:features>")
+
+				tok, err = dec.Token()
+				if err != nil {
+					return err
+				}
+				if el, ok := tok.(xml.StartElement); !ok || el.Name.Local != "starttls" {
+					return fmt.Errorf("unexpected token %v", tok)
+				}
+				_, err = fmt.Fprintf(conn, "<proceed xmlns='urn:ietf:params:xml:ns:xmpp-tls'/>")
+				return err
+			},
+		},
+		{
+			protocol: probe.StartTLSSMTP,
+			server: func(conn net.Conn, r *bufio.Reader) error {
+				fmt.Fprintf(conn, "220 ready\r\n")
+				if err := expect(r, "EHLO gossl"); err != nil {
+					return err
+				}
+				fmt.Fprintf(conn, "250 mail.example.com\r\n")
+				if err := expect(r, "STARTTLS"); err != nil {
+					return err
+				}
+				_, err := fmt.Fprintf(conn, "454 TLS not available\r\n")
+				return err
+			},
+			shouldErr: true,
+		},
+		{
+			protocol: probe.StartTLSPostgres,
+			server: func(conn net.Conn, r *bufio.Reader) error {
+				if _, err := io.ReadFull(r, make([]byte, 8)); err != nil {
+					return err
+				}
+				_, err := conn.Write([]byte{'N'})
+				return err
+			},
+			shouldErr: true,
+		},
+		{
+			protocol: probe.StartTLSPOP3,
+			server: func(conn net.Conn, r *bufio.Reader) error {
+				// Server never replies
+				_, err := r.ReadString('\n')
+				return err
+			},
+			shouldErr: true,
+		},
+	}
+
+	for _, tC := range testCases {
+		name := tC.protocol
+		if tC.shouldErr {
+			name += " error"
+		}
+
+		t.Run(name, func(t *testing.T) {
+			addr := fakeServer(t, tC.server)
+			opts := &probe.Options{StartTLS: tC.protocol, Timeout: time.Second}
+
+			conn, err := opts.Dial(addr, &tls.Config{RootCAs: roots})
+			if tC.shouldErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			defer conn.Close()
+
+			require.NotEmpty(t, conn.ConnectionState().VerifiedChains)
+		})
+	}
+
+	t.Run("LDAP response too large error", func(t *testing.T) {
+		client, server := net.Pipe()
+		defer client.Close()
+
+		go func() {
+			defer server.Close()
+			header := make([]byte, 2)
+			if _, err := io.ReadFull(server, header); err != nil {
+				return
+			}
+			if _, err := io.ReadFull(server, make([]byte, header[1])); err != nil {
+				return
+			}
+			// Response claims to be 2 GiB long
+			_, _ = server.Write([]byte{0x30, 0x84, 0x7f, 0xff, 0xff, 0xff})
+		}()
+
+		err := probe.StartTLS(client, probe.StartTLSLDAP, "example.com")
+		require.ErrorContains(t, err, "too large")
+	})
+
+	t.Run("unsupported protocol error", func(t *testing.T) {
+		require.Error(t, probe.StartTLS(nil, "telnet", "example.com"))
+	})
+}
+
+func TestHostPort(t *testing.T) {
+	testCases := []struct {
+		uri      string
+		starttls string
+		addr     string
+	}{
+		{uri: "example.com", addr: "example.com:443"},
+		{uri: "https://example.com:8443/path", addr: "example.com:8443"},
+		{uri: "mail.example.com", starttls: probe.StartTLSSMTP, addr: "mail.example.com:25"},
+		{uri: "mail.example.com:587", starttls: probe.StartTLSSMTP, addr: "mail.example.com:587"},
+		{uri: "db.example.com", starttls: probe.StartTLSPostgres, addr: "db.example.com:5432"},
+		{uri: "127.0.0.1:8443", addr: "127.0.0.1:8443"},
+		{uri: "[::1]", starttls: probe.StartTLSLDAP, addr: "[::1]:389"},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.uri, func(t *testing.T) {
+			opts := &probe.Options{StartTLS: tC.starttls}
+			addr, err := opts.HostPort(tC.uri)
+			require.NoError(t, err)
+			require.Equal(t, tC.addr, addr)
+		})
+	}
+}
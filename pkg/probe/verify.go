@@ -0,0 +1,101 @@
+package probe
+
+import (
+	"crypto/x509"
+	"errors"
+	"time"
+)
+
+// Failed checks of certificate verification
+const (
+	CheckUnknownAuthority = "unknown authority"
+	CheckHostname         = "hostname mismatch"
+	CheckExpired          = "expired"
+	CheckNotYetValid      = "not yet valid"
+	CheckExtKeyUsage      = "wrong extended key usage"
+	CheckNotCA            = "issuer is not a CA"
+	CheckNameConstraints  = "name constraints violated"
+	CheckPathLength       = "path length exceeded"
+	CheckInvalid          = "invalid certificate"
+	CheckRevoked          = "revoked"
+	CheckRevocation       = "revocation status unknown"
+)
+
+// VerificationError is a certificate verification error with the check that failed
+type VerificationError struct {
+	Check string
+	Err   error
+}
+
+func (e *VerificationError) Error() string {
+	return e.Check + ": " + e.Err.Error()
+}
+
+func (e *VerificationError) Unwrap() error {
+	return e.Err
+}
+
+// VerifyPeer verifies certificates sent by server, first one is the leaf and
+// the others are intermediates. Error tells which check failed.
+func VerifyPeer(certs []*x509.Certificate, roots *x509.CertPool, dnsName string) ([][]*x509.Certificate, error) {
+	if len(certs) == 0 {
+		return nil, errors.New("no certificate sent by server")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	chains, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		DNSName:       dnsName,
+	})
+	if err != nil {
+		return nil, ClassifyError(err)
+	}
+
+	return chains, nil
+}
+
+// ClassifyError returns err with the check that failed if err is a
+// certificate verification error, otherwise err itself
+func ClassifyError(err error) error {
+	var (
+		unknownAuthorityErr x509.UnknownAuthorityError
+		hostnameErr         x509.HostnameError
+		invalidErr          x509.CertificateInvalidError
+	)
+
+	switch {
+	case errors.As(err, &unknownAuthorityErr):
+		return &VerificationError{Check: CheckUnknownAuthority, Err: err}
+	case errors.As(err, &hostnameErr):
+		return &VerificationError{Check: CheckHostname, Err: err}
+	case errors.As(err, &invalidErr):
+		return &VerificationError{Check: invalidCheck(invalidErr), Err: err}
+	default:
+		return err
+	}
+}
+
+func invalidCheck(err x509.CertificateInvalidError) string {
+	switch err.Reason {
+	case x509.Expired:
+		if err.Cert != nil && time.Now().Before(err.Cert.NotBefore) {
+			return CheckNotYetValid
+		}
+		return CheckExpired
+	case x509.IncompatibleUsage:
+		return CheckExtKeyUsage
+	case x509.NotAuthorizedToSign:
+		return CheckNotCA
+	case x509.CANotAuthorizedForThisName:
+		return CheckNameConstraints
+	case x509.TooManyIntermediates:
+		return CheckPathLength
+	default:
+		return CheckInvalid
+	}
+}
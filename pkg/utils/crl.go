@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+)
+
+var (
+	oidExtensionCRLNumber  = asn1.ObjectIdentifier{2, 5, 29, 20}
+	oidExtensionReasonCode = asn1.ObjectIdentifier{2, 5, 29, 21}
+)
+
+// CRLReasons maps RFC 5280 revocation reason names to reason codes
+var CRLReasons = map[string]int{
+	"unspecified":          0,
+	"keyCompromise":        1,
+	"cACompromise":         2,
+	"affiliationChanged":   3,
+	"superseded":           4,
+	"cessationOfOperation": 5,
+	"certificateHold":      6,
+	"removeFromCRL":        8,
+	"privilegeWithdrawn":   9,
+	"aACompromise":         10,
+}
+
+// CRLReasonName returns RFC 5280 name of revocation reason code
+func CRLReasonName(code int) string {
+	for name, c := range CRLReasons {
+		if c == code {
+			return name
+		}
+	}
+
+	return fmt.Sprintf("unknown(%d)", code)
+}
+
+// CRLFromFile reads a PEM or DER encoded CRL
+func CRLFromFile(path string) (*pkix.CertificateList, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("Failed to read CRL file %q error: %v", path, err)
+		return nil, err
+	}
+
+	crl, err := ParseCRL(data)
+	if err != nil {
+		log.Printf("Failed to parse CRL from file %q error: %v", path, err)
+		return nil, err
+	}
+
+	return crl, nil
+}
+
+// ParseCRL parses a PEM or DER encoded CRL
+func ParseCRL(data []byte) (*pkix.CertificateList, error) {
+	if bytes.Contains(data, []byte("-----BEGIN")) {
+		block, _ := pem.Decode(data)
+		if block == nil || block.Type != "X509 CRL" {
+			return nil, errors.New("no CRL found in PEM data")
+		}
+		data = block.Bytes
+	}
+
+	return x509.ParseDERCRL(data)
+}
+
+// CRLToPEM returns DER encoded CRL in PEM format
+func CRLToPEM(crl []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  "X509 CRL",
+		Bytes: crl,
+	})
+}
+
+// CRLNumber returns CRL number extension of crl, nil if it has none
+func CRLNumber(crl *pkix.CertificateList) *big.Int {
+	for _, ext := range crl.TBSCertList.Extensions {
+		if !ext.Id.Equal(oidExtensionCRLNumber) {
+			continue
+		}
+
+		number := new(big.Int)
+		if _, err := asn1.Unmarshal(ext.Value, &number); err != nil {
+			return nil
+		}
+		return number
+	}
+
+	return nil
+}
+
+// CRLReason returns reason code of revoked certificate entry, false if it has none
+func CRLReason(rc pkix.RevokedCertificate) (int, bool) {
+	for _, ext := range rc.Extensions {
+		if !ext.Id.Equal(oidExtensionReasonCode) {
+			continue
+		}
+
+		var reason asn1.Enumerated
+		if _, err := asn1.Unmarshal(ext.Value, &reason); err != nil {
+			return 0, false
+		}
+		return int(reason), true
+	}
+
+	return 0, false
+}
+
+// CRLReasonExtension returns reason code extension of a revoked certificate entry
+func CRLReasonExtension(code int) (pkix.Extension, error) {
+	value, err := asn1.Marshal(asn1.Enumerated(code))
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+
+	return pkix.Extension{Id: oidExtensionReasonCode, Value: value}, nil
+}
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+)
+
+// PKCS#7 (RFC 2315) certificate bundles (.p7b) are degenerate SignedData
+// structures without content and signers, only carrying certificates and CRLs.
+var (
+	oidPKCS7Data       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidPKCS7SignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+)
+
+type pkcs7ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type pkcs7SignedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	ContentInfo      pkcs7ContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      asn1.RawValue
+}
+
+// EncodePKCS7 returns DER encoded PKCS#7 bundle of certificates and DER encoded CRLs
+func EncodePKCS7(certs []*x509.Certificate, crls [][]byte) ([]byte, error) {
+	emptySet := asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true}
+
+	sd := pkcs7SignedData{
+		Version:          1,
+		DigestAlgorithms: emptySet,
+		ContentInfo:      pkcs7ContentInfo{ContentType: oidPKCS7Data},
+		SignerInfos:      emptySet,
+	}
+
+	if len(certs) > 0 {
+		sd.Certificates = asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true}
+		for _, cert := range certs {
+			sd.Certificates.Bytes = append(sd.Certificates.Bytes, cert.Raw...)
+		}
+	}
+
+	if len(crls) > 0 {
+		sd.CRLs = asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 1, IsCompound: true}
+		for _, crl := range crls {
+			sd.CRLs.Bytes = append(sd.CRLs.Bytes, crl...)
+		}
+	}
+
+	content, err := asn1.Marshal(sd)
+	if err != nil {
+		return nil, err
+	}
+
+	// Raw values are marshaled as is, so explicit tag is added here
+	content, err = asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: content})
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(pkcs7ContentInfo{
+		ContentType: oidPKCS7SignedData,
+		Content:     asn1.RawValue{FullBytes: content},
+	})
+}
+
+// DecodePKCS7 returns certificates and DER encoded CRLs of a DER encoded PKCS#7 bundle
+func DecodePKCS7(der []byte) ([]*x509.Certificate, [][]byte, error) {
+	var ci pkcs7ContentInfo
+	rest, err := asn1.Unmarshal(der, &ci)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(rest) > 0 {
+		return nil, nil, errors.New("trailing data after PKCS#7")
+	}
+	if !ci.ContentType.Equal(oidPKCS7SignedData) {
+		return nil, nil, fmt.Errorf("unsupported PKCS#7 content type %s", ci.ContentType)
+	}
+
+	var sd pkcs7SignedData
+	if _, err = asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, nil, err
+	}
+
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var crls [][]byte
+	for rest := sd.CRLs.Bytes; len(rest) > 0; {
+		var crl asn1.RawValue
+		if rest, err = asn1.Unmarshal(rest, &crl); err != nil {
+			return nil, nil, err
+		}
+		crls = append(crls, crl.FullBytes)
+	}
+
+	return certs, crls, nil
+}
@@ -2,6 +2,11 @@ package utils
 
 import (
 	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -10,20 +15,105 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math/big"
 	"os"
+	"syscall"
 
+	"github.com/urfave/cli/v2"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
 )
 
-// GenerateSSHPublicKey take an rsa.PublicKey and return bytes
-// suitable for writing to .pub file in the format "ssh-rsa ..."
-func GenerateSSHPublicKey(rsaPubKey *rsa.PublicKey) ([]byte, error) {
-	sshPubKey, err := ssh.NewPublicKey(rsaPubKey)
+// Supported private key types
+const (
+	KeyTypeRSA     = "rsa"
+	KeyTypeECDSA   = "ecdsa"
+	KeyTypeEd25519 = "ed25519"
+)
+
+// curves maps supported curve names to elliptic curves
+var curves = map[string]elliptic.Curve{
+	"P-256": elliptic.P256(),
+	"P-384": elliptic.P384(),
+	"P-521": elliptic.P521(),
+}
+
+// GenerateSSHPublicKey takes an RSA, ECDSA or Ed25519 public key and returns bytes
+// suitable for writing to .pub file in the format "ssh-ed25519 AAAA... comment"
+func GenerateSSHPublicKey(pubKey crypto.PublicKey, comment string) ([]byte, error) {
+	sshPubKey, err := ssh.NewPublicKey(pubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	authorizedKey := ssh.MarshalAuthorizedKey(sshPubKey)
+	if comment == "" {
+		return authorizedKey, nil
+	}
+
+	return append(bytes.TrimSuffix(authorizedKey, []byte("\n")), []byte(" "+comment+"\n")...), nil
+}
+
+// SSHPrivateKeyToPEM encodes private key with comment in OpenSSH private key format.
+// Key is encrypted with bcrypt-pbkdf and AES-256-CTR if passphrase is not empty.
+func SSHPrivateKeyToPEM(key crypto.PrivateKey, comment string, passphrase []byte) ([]byte, error) {
+	var (
+		block *pem.Block
+		err   error
+	)
+	if len(passphrase) == 0 {
+		block, err = ssh.MarshalPrivateKey(key, comment)
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(key, comment, passphrase)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	return ssh.MarshalAuthorizedKey(sshPubKey), nil
+	return pem.EncodeToMemory(block), nil
+}
+
+// SSHPrivateKeyFromPEM parses an OpenSSH, PKCS#1, SEC1 or PKCS#8 private key.
+// It returns ssh.PassphraseMissingError if key is encrypted and passphrase is empty.
+func SSHPrivateKeyFromPEM(data, passphrase []byte) (crypto.Signer, error) {
+	var (
+		key interface{}
+		err error
+	)
+	if len(passphrase) == 0 {
+		key, err = ssh.ParseRawPrivateKey(data)
+	} else {
+		key, err = ssh.ParseRawPrivateKeyWithPassphrase(data, passphrase)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// OpenSSH Ed25519 keys are parsed as pointers
+	if edKey, ok := key.(*ed25519.PrivateKey); ok {
+		key = *edKey
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+
+	return signer, nil
+}
+
+// RandomSerial returns a random positive 128-bit certificate serial number
+func RandomSerial() (*big.Int, error) {
+	limit := new(big.Int).Lsh(big.NewInt(1), 128)
+	for {
+		serial, err := rand.Int(rand.Reader, limit)
+		if err != nil {
+			return nil, err
+		}
+		if serial.Sign() > 0 {
+			return serial, nil
+		}
+	}
 }
 
 // GeneratePrivateKey creates an RSA Private Key with provided bit size
@@ -40,6 +130,41 @@ func GeneratePrivateKey(bitSize int) (*rsa.PrivateKey, error) {
 	return privateKey, nil
 }
 
+// GenerateECDSAPrivateKey creates an ECDSA Private Key on provided curve (P-256, P-384 or P-521)
+func GenerateECDSAPrivateKey(curveName string) (*ecdsa.PrivateKey, error) {
+	curve, ok := curves[curveName]
+	if !ok {
+		return nil, fmt.Errorf("unsupported curve %q", curveName)
+	}
+
+	return ecdsa.GenerateKey(curve, rand.Reader)
+}
+
+// GenerateEd25519PrivateKey creates an Ed25519 Private Key
+func GenerateEd25519PrivateKey() (ed25519.PrivateKey, error) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return privateKey, nil
+}
+
+// GenerateKey creates a Private Key with provided type. Bit size is used
+// for RSA keys and curve name is used for ECDSA keys only.
+func GenerateKey(keyType string, bitSize int, curveName string) (crypto.Signer, error) {
+	switch keyType {
+	case KeyTypeRSA:
+		return GeneratePrivateKey(bitSize)
+	case KeyTypeECDSA:
+		return GenerateECDSAPrivateKey(curveName)
+	case KeyTypeEd25519:
+		return GenerateEd25519PrivateKey()
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", keyType)
+	}
+}
+
 // PrivateKeyToPEM encodes Private Key to PEM format
 func PrivateKeyToPEM(privateKey *rsa.PrivateKey) []byte {
 	// Get ASN.1 DER format
@@ -68,6 +193,75 @@ func PublicKeyToPEM(publicKey *rsa.PublicKey) []byte {
 	return pem.EncodeToMemory(&pubBlock)
 }
 
+// AnyPrivateKeyToPEM encodes RSA, ECDSA or Ed25519 Private Key to PEM format.
+// RSA keys are encoded as PKCS#1, ECDSA keys as SEC1 and Ed25519 keys as PKCS#8.
+func AnyPrivateKeyToPEM(privateKey crypto.Signer) ([]byte, error) {
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		return PrivateKeyToPEM(key), nil
+	case *ecdsa.PrivateKey:
+		privDER, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: privDER}), nil
+	case ed25519.PrivateKey:
+		return PKCS8PrivateKeyToPEM(key)
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", privateKey)
+	}
+}
+
+// PKCS8PrivateKeyToPEM encodes RSA, ECDSA or Ed25519 Private Key to PKCS#8 PEM format
+func PKCS8PrivateKeyToPEM(privateKey crypto.Signer) ([]byte, error) {
+	privDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}), nil
+}
+
+// EncryptedPrivateKeyToPEM encrypts RSA, ECDSA or Ed25519 Private Key with password
+// and encodes it to encrypted PKCS#8 PEM format
+func EncryptedPrivateKeyToPEM(privateKey crypto.Signer, password []byte) ([]byte, error) {
+	if len(password) == 0 {
+		return nil, errors.New("pass phrase cannot be empty")
+	}
+
+	privDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	encDER, err := EncryptPKCS8PrivateKey(privDER, password)
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: encDER}), nil
+}
+
+// AnyPublicKeyToPEM encodes RSA, ECDSA or Ed25519 Public Key to PEM format.
+// RSA keys are encoded as PKCS#1, others as PKIX (SubjectPublicKeyInfo).
+func AnyPublicKeyToPEM(publicKey crypto.PublicKey) ([]byte, error) {
+	if key, ok := publicKey.(*rsa.PublicKey); ok {
+		return PublicKeyToPEM(key), nil
+	}
+
+	return PKIXPublicKeyToPEM(publicKey)
+}
+
+// PKIXPublicKeyToPEM encodes RSA, ECDSA or Ed25519 Public Key to PKIX (SubjectPublicKeyInfo) PEM format
+func PKIXPublicKeyToPEM(publicKey crypto.PublicKey) ([]byte, error) {
+	pubDER, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), nil
+}
+
 // CertToPEM encodes Certificate to PEM format
 func CertToPEM(cert []byte) []byte {
 	// pem.Block
@@ -90,21 +284,102 @@ func CSRToPEM(cert []byte) []byte {
 	return pem.EncodeToMemory(&block)
 }
 
-func PrivateKeyFromFile(path string) (*rsa.PrivateKey, error) {
+// PrivateKeyFromFile reads a PKCS#1 (RSA), SEC1 (EC) or PKCS#8 encoded private key.
+// Encrypted private keys are rejected, see PrivateKeyFromFileWithPassword.
+func PrivateKeyFromFile(path string) (crypto.Signer, error) {
+	return PrivateKeyFromFileWithPassword(path, nil)
+}
+
+// PrivateKeyFromFileWithPassword reads a PKCS#1 (RSA), SEC1 (EC), PKCS#8 or
+// encrypted PKCS#8 encoded private key. Password is read with reader
+// only if the private key is encrypted.
+func PrivateKeyFromFileWithPassword(path string, reader PasswordReader) (crypto.Signer, error) {
 	block, err := readPEMfromFile(path)
 	if err != nil {
 		log.Printf("Failed to read PEM from file %s error: %v", path, err)
 		return nil, err
 	}
 
-	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	key, err := PrivateKeyFromPEM(block, reader)
 	if err != nil {
+		log.Printf("Failed to parse private key from file %q error: %v", path, err)
 		return nil, err
 	}
 
 	return key, nil
 }
 
+// PrivateKeyFromPEM parses a PKCS#1 (RSA), SEC1 (EC), PKCS#8 or encrypted PKCS#8
+// private key PEM block. Password is read with reader only if the private key is encrypted.
+func PrivateKeyFromPEM(block *pem.Block, reader PasswordReader) (crypto.Signer, error) {
+	der := block.Bytes
+	if block.Type == "ENCRYPTED PRIVATE KEY" {
+		if reader == nil {
+			return nil, errors.New("private key is encrypted, pass phrase is required")
+		}
+
+		pwd, err := reader.ReadPassword()
+		if err != nil {
+			log.Printf("Failed to read pass phrase error: %v", err)
+			return nil, err
+		}
+
+		der, err = DecryptPKCS8PrivateKey(der, []byte(pwd))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	key, err := parsePrivateKey(block.Type, der)
+	if err != nil {
+		if block.Type == "ENCRYPTED PRIVATE KEY" {
+			err = ErrIncorrectPassword
+		}
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// parsePrivateKey parses DER encoded private key according to PEM block type.
+// Unknown block types are tried with all supported encodings.
+func parsePrivateKey(blockType string, der []byte) (crypto.Signer, error) {
+	var (
+		key interface{}
+		err error
+	)
+
+	switch blockType {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(der)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(der)
+	case "PRIVATE KEY", "ENCRYPTED PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(der)
+	default:
+		if key, err = x509.ParsePKCS8PrivateKey(der); err == nil {
+			break
+		}
+		if key, err = x509.ParsePKCS1PrivateKey(der); err == nil {
+			break
+		}
+		if key, err = x509.ParseECPrivateKey(der); err == nil {
+			break
+		}
+		err = fmt.Errorf("unsupported private key in PEM block type %q", blockType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+
+	return signer, nil
+}
+
 func CSRFromFile(path string) (*x509.CertificateRequest, error) {
 	block, err := readPEMfromFile(path)
 	if err != nil {
@@ -175,3 +450,121 @@ func readPEMfromFile(path string) (*pem.Block, error) {
 
 	return block, nil
 }
+
+// PEMBlocksFromFile reads all PEM blocks from file
+func PEMBlocksFromFile(path string) ([]*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("Failed to read file %q error: %v", path, err)
+		return nil, err
+	}
+
+	var blocks []*pem.Block
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		blocks = append(blocks, block)
+	}
+
+	if len(blocks) == 0 {
+		err = fmt.Errorf("no PEM block found in file %q", path)
+		log.Printf("%v", err)
+		return nil, err
+	}
+
+	return blocks, nil
+}
+
+// CertsFromFile reads all certificates from a PEM file or a single certificate from a DER file
+func CertsFromFile(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("Failed to read cert file %q error: %v", path, err)
+		return nil, err
+	}
+
+	// DER encoded file
+	if !bytes.Contains(data, []byte("-----BEGIN")) {
+		cert, err := x509.ParseCertificate(data)
+		if err != nil {
+			log.Printf("Failed to parse x509 certificate from DER file %q error: %v", path, err)
+			return nil, err
+		}
+		return []*x509.Certificate{cert}, nil
+	}
+
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			log.Printf("Failed to parse x509 certificate from cert file %q error: %v", path, err)
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		err = fmt.Errorf("no certificate found in file %q", path)
+		log.Printf("%v", err)
+		return nil, err
+	}
+
+	return certs, nil
+}
+
+// PasswordReader reads a password or pass phrase
+type PasswordReader interface {
+	ReadPassword() (string, error)
+}
+
+// StdinPasswordReader reads password from terminal without echoing.
+// Prompt is printed before reading if it is set.
+type StdinPasswordReader struct {
+	Prompt string
+}
+
+func (pr StdinPasswordReader) ReadPassword() (string, error) {
+	if pr.Prompt != "" {
+		fmt.Print(pr.Prompt)
+		defer fmt.Println()
+	}
+
+	pwd, err := term.ReadPassword(int(syscall.Stdin))
+	if err != nil {
+		return "", err
+	}
+
+	return string(pwd), nil
+}
+
+// StaticPasswordReader returns the password it is created with, eg. from a flag
+type StaticPasswordReader struct {
+	Password string
+}
+
+func (pr StaticPasswordReader) ReadPassword() (string, error) {
+	return pr.Password, nil
+}
+
+// KeyPasswordReader returns a reader for pass phrase of the encrypted private key
+// in path. Pass phrase is taken from passwordFlag if it is set, otherwise it is
+// read from terminal.
+func KeyPasswordReader(c *cli.Context, passwordFlag, path string) PasswordReader {
+	if c.IsSet(passwordFlag) {
+		return StaticPasswordReader{Password: c.String(passwordFlag)}
+	}
+
+	return StdinPasswordReader{Prompt: fmt.Sprintf("Enter pass phrase for %s: ", path)}
+}
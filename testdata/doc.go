@@ -22,6 +22,11 @@ openssl x509 -req -days 365000 -set_serial 01 \
    -CAkey ca-key.pem \
    -extfile v3.ext
 
+openssl pkcs8 -topk8 -v2 aes256 \
+   -in ca-key.pem \
+   -out ca-key-encrypted.pem \
+   -passout pass:gossl
+
 v3.ext file content:
 subjectAltName = @alt_names
 